@@ -7,10 +7,26 @@ import (
 	"github.com/alexey-mavrin/graduate-2/cmd/client/internal/config"
 	"github.com/alexey-mavrin/graduate-2/internal/client"
 	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"github.com/alexey-mavrin/graduate-2/internal/crypt"
 )
 
 const minPasswordLen = 5
 
+// newClient returns the client configured by the config file.
+// The cache is encrypted with the key derived from the master key.
+func newClient() *client.Client {
+	clnt := client.NewClient(config.Cfg.ServerAddr,
+		config.Cfg.UserName,
+		config.Cfg.Password,
+		config.Cfg.CacheFile,
+		config.Cfg.HTTPSInsecure,
+	)
+	cacheKey := crypt.CacheKey(*config.Key)
+	clnt.CacheKey = &cacheKey
+	clnt.CacheOnly = config.Op.CacheOnly
+	return clnt
+}
+
 func checkPasswordRequirements(password string) bool {
 	if len(password) < minPasswordLen {
 		return false
@@ -19,12 +35,7 @@ func checkPasswordRequirements(password string) bool {
 }
 
 func actUser(subop config.OpSubtype, user common.User) error {
-	clnt := client.NewClient(config.Cfg.ServerAddr,
-		config.Cfg.UserName,
-		config.Cfg.Password,
-		config.Cfg.CacheFile,
-		config.Cfg.HTTPSInsecure,
-	)
+	clnt := newClient()
 	switch subop {
 	case config.OpSubtypeUserRegister:
 		id, err := clnt.RegisterUser(config.Cfg.FullName)
@@ -32,6 +43,10 @@ func actUser(subop config.OpSubtype, user common.User) error {
 			return err
 		}
 		log.Printf("user is registered with id %d", id)
+		err = publishKey(clnt, *config.Key)
+		if err != nil {
+			return err
+		}
 	case config.OpSubtypeUserVerify:
 		err := clnt.VerifyUser()
 		if err != nil {
@@ -48,17 +63,33 @@ func actUser(subop config.OpSubtype, user common.User) error {
 			return err
 		}
 		log.Printf("password is changed")
+	case config.OpSubtypeUserPublishKey:
+		err := publishKey(clnt, *config.Key)
+		if err != nil {
+			return err
+		}
+	case config.OpSubtypeUserRekey:
+		newKey, err := config.GetKey(config.Op.NewKeyFile)
+		if err != nil {
+			return err
+		}
+		err = rekey(clnt, *config.Key, *newKey)
+		if err != nil {
+			return err
+		}
+		log.Printf("master key is changed, set key_phrase_file to %s",
+			config.Op.NewKeyFile)
+	case config.OpSubtypeUserUpgrade:
+		err := upgradeRecords(clnt, *config.Key)
+		if err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
 func actCache(subop config.OpSubtype) error {
-	clnt := client.NewClient(config.Cfg.ServerAddr,
-		config.Cfg.UserName,
-		config.Cfg.Password,
-		config.Cfg.CacheFile,
-		config.Cfg.HTTPSInsecure,
-	)
+	clnt := newClient()
 	switch subop {
 	case config.OpSubtypeCacheClean:
 		err := clnt.CleanCache()
@@ -67,19 +98,17 @@ func actCache(subop config.OpSubtype) error {
 		}
 		log.Println("cache is cleaned")
 	case config.OpSubtypeCacheSync:
-		if err := clnt.SyncCacheByType(common.AccountRecord); err != nil {
-			return err
-		}
-		if err := clnt.SyncCacheByType(common.NoteRecord); err != nil {
-			return err
+		for _, t := range common.RecordTypes() {
+			if err := clnt.SyncCacheByType(t); err != nil {
+				return err
+			}
 		}
-		if err := clnt.SyncCacheByType(common.CardRecord); err != nil {
-			return err
-		}
-		if err := clnt.SyncCacheByType(common.BinaryRecord); err != nil {
+		log.Println("cache is synchronized")
+		ix, err := updateSearchIndex(clnt, *config.Key)
+		if err != nil {
 			return err
 		}
-		log.Println("cache is synchronized")
+		log.Printf("search index holds %d records", ix.Len())
 	}
 	return nil
 }
@@ -91,14 +120,26 @@ func ChooseAct() error {
 		return actUser(config.Op.Subop, config.Op.User)
 	case config.OpTypeCache:
 		return actCache(config.Op.Subop)
-	case config.OpTypeAccount:
-		return actRecord(config.Op.Subop, config.Op.Account)
-	case config.OpTypeNote:
-		return actRecord(config.Op.Subop, config.Op.Note)
-	case config.OpTypeCard:
-		return actRecord(config.Op.Subop, config.Op.Card)
-	case config.OpTypeBinary:
-		return actRecord(config.Op.Subop, config.Op.Binary)
+	case config.OpTypeVault:
+		return actVault(config.Op.Subop)
+	case config.OpTypeAgent:
+		return actAgent(config.Op.Subop)
+	case config.OpTypeGen:
+		return actGen()
+	case config.OpTypeAudit:
+		return actAudit(config.Op.Subop)
+	case config.OpTypeRun:
+		return actRun()
+	case config.OpTypeInject:
+		return actInject()
+	case config.OpTypeSearch:
+		return actSearch()
+	case config.OpTypeGitCredential:
+		return actGitCredential(config.Op.Subop)
+	case config.OpTypeDockerCredential:
+		return actDockerCredential(config.Op.Subop)
+	case config.OpTypeRecord:
+		return actRecord(config.Op.Subop, config.Op.Record)
 	default:
 		return errors.New("unknown operation type")
 	}
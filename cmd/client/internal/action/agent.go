@@ -0,0 +1,179 @@
+package action
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/alexey-mavrin/graduate-2/cmd/client/internal/config"
+	"github.com/alexey-mavrin/graduate-2/internal/agent"
+	"github.com/alexey-mavrin/graduate-2/internal/client"
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"github.com/alexey-mavrin/graduate-2/internal/crypt"
+)
+
+// agentStartTimeout is the time to wait for the agent started to listen
+const agentStartTimeout = 2 * time.Second
+
+// startAgent runs the agent in background detached from the terminal
+func startAgent(clnt *agent.Client) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(exe,
+		"agent",
+		"-a", "serve",
+		"-t", config.Op.AgentTimeout.String(),
+	)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+	err = cmd.Process.Release()
+	if err != nil {
+		return err
+	}
+
+	for start := time.Now(); time.Since(start) < agentStartTimeout; {
+		if _, err = clnt.Status(); err == nil {
+			return nil
+		}
+		time.Sleep(agentStartTimeout / 20)
+	}
+	return fmt.Errorf("agent is not started: %w", err)
+}
+
+// serveAgent runs the agent in foreground until it is stopped
+// or interrupted
+func serveAgent() error {
+	l, err := agent.Listen(config.AgentSocket())
+	if err != nil {
+		return err
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		l.Close()
+	}()
+
+	log.Printf("agent is listening on %s", config.AgentSocket())
+	return agent.NewAgent(config.Op.AgentTimeout).Serve(l)
+}
+
+// unlockAgent prompts for the secrets not set in the config file,
+// checks them against the server and passes them to the agent
+func unlockAgent(clnt *agent.Client) error {
+	var key *common.Key
+	var err error
+	if config.Cfg.KeyPhraseFile != "" {
+		key, err = config.GetKey(config.Cfg.KeyPhraseFile)
+	} else {
+		var phrase string
+		phrase, err = config.ReadSecret("key phrase")
+		if err == nil {
+			key, err = config.KeyFromPhrase(phrase)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	password := config.Cfg.Password
+	if password == "" {
+		password, err = config.ReadSecret("password of " + config.Cfg.UserName)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = checkSecrets(*key, password)
+	if err != nil {
+		return err
+	}
+	return clnt.Unlock(*key, password)
+}
+
+// checkSecrets verifies the password with the server and checks
+// the key matches the public key published
+func checkSecrets(key common.Key, password string) error {
+	clnt := client.NewClient(config.Cfg.ServerAddr,
+		config.Cfg.UserName,
+		password,
+		"",
+		config.Cfg.HTTPSInsecure,
+	)
+	err := clnt.VerifyUser()
+	if err != nil {
+		return err
+	}
+	published, err := clnt.GetPublicKey(config.Cfg.UserName)
+	if errors.Is(err, client.ErrNotFound) {
+		// no key is published, nothing to check
+		return nil
+	}
+	if err != nil || published == "" {
+		return err
+	}
+	pub, _, err := crypt.KeyPair(key)
+	if err != nil {
+		return err
+	}
+	if hex.EncodeToString(pub[:]) != published {
+		return errors.New("key phrase does not match the public key published")
+	}
+	return nil
+}
+
+func actAgent(subop config.OpSubtype) error {
+	clnt := agent.NewClient(config.AgentSocket())
+	switch subop {
+	case config.OpSubtypeAgentStart:
+		err := startAgent(clnt)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("agent is started on %s, unlock it with 'agent -a unlock'\n",
+			config.AgentSocket())
+	case config.OpSubtypeAgentServe:
+		return serveAgent()
+	case config.OpSubtypeAgentUnlock:
+		err := unlockAgent(clnt)
+		if err != nil {
+			return err
+		}
+		fmt.Println("agent is unlocked")
+	case config.OpSubtypeAgentLock:
+		err := clnt.Lock()
+		if err != nil {
+			return err
+		}
+		fmt.Println("agent is locked")
+	case config.OpSubtypeAgentStatus:
+		locked, err := clnt.Status()
+		if err != nil {
+			return err
+		}
+		if locked {
+			fmt.Println("agent is locked")
+		} else {
+			fmt.Println("agent is unlocked")
+		}
+	case config.OpSubtypeAgentStop:
+		err := clnt.Stop()
+		if err != nil {
+			return err
+		}
+		fmt.Println("agent is stopped")
+	}
+	return nil
+}
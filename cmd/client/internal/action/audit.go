@@ -0,0 +1,94 @@
+package action
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"time"
+
+	"github.com/alexey-mavrin/graduate-2/cmd/client/internal/config"
+	"github.com/alexey-mavrin/graduate-2/internal/audit"
+	"github.com/alexey-mavrin/graduate-2/internal/client"
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+)
+
+// stampPassword sets the time the account password is changed.
+// On update the time is kept if the password is the same.
+func stampPassword(clnt *client.Client,
+	key common.Key,
+	update bool,
+	account common.Account,
+) (common.Account, error) {
+	changed := time.Now().UTC().Truncate(time.Second)
+	account.PasswordChanged = &changed
+	if !update {
+		return account, nil
+	}
+	stored, err := storedAccount(clnt, key)
+	if err != nil {
+		return account, err
+	}
+	if stored.Password == account.Password {
+		account.PasswordChanged = stored.PasswordChanged
+	}
+	return account, nil
+}
+
+// auditItems returns all the accounts decrypted
+func auditItems(clnt *client.Client, key common.Key) ([]audit.Item, error) {
+	records, err := getRecords(clnt, key, common.AccountRecord)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]audit.Item, 0, len(records))
+	for id, record := range records {
+		var account common.Account
+		err = json.Unmarshal([]byte(record.Opaque), &account)
+		if err != nil {
+			log.Printf("cannot unpack account %d: %v", id, err)
+			continue
+		}
+		items = append(items, audit.Item{
+			ID:      id,
+			Name:    record.Name,
+			Account: account,
+		})
+	}
+	return items, nil
+}
+
+func actAudit(subop config.OpSubtype) error {
+	clnt := newClient()
+	key, err := recordKey(clnt)
+	if err != nil {
+		return err
+	}
+	items, err := auditItems(clnt, key)
+	if err != nil {
+		return err
+	}
+
+	opts := audit.Options{
+		MinEntropy: config.Op.AuditMinEntropy,
+		MaxAge:     time.Duration(config.Op.AuditDays) * 24 * time.Hour,
+		Now:        time.Now(),
+	}
+	if config.Op.AuditHIBP != "" {
+		passwords := make([]string, len(items))
+		for i, item := range items {
+			passwords[i] = item.Account.Password
+		}
+		opts.Breaches, err = audit.Breaches(config.Op.AuditHIBP, passwords)
+		if err != nil {
+			return err
+		}
+	}
+
+	report := audit.Audit(items, opts)
+	if config.Op.JSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	return report.WriteTable(os.Stdout)
+}
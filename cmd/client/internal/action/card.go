@@ -0,0 +1,60 @@
+package action
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/alexey-mavrin/graduate-2/cmd/client/internal/config"
+	"github.com/alexey-mavrin/graduate-2/internal/client"
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+)
+
+// listExpiringCards prints the cards expiring within the days
+// requested, including the cards already expired
+func listExpiringCards(clnt *client.Client, key common.Key) error {
+	records, err := getRecords(clnt, key, common.CardRecord)
+	if err != nil {
+		return err
+	}
+
+	type expiring struct {
+		id      int64
+		name    string
+		card    common.Card
+		expires time.Time
+	}
+	now := time.Now()
+	until := now.AddDate(0, 0, config.Op.ExpiringDays)
+	var cards []expiring
+	for id, record := range records {
+		var card common.Card
+		err = json.Unmarshal([]byte(record.Opaque), &card)
+		if err != nil {
+			return err
+		}
+		if expires := card.Expires(); expires.Before(until) {
+			cards = append(cards, expiring{id, record.Name, card, expires})
+		}
+	}
+	sort.Slice(cards, func(i, j int) bool {
+		return cards[i].expires.Before(cards[j].expires)
+	})
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tBRAND\tNUMBER\tEXPIRY\tSTATUS")
+	for _, c := range cards {
+		status := fmt.Sprintf("expires in %d days",
+			int(c.expires.Sub(now).Hours()/24))
+		if !c.expires.After(now) {
+			status = "expired"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%02d/%d\t%s\n",
+			c.id, c.name, c.card.Brand(), c.card.Masked().Number,
+			c.card.ExpMonth, c.card.ExpYear, status)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,177 @@
+package action
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/alexey-mavrin/graduate-2/cmd/client/internal/config"
+	"github.com/alexey-mavrin/graduate-2/internal/client"
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"github.com/alexey-mavrin/graduate-2/internal/credhelper"
+)
+
+// dockerMeta is the meta of the account records stored by Docker,
+// only these records are served to Docker
+const dockerMeta = "docker registry"
+
+// registryAccount returns the account stored by Docker best matching
+// the registry server URL. The other accounts are neither served,
+// nor overwritten or erased by Docker.
+func registryAccount(clnt *client.Client,
+	key common.Key,
+	serverURL string,
+) (credentialAccount, bool, error) {
+	c, err := credhelper.DockerURL(serverURL)
+	if err != nil {
+		return credentialAccount{}, false, err
+	}
+	matched, err := matchAccounts(clnt, key, c)
+	if err != nil {
+		return credentialAccount{}, false, err
+	}
+	for _, m := range matched {
+		if m.record.Meta == dockerMeta {
+			return m, true, nil
+		}
+	}
+	return credentialAccount{}, false, nil
+}
+
+// storeRegistry stores the registry credential: the account stored
+// by Docker matching the server URL is updated, the new account record
+// is stored if there is no match. Docker keeps one credential per registry.
+func storeRegistry(clnt *client.Client,
+	key common.Key,
+	d credhelper.DockerCredential,
+) error {
+	found, ok, err := registryAccount(clnt, key, d.ServerURL)
+	if err != nil {
+		return err
+	}
+	changed := time.Now().UTC().Truncate(time.Second)
+	if ok {
+		account := found.account
+		if account.UserName == d.Username && account.Password == d.Secret {
+			return nil
+		}
+		if account.Password != d.Secret {
+			account.PasswordChanged = &changed
+		}
+		account.UserName = d.Username
+		account.Password = d.Secret
+		return updateAccount(clnt, key, found.id, found.record, account)
+	}
+
+	c, err := credhelper.DockerURL(d.ServerURL)
+	if err != nil {
+		return err
+	}
+	opaque, err := common.Account{
+		URL:             d.ServerURL,
+		UserName:        d.Username,
+		Password:        d.Secret,
+		PasswordChanged: &changed,
+	}.Pack()
+	if err != nil {
+		return err
+	}
+	record := common.Record{
+		Name:   c.Name(),
+		Type:   common.AccountRecord,
+		Opaque: string(opaque),
+		Meta:   dockerMeta,
+	}
+	_, err = storeRecord(clnt, key, record)
+	if errors.Is(err, client.ErrAlreadyExists) {
+		// the account of the same name is not stored by Docker
+		record.Name = dockerMeta + " " + c.Name()
+		_, err = storeRecord(clnt, key, record)
+	}
+	return err
+}
+
+// listRegistries returns the user names of the registries stored
+// by Docker by the server URL
+func listRegistries(clnt *client.Client, key common.Key) (map[string]string, error) {
+	records, err := getRecords(clnt, key, common.AccountRecord)
+	if err != nil {
+		return nil, err
+	}
+	registries := make(map[string]string)
+	for _, record := range records {
+		if record.Meta != dockerMeta {
+			continue
+		}
+		var account common.Account
+		err = json.Unmarshal([]byte(record.Opaque), &account)
+		if err != nil {
+			return nil, err
+		}
+		registries[account.URL] = account.UserName
+	}
+	return registries, nil
+}
+
+func dockerCredential(subop config.OpSubtype) error {
+	clnt := newClient()
+	key, err := recordKey(clnt)
+	if err != nil {
+		return err
+	}
+
+	switch subop {
+	case config.OpSubtypeCredentialGet:
+		serverURL, err := credhelper.ReadServerURL(os.Stdin)
+		if err != nil {
+			return err
+		}
+		found, ok, err := registryAccount(clnt, key, serverURL)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return credhelper.ErrNotFound
+		}
+		return json.NewEncoder(os.Stdout).Encode(credhelper.DockerCredential{
+			ServerURL: serverURL,
+			Username:  found.account.UserName,
+			Secret:    found.account.Password,
+		})
+	case config.OpSubtypeCredentialStore:
+		d, err := credhelper.ReadDocker(os.Stdin)
+		if err != nil {
+			return err
+		}
+		return storeRegistry(clnt, key, d)
+	case config.OpSubtypeCredentialErase:
+		serverURL, err := credhelper.ReadServerURL(os.Stdin)
+		if err != nil {
+			return err
+		}
+		found, ok, err := registryAccount(clnt, key, serverURL)
+		if err != nil || !ok {
+			return err
+		}
+		return clnt.DeleteRecordByID(found.id)
+	case config.OpSubtypeCredentialList:
+		registries, err := listRegistries(clnt, key)
+		if err != nil {
+			return err
+		}
+		return json.NewEncoder(os.Stdout).Encode(registries)
+	}
+	return nil
+}
+
+// actDockerCredential implements Docker credential helper,
+// the error is written to stdout as Docker reads it from there
+func actDockerCredential(subop config.OpSubtype) error {
+	err := dockerCredential(subop)
+	if err != nil {
+		fmt.Println(err)
+	}
+	return err
+}
@@ -0,0 +1,73 @@
+package action
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/alexey-mavrin/graduate-2/cmd/client/internal/config"
+	"github.com/alexey-mavrin/graduate-2/internal/client"
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"github.com/alexey-mavrin/graduate-2/internal/urlmatch"
+)
+
+// accountCandidates returns the accounts matching the URL
+// at the level given at least, the closest match first
+func accountCandidates(clnt *client.Client,
+	key common.Key,
+	lookup string,
+	min urlmatch.Level,
+) ([]urlmatch.Candidate, error) {
+	records, err := getCachedRecords(clnt, key, common.AccountRecord)
+	if err != nil {
+		return nil, err
+	}
+	candidates := make([]urlmatch.Candidate, 0, len(records))
+	for id, record := range records {
+		var account common.Account
+		err = json.Unmarshal([]byte(record.Opaque), &account)
+		if err != nil {
+			log.Printf("cannot unpack account %d: %v", id, err)
+			continue
+		}
+		candidates = append(candidates, urlmatch.Candidate{
+			ID:       id,
+			Name:     record.Name,
+			UserName: account.UserName,
+			URL:      account.URL,
+		})
+	}
+	return urlmatch.Rank(lookup, candidates, min)
+}
+
+// findAccounts prints the accounts matching the URL requested,
+// the passwords are not printed
+func findAccounts(clnt *client.Client, key common.Key, url string) error {
+	found, err := accountCandidates(clnt, key, url, config.Op.URLMatch)
+	if err != nil {
+		return err
+	}
+	if config.Op.JSON {
+		if found == nil {
+			found = []urlmatch.Candidate{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(found)
+	}
+	if len(found) == 0 {
+		fmt.Printf("no accounts match %s by %s\n",
+			url, config.Op.URLMatch)
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tUSER\tURL\tMATCH")
+	for _, c := range found {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n",
+			c.ID, c.Name, c.UserName, c.URL, c.Level)
+	}
+	return tw.Flush()
+}
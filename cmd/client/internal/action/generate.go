@@ -0,0 +1,52 @@
+package action
+
+import (
+	"fmt"
+
+	"github.com/alexey-mavrin/graduate-2/cmd/client/internal/config"
+	"github.com/alexey-mavrin/graduate-2/internal/client"
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"github.com/alexey-mavrin/graduate-2/internal/generator"
+)
+
+// generatePassword returns the account with the password generated
+// by the rule of the account site. On update the account user name
+// and URL not set are taken from the stored record.
+func generatePassword(clnt *client.Client,
+	key common.Key,
+	update bool,
+	account common.Account,
+) (common.Account, error) {
+	if update {
+		stored, err := storedAccount(clnt, key)
+		if err != nil {
+			return account, err
+		}
+		if account.URL == "" {
+			account.URL = stored.URL
+		}
+		if account.UserName == "" {
+			account.UserName = stored.UserName
+		}
+	}
+
+	site := account.URL
+	if site == "" {
+		site = config.Op.RecordName
+	}
+	password, err := generator.Generate(config.GeneratorPolicy(site))
+	if err != nil {
+		return account, err
+	}
+	account.Password = password
+	return account, nil
+}
+
+func actGen() error {
+	password, err := generator.Generate(config.Op.GenPolicy)
+	if err != nil {
+		return err
+	}
+	fmt.Println(password)
+	return nil
+}
@@ -0,0 +1,67 @@
+package action
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/alexey-mavrin/graduate-2/cmd/client/internal/config"
+	"github.com/alexey-mavrin/graduate-2/internal/client"
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"github.com/alexey-mavrin/graduate-2/internal/crypt"
+)
+
+// loadSchema returns the user schema by name. The schemas are
+// the personal records of the user, the vault records use them too.
+func loadSchema(name string) (common.Schema, error) {
+	var schema common.Schema
+	clnt := newClient()
+	key := *config.Key
+	id, err := findRecordID(clnt, key, common.SchemaRecord, name)
+	if err != nil {
+		return schema, fmt.Errorf("schema %s: %w", name, err)
+	}
+	eRecord, err := clnt.GetRecordByID(id)
+	if err != nil {
+		return schema, err
+	}
+	record, err := crypt.DecryptRecord(key, recordBinding(clnt, id), eRecord)
+	if err != nil {
+		return schema, err
+	}
+	err = json.Unmarshal([]byte(record.Opaque), &schema)
+	return schema, err
+}
+
+// prepareGeneric returns the generic record to store or update.
+// On update the fields given are set over the fields stored. The field
+// types unset are taken from the schema, and the record is checked
+// against the schema if it has one.
+func prepareGeneric(clnt *client.Client,
+	key common.Key,
+	update bool,
+	g common.Generic,
+) (common.Opaque, error) {
+	if update {
+		record, err := getRecord(clnt, key)
+		if err != nil {
+			return nil, err
+		}
+		var stored common.Generic
+		err = json.Unmarshal([]byte(record.Opaque), &stored)
+		if err != nil {
+			return nil, err
+		}
+		if g.Schema != "" {
+			stored.Schema = g.Schema
+		}
+		g = stored.Set(g.Fields)
+	}
+	if g.Schema == "" {
+		return common.Schema{}.Apply(g), nil
+	}
+	schema, err := loadSchema(g.Schema)
+	if err != nil {
+		return nil, err
+	}
+	return common.SchemaGeneric{Generic: schema.Apply(g), Spec: schema}, nil
+}
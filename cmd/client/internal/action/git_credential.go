@@ -0,0 +1,186 @@
+package action
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/alexey-mavrin/graduate-2/cmd/client/internal/config"
+	"github.com/alexey-mavrin/graduate-2/internal/client"
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"github.com/alexey-mavrin/graduate-2/internal/credhelper"
+	"github.com/alexey-mavrin/graduate-2/internal/crypt"
+)
+
+// gitMeta is the meta of the account records stored by git,
+// only these records are erased on the credential rejected
+const gitMeta = "stored by git"
+
+// credentialAccount is the account record matching the credential
+type credentialAccount struct {
+	id      int64
+	record  common.Record
+	account common.Account
+	score   int
+}
+
+// matchAccounts returns the accounts matching the credential URL
+// and user name if it is set, the most specific match first
+func matchAccounts(clnt *client.Client,
+	key common.Key,
+	c credhelper.Credential,
+) ([]credentialAccount, error) {
+	records, err := getRecords(clnt, key, common.AccountRecord)
+	if err != nil {
+		return nil, err
+	}
+	var matched []credentialAccount
+	for id, record := range records {
+		var account common.Account
+		err = json.Unmarshal([]byte(record.Opaque), &account)
+		if err != nil {
+			log.Printf("cannot unpack account %d: %v", id, err)
+			continue
+		}
+		if c.Username != "" && account.UserName != c.Username {
+			continue
+		}
+		score, ok := c.Match(account.URL)
+		if !ok {
+			continue
+		}
+		matched = append(matched, credentialAccount{id, record, account, score})
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		if matched[i].score != matched[j].score {
+			return matched[i].score > matched[j].score
+		}
+		return matched[i].id < matched[j].id
+	})
+	return matched, nil
+}
+
+// updateAccount stores the account data in the existing record
+func updateAccount(clnt *client.Client,
+	key common.Key,
+	id int64,
+	record common.Record,
+	account common.Account,
+) error {
+	opaque, err := account.Pack()
+	if err != nil {
+		return err
+	}
+	record.Opaque = string(opaque)
+	eRecord, err := crypt.EncryptRecord(key, recordBinding(clnt, id), record)
+	if err != nil {
+		return err
+	}
+	return clnt.UpdateRecordByID(id, eRecord)
+}
+
+// storeCredential stores the credential approved: the password
+// of the best matching account is updated, the new account record
+// is stored if there is no match
+func storeCredential(clnt *client.Client,
+	key common.Key,
+	c credhelper.Credential,
+) error {
+	if c.Host == "" || c.Username == "" || c.Password == "" {
+		return nil
+	}
+	matched, err := matchAccounts(clnt, key, c)
+	if err != nil {
+		return err
+	}
+	changed := time.Now().UTC().Truncate(time.Second)
+	if len(matched) > 0 {
+		best := matched[0]
+		if best.account.Password == c.Password {
+			return nil
+		}
+		best.account.Password = c.Password
+		best.account.PasswordChanged = &changed
+		return updateAccount(clnt, key, best.id, best.record, best.account)
+	}
+
+	account := common.Account{
+		URL:             c.URL(),
+		UserName:        c.Username,
+		Password:        c.Password,
+		PasswordChanged: &changed,
+	}
+	opaque, err := account.Pack()
+	if err != nil {
+		return err
+	}
+	_, err = storeRecord(clnt, key, common.Record{
+		Name:   c.Name(),
+		Type:   common.AccountRecord,
+		Opaque: string(opaque),
+		Meta:   gitMeta,
+	})
+	return err
+}
+
+// eraseCredential deletes the accounts stored by git matching
+// the credential rejected. The accounts with the password other than
+// rejected and the accounts not stored by git are kept: the rejection
+// may be transient.
+func eraseCredential(clnt *client.Client,
+	key common.Key,
+	c credhelper.Credential,
+) error {
+	if c.Host == "" {
+		return nil
+	}
+	matched, err := matchAccounts(clnt, key, c)
+	if err != nil {
+		return err
+	}
+	for _, m := range matched {
+		if m.record.Meta != gitMeta {
+			continue
+		}
+		if c.Password != "" && m.account.Password != c.Password {
+			continue
+		}
+		err = clnt.DeleteRecordByID(m.id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// actGitCredential implements git credential helper: the credential
+// is read from stdin, the one found is written to stdout
+func actGitCredential(subop config.OpSubtype) error {
+	c, err := credhelper.ReadGit(os.Stdin)
+	if err != nil {
+		return err
+	}
+	clnt := newClient()
+	key, err := recordKey(clnt)
+	if err != nil {
+		return err
+	}
+
+	switch subop {
+	case config.OpSubtypeCredentialGet:
+		matched, err := matchAccounts(clnt, key, c)
+		if err != nil || len(matched) == 0 {
+			return err
+		}
+		c.Username = matched[0].account.UserName
+		c.Password = matched[0].account.Password
+		return c.WriteGit(os.Stdout)
+	case config.OpSubtypeCredentialStore:
+		return storeCredential(clnt, key, c)
+	case config.OpSubtypeCredentialErase:
+		return eraseCredential(clnt, key, c)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package action
+
+import (
+	"os"
+
+	"github.com/alexey-mavrin/graduate-2/cmd/client/internal/config"
+	"github.com/alexey-mavrin/graduate-2/internal/client"
+	"github.com/alexey-mavrin/graduate-2/internal/secretref"
+)
+
+func actInject() error {
+	tpl, err := os.ReadFile(config.Op.InjectInput)
+	if err != nil {
+		return err
+	}
+	clnt := newClient()
+	key, err := recordKey(clnt)
+	if err != nil {
+		return err
+	}
+	release := clnt.HoldCache()
+	out, err := secretref.Render(tpl, refResolver(clnt, key))
+	releaseCache(release, &err)
+	if err != nil {
+		return err
+	}
+
+	if config.Op.InjectOutput == "" {
+		_, err = os.Stdout.Write(out)
+		return err
+	}
+	return client.WriteSecretFile(config.Op.InjectOutput, out)
+}
@@ -0,0 +1,103 @@
+package action
+
+import (
+	"fmt"
+
+	"github.com/alexey-mavrin/graduate-2/cmd/client/internal/config"
+	"github.com/alexey-mavrin/graduate-2/internal/client"
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"github.com/alexey-mavrin/graduate-2/internal/crypt"
+)
+
+// labelFilter returns the blind indexes of the folder and tags
+// the records are selected by, nil selects all the records
+func labelFilter(key common.Key) []string {
+	var labels []string
+	if config.Op.RecordFolder != "" {
+		labels = append(labels, crypt.FolderIndex(key, config.Op.RecordFolder))
+	}
+	for _, tag := range config.Op.RecordTags {
+		labels = append(labels, crypt.TagIndex(key, tag))
+	}
+	return labels
+}
+
+// selectedRecords returns the IDs of the records to move or retag:
+// the record requested by ID or name, or the records in the folder
+// and with the tags requested
+func selectedRecords(clnt *client.Client, key common.Key) ([]int64, error) {
+	if config.Op.RecordID != 0 || config.Op.RecordName != "" {
+		id, err := recordID(clnt, key)
+		if err != nil {
+			return nil, err
+		}
+		return []int64{id}, nil
+	}
+	records, err := clnt.ListRecordsByLabels(config.Op.RecordType, labelFilter(key))
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, 0, len(records))
+	for id := range records {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// retag returns the tags with the tags requested added and removed
+func retag(tags []string) []string {
+	remove := make(map[string]bool, len(config.Op.RemoveTags))
+	for _, tag := range config.Op.RemoveTags {
+		remove[tag] = true
+	}
+	var result []string
+	for _, tag := range append(tags, config.Op.AddTags...) {
+		if !remove[tag] {
+			result = append(result, tag)
+		}
+	}
+	return common.CleanTags(result)
+}
+
+// relabelRecords moves the selected records to another folder or changes
+// their tags. Each record is re-encrypted as its folder and tags
+// are encrypted with the record data.
+func relabelRecords(clnt *client.Client,
+	key common.Key,
+	subop config.OpSubtype,
+) (err error) {
+	ids, err := selectedRecords(clnt, key)
+	if err != nil {
+		return err
+	}
+	defer releaseCache(clnt.HoldCache(), &err)
+	for _, id := range ids {
+		eRecord, err := clnt.GetRecordByID(id)
+		if err != nil {
+			return err
+		}
+		record, err := crypt.DecryptRecord(key, recordBinding(clnt, id), eRecord)
+		if err != nil {
+			return fmt.Errorf("record %d: %w", id, err)
+		}
+		if subop == config.OpSubtypeRecordMove {
+			record.Folder = config.Op.MoveTo
+		} else {
+			record.Tags = retag(record.Tags)
+		}
+		eRecord, err = crypt.EncryptRecord(key, recordBinding(clnt, id), record)
+		if err != nil {
+			return err
+		}
+		err = clnt.UpdateRecordByID(id, eRecord)
+		if err != nil {
+			return err
+		}
+	}
+	if subop == config.OpSubtypeRecordMove {
+		fmt.Printf("%d records moved to %q\n", len(ids), config.Op.MoveTo)
+	} else {
+		fmt.Printf("%d records retagged\n", len(ids))
+	}
+	return nil
+}
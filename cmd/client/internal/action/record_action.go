@@ -2,7 +2,10 @@ package action
 
 import (
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"os"
 
 	"github.com/alexey-mavrin/graduate-2/cmd/client/internal/config"
@@ -13,27 +16,170 @@ import (
 
 const defaultFileMode = 0600
 
-func getRecord(clnt *client.Client) (common.Record, error) {
-	var eRecord common.Record
-	var err error
+// recordBinding returns the binding of the record to its owner: the vault
+// or the current user. The id binds the records of the previous formats.
+func recordBinding(clnt *client.Client, id int64) crypt.Binding {
+	return crypt.Binding{
+		Owner: crypt.RecordOwner(clnt.UserName, clnt.Vault),
+		ID:    id,
+	}
+}
+
+// recordID returns the ID of the record requested by ID or by type and name.
+// The record is looked up by the name blind index, the clear text name
+// is never sent to the server. The records stored with clear text name
+// are requested by ID until they are upgraded.
+func recordID(clnt *client.Client, key common.Key) (int64, error) {
 	if config.Op.RecordID != 0 {
-		eRecord, err = clnt.GetRecordByID(config.Op.RecordID)
-	} else {
-		eRecord, err = clnt.GetRecordByTypeName(
-			config.Op.RecordType,
-			config.Op.RecordName,
-		)
+		return config.Op.RecordID, nil
+	}
+	return findRecordID(clnt, key, config.Op.RecordType, config.Op.RecordName)
+}
+
+// findRecordID returns the ID of the record of the type and name,
+// the error matches client.ErrNotFound if there is no such record
+func findRecordID(clnt *client.Client,
+	key common.Key,
+	t common.RecordType,
+	name string,
+) (int64, error) {
+	id, err := clnt.GetRecordID(t, crypt.NameIndex(key, t, name))
+	if errors.Is(err, client.ErrNotFound) {
+		return 0, fmt.Errorf("%s %q: %w", t, name, err)
 	}
+	return id, err
+}
+
+// decryptListed decrypts the names, folders and tags of the records listed
+func decryptListed(clnt *client.Client,
+	key common.Key,
+	records common.Records,
+) common.Records {
+	for id, record := range records {
+		record, err := crypt.DecryptRecordLabels(key, recordBinding(clnt, id), record)
+		if err != nil {
+			log.Printf("cannot decrypt record %d name: %v", id, err)
+			continue
+		}
+		records[id] = record
+	}
+	return records
+}
+
+func getRecord(clnt *client.Client, key common.Key) (common.Record, error) {
+	id, err := recordID(clnt, key)
+	if err != nil {
+		return common.Record{}, err
+	}
+	eRecord, err := clnt.GetRecordByID(id)
 	if err != nil {
 		return eRecord, err
 	}
-	record, err := crypt.DecryptRecord(*config.Key, eRecord)
+	record, err := crypt.DecryptRecord(key, recordBinding(clnt, id), eRecord)
 	if err != nil {
 		return record, err
 	}
+	// the record is bound to its name, the server cannot give
+	// another record for the name requested
+	if config.Op.RecordID == 0 &&
+		(record.Name != config.Op.RecordName || record.Type != config.Op.RecordType) {
+		return record, fmt.Errorf("record %d is not %s %q", id,
+			config.Op.RecordType, config.Op.RecordName)
+	}
 	return record, nil
 }
 
+// getRecords returns all the records of the type decrypted,
+// the records that cannot be decrypted are skipped
+func getRecords(clnt *client.Client,
+	key common.Key,
+	t common.RecordType,
+) (common.Records, error) {
+	return decryptRecords(clnt, key, t, clnt.GetRecordByID)
+}
+
+// getCachedRecords returns all the records of the type decrypted,
+// the records are taken from the cache when possible
+func getCachedRecords(clnt *client.Client,
+	key common.Key,
+	t common.RecordType,
+) (common.Records, error) {
+	return decryptRecords(clnt, key, t, clnt.GetCachedRecordByID)
+}
+
+// releaseCache writes the cache file held for a batch of records once,
+// the error of the batch takes precedence
+func releaseCache(release func() error, err *error) {
+	if releaseErr := release(); *err == nil {
+		*err = releaseErr
+	}
+}
+
+// decryptRecords returns the records of the type listed decrypted,
+// each record is got by the function given
+func decryptRecords(clnt *client.Client,
+	key common.Key,
+	t common.RecordType,
+	get func(int64) (common.Record, error),
+) (_ common.Records, err error) {
+	defer releaseCache(clnt.HoldCache(), &err)
+
+	list, err := clnt.ListRecordsByType(t)
+	if err != nil {
+		return nil, err
+	}
+	records := make(common.Records)
+	for id := range list {
+		eRecord, err := get(id)
+		if err != nil {
+			return nil, err
+		}
+		record, err := crypt.DecryptRecord(key, recordBinding(clnt, id), eRecord)
+		if err != nil {
+			log.Printf("cannot decrypt record %d: %v", id, err)
+			continue
+		}
+		records[id] = record
+	}
+	return records, nil
+}
+
+// checkOpaque checks the record data before storing,
+// the expired card is accepted if forced
+func checkOpaque(subrecord common.Opaque) error {
+	err := subrecord.Check()
+	if errors.Is(err, common.ErrCardExpired) && config.Op.Force {
+		log.Print(err)
+		return nil
+	}
+	return err
+}
+
+// storedAccount returns the account stored in the record requested
+func storedAccount(clnt *client.Client, key common.Key) (common.Account, error) {
+	var account common.Account
+	record, err := getRecord(clnt, key)
+	if err != nil {
+		return account, err
+	}
+	err = json.Unmarshal([]byte(record.Opaque), &account)
+	return account, err
+}
+
+// storeRecord encrypts and stores the new record. The record is bound
+// to its name, not to the ID assigned by the server, so it is stored
+// encrypted in a single request.
+func storeRecord(clnt *client.Client,
+	key common.Key,
+	record common.Record,
+) (int64, error) {
+	eRecord, err := crypt.EncryptRecord(key, recordBinding(clnt, 0), record)
+	if err != nil {
+		return 0, err
+	}
+	return clnt.StoreRecord(eRecord)
+}
+
 func mergeRecord(newRecord, oldRecord common.Record) common.Record {
 	if !config.Op.RecordChange.Name {
 		newRecord.Name = oldRecord.Name
@@ -44,25 +190,47 @@ func mergeRecord(newRecord, oldRecord common.Record) common.Record {
 	if !config.Op.RecordChange.Meta {
 		newRecord.Meta = oldRecord.Meta
 	}
+	if !config.Op.RecordChange.Folder {
+		newRecord.Folder = oldRecord.Folder
+	}
+	if !config.Op.RecordChange.Tags {
+		newRecord.Tags = oldRecord.Tags
+	}
 	return newRecord
 }
 
 func actRecord(subop config.OpSubtype, subrecord common.Opaque) error {
-	clnt := client.NewClient(config.Cfg.ServerAddr,
-		config.Cfg.UserName,
-		config.Cfg.Password,
-		config.Cfg.CacheFile,
-		config.Cfg.HTTPSInsecure,
-	)
+	clnt := newClient()
+	key, err := recordKey(clnt)
+	if err != nil {
+		return err
+	}
+	if subop == config.OpSubtypeSSHGenerate {
+		subop = config.OpSubtypeRecordStore
+	}
+	info, _ := common.LookupType(config.Op.RecordType)
+	if info.Hooks.Prepare != nil &&
+		(subop == config.OpSubtypeRecordStore ||
+			subop == config.OpSubtypeRecordUpdate && config.Op.RecordChange.Opaque) {
+		subrecord, err = info.Hooks.Prepare(recordEnv{clnt: clnt, key: key},
+			subrecord,
+			subop == config.OpSubtypeRecordUpdate,
+		)
+		if err != nil {
+			return err
+		}
+	}
 	switch subop {
 	case config.OpSubtypeRecordStore:
 		record := common.Record{
-			Name: config.Op.RecordName,
-			Type: config.Op.RecordType,
-			Meta: config.Op.RecordMeta,
+			Name:   config.Op.RecordName,
+			Type:   config.Op.RecordType,
+			Meta:   config.Op.RecordMeta,
+			Folder: config.Op.RecordFolder,
+			Tags:   config.Op.RecordTags,
 		}
 
-		err := subrecord.Check()
+		err := checkOpaque(subrecord)
 		if err != nil {
 			return err
 		}
@@ -73,46 +241,48 @@ func actRecord(subop config.OpSubtype, subrecord common.Opaque) error {
 		}
 		record.Opaque = string(opaque)
 
-		eRecord, err := crypt.EncryptRecord(*config.Key, record)
-		if err != nil {
-			return err
-		}
-		id, err := clnt.StoreRecord(eRecord)
+		id, err := storeRecord(clnt, key, record)
 		if err != nil {
 			return err
 		}
 		fmt.Printf("record stored with id %d\n", id)
 	case config.OpSubtypeRecordGet:
-		record, err := getRecord(clnt)
+		record, err := getRecord(clnt, key)
 		if err != nil {
 			return err
 		}
 
-		fmt.Println(record)
-
-		if config.Op.RecordType == common.BinaryRecord {
-			err = writeDecodeFile(config.Op.FileName, record.Opaque)
+		if !config.Op.Reveal {
+			record.Opaque, err = common.Mask(record.Type, record.Opaque)
 			if err != nil {
 				return err
 			}
-			fmt.Printf("  File %s is written\n", config.Op.FileName)
+		}
+		fmt.Println(record)
+
+		if info.Hooks.Got != nil {
+			return info.Hooks.Got(recordEnv{clnt: clnt, key: key}, record)
 		}
 	case config.OpSubtypeRecordList:
-		records, err := clnt.ListRecordsByType(config.Op.RecordType)
+		records, err := clnt.ListRecordsByLabels(config.Op.RecordType,
+			labelFilter(key),
+		)
 		if err != nil {
 			return err
 		}
-		fmt.Println(records)
+		fmt.Println(decryptListed(clnt, key, records))
 	case config.OpSubtypeRecordUpdate:
 		record := common.Record{
-			Name: config.Op.RecordName,
-			Type: config.Op.RecordType,
-			Meta: config.Op.RecordMeta,
+			Name:   config.Op.RecordName,
+			Type:   config.Op.RecordType,
+			Meta:   config.Op.RecordMeta,
+			Folder: config.Op.RecordFolder,
+			Tags:   config.Op.RecordTags,
 		}
 
 		// check opaque fields for validity if their change is requested
 		if config.Op.RecordChange.Opaque {
-			err := subrecord.Check()
+			err := checkOpaque(subrecord)
 			if err != nil {
 				return err
 			}
@@ -125,32 +295,39 @@ func actRecord(subop config.OpSubtype, subrecord common.Opaque) error {
 		}
 		record.Opaque = string(opaque)
 
-		// use server-side data for unchanged name, meta or opaque
-		if !config.Op.RecordChange.Name || !config.Op.RecordChange.Opaque || !config.Op.RecordChange.Meta {
-			serverRecord, err := getRecord(clnt)
+		// use server-side data for unchanged name, meta, opaque, folder or tags
+		change := config.Op.RecordChange
+		if !change.Name || !change.Opaque || !change.Meta || !change.Folder || !change.Tags {
+			serverRecord, err := getRecord(clnt, key)
 			if err != nil {
 				return err
 			}
 			record = mergeRecord(record, serverRecord)
 		}
 
-		eRecord, err := crypt.EncryptRecord(*config.Key, record)
+		id, err := recordID(clnt, key)
 		if err != nil {
 			return err
 		}
-
-		if config.Op.RecordID != 0 {
-			err = clnt.UpdateRecordByID(config.Op.RecordID, eRecord)
-		} else {
-			err = clnt.UpdateRecordByTypeName(config.Op.RecordType,
-				config.Op.RecordName,
-				eRecord,
-			)
+		eRecord, err := crypt.EncryptRecord(key, recordBinding(clnt, id), record)
+		if err != nil {
+			return err
 		}
+		err = clnt.UpdateRecordByID(id, eRecord)
 		if err != nil {
 			return err
 		}
 		fmt.Println("record updated")
+	case config.OpSubtypeRecordMove, config.OpSubtypeRecordRetag:
+		return relabelRecords(clnt, key, subop)
+	case config.OpSubtypeAccountFind:
+		return findAccounts(clnt, key, subrecord.(common.Account).URL)
+	case config.OpSubtypeCardExpiring:
+		return listExpiringCards(clnt, key)
+	case config.OpSubtypeTOTPCode:
+		return printTOTPCode(clnt, key, subrecord.(common.TOTP).Account)
+	case config.OpSubtypeSSHAgent:
+		return serveSSHAgent(clnt, key)
 	case config.OpSubtypeRecordDelete:
 		if config.Op.RecordID != 0 {
 			err := clnt.DeleteRecordByID(config.Op.RecordID)
@@ -159,10 +336,11 @@ func actRecord(subop config.OpSubtype, subrecord common.Opaque) error {
 			}
 			fmt.Printf("Record %d deleted\n", config.Op.RecordID)
 		} else {
-			err := clnt.DeleteRecordByTypeName(
-				config.Op.RecordType,
-				config.Op.RecordName,
-			)
+			id, err := recordID(clnt, key)
+			if err != nil {
+				return err
+			}
+			err = clnt.DeleteRecordByID(id)
 			if err != nil {
 				return err
 			}
@@ -172,6 +350,16 @@ func actRecord(subop config.OpSubtype, subrecord common.Opaque) error {
 	return nil
 }
 
+// recordKey returns the key to encrypt the records with: the vault key
+// for the vault records or the user key for the personal ones
+func recordKey(clnt *client.Client) (common.Key, error) {
+	if config.Op.Vault == "" {
+		return *config.Key, nil
+	}
+	clnt.Vault = config.Op.Vault
+	return vaultKey(clnt, config.Op.Vault)
+}
+
 func writeDecodeFile(file, str string) error {
 	data, err := base64.StdEncoding.DecodeString(str)
 	if err != nil {
@@ -0,0 +1,110 @@
+package action
+
+import (
+	"fmt"
+
+	"github.com/alexey-mavrin/graduate-2/cmd/client/internal/config"
+	"github.com/alexey-mavrin/graduate-2/internal/client"
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+)
+
+// recordEnv is the client state the record type hooks run with
+type recordEnv struct {
+	clnt *client.Client
+	key  common.Key
+}
+
+func init() {
+	common.SetHooks(common.AccountRecord, common.Hooks{
+		Prepare: prepareAccountHook,
+		Got:     gotAccountHook,
+	})
+	common.SetHooks(common.BinaryRecord, common.Hooks{
+		Got: gotBinaryHook,
+	})
+	common.SetHooks(common.TOTPRecord, common.Hooks{
+		Prepare: prepareTOTPHook,
+	})
+	common.SetHooks(common.SSHRecord, common.Hooks{
+		Prepare: prepareSSHHook,
+	})
+	common.SetHooks(common.GenericRecord, common.Hooks{
+		Prepare: prepareGenericHook,
+	})
+}
+
+// prepareAccountHook generates the password if requested and sets
+// the time the password is changed
+func prepareAccountHook(env interface{},
+	data common.Opaque,
+	update bool,
+) (common.Opaque, error) {
+	e := env.(recordEnv)
+	account := data.(common.Account)
+	if config.Op.GenPassword {
+		var err error
+		account, err = generatePassword(e.clnt, e.key, update, account)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Println("password is generated")
+	}
+	return stampPassword(e.clnt, e.key, update, account)
+}
+
+// gotAccountHook prints the code of the TOTP secret linked
+// to the account if requested
+func gotAccountHook(env interface{}, record common.Record) error {
+	e := env.(recordEnv)
+	if config.Op.LinkedCode {
+		printLinkedCode(e.clnt, e.key, record.Name)
+	}
+	return nil
+}
+
+// gotBinaryHook writes the binary record data to the file
+func gotBinaryHook(_ interface{}, record common.Record) error {
+	err := writeDecodeFile(config.Op.FileName, record.Opaque)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("  File %s is written\n", config.Op.FileName)
+	return nil
+}
+
+// prepareTOTPHook checks the account the secret is linked to exists
+func prepareTOTPHook(env interface{},
+	data common.Opaque,
+	_ bool,
+) (common.Opaque, error) {
+	e := env.(recordEnv)
+	totp := data.(common.TOTP)
+	if totp.Account == "" {
+		return totp, nil
+	}
+	_, err := findRecordID(e.clnt, e.key, common.AccountRecord, totp.Account)
+	if err != nil {
+		return nil, fmt.Errorf("account %s: %w", totp.Account, err)
+	}
+	return totp, nil
+}
+
+// prepareSSHHook generates the key to store if requested
+func prepareSSHHook(_ interface{},
+	data common.Opaque,
+	_ bool,
+) (common.Opaque, error) {
+	if config.Op.Subop != config.OpSubtypeSSHGenerate {
+		return data, nil
+	}
+	return generateSSHKey(data.(common.SSHKey).Comment)
+}
+
+// prepareGenericHook applies the schema to the generic record
+func prepareGenericHook(env interface{},
+	data common.Opaque,
+	update bool,
+) (common.Opaque, error) {
+	e := env.(recordEnv)
+	return prepareGeneric(e.clnt, e.key, update, data.(common.Generic))
+}
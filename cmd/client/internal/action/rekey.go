@@ -0,0 +1,123 @@
+package action
+
+import (
+	"log"
+
+	"github.com/alexey-mavrin/graduate-2/internal/client"
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"github.com/alexey-mavrin/graduate-2/internal/crypt"
+)
+
+// rekey switches the user to the new master key: rewraps the data keys
+// of the personal records and the vault keys, and publishes
+// the new public key. Records and vault keys already wrapped
+// by the new key are skipped, so rekey can be repeated after a failure.
+// The cache encrypted with the old key is wiped.
+func rekey(clnt *client.Client, oldKey, newKey common.Key) (err error) {
+	defer releaseCache(clnt.HoldCache(), &err)
+
+	oldPub, oldPriv, err := crypt.KeyPair(oldKey)
+	if err != nil {
+		return err
+	}
+	newPub, newPriv, err := crypt.KeyPair(newKey)
+	if err != nil {
+		return err
+	}
+
+	vaults, err := clnt.ListVaults()
+	if err != nil {
+		return err
+	}
+	for _, v := range vaults {
+		wrapped, err := clnt.GetVaultKey(v.Name)
+		if err != nil {
+			return err
+		}
+		if _, err := crypt.UnwrapKey(newPub, newPriv, wrapped); err == nil {
+			continue
+		}
+		key, err := crypt.UnwrapKey(oldPub, oldPriv, wrapped)
+		if err != nil {
+			return err
+		}
+		wrapped, err = crypt.WrapKey(newPub, key)
+		if err != nil {
+			return err
+		}
+		err = clnt.SetVaultMemberKey(v.Name, clnt.UserName, wrapped)
+		if err != nil {
+			return err
+		}
+		log.Printf("vault %s key is rewrapped", v.Name)
+	}
+
+	records, err := clnt.ListRecords()
+	if err != nil {
+		return err
+	}
+	rewrapped := 0
+	for id := range records {
+		eRecord, err := clnt.GetRecordByID(id)
+		if err != nil {
+			return err
+		}
+		b := recordBinding(clnt, id)
+		if _, err := crypt.DecryptRecord(newKey, b, eRecord); err == nil {
+			continue
+		}
+		eRecord, err = crypt.RewrapRecord(oldKey, newKey, b, eRecord)
+		if err != nil {
+			return err
+		}
+		err = clnt.UpdateRecordByID(id, eRecord)
+		if err != nil {
+			return err
+		}
+		rewrapped++
+	}
+	log.Printf("%d records are rewrapped", rewrapped)
+
+	err = clnt.CleanCache()
+	if err != nil {
+		return err
+	}
+
+	return publishKey(clnt, newKey)
+}
+
+// upgradeRecords re-encrypts the records stored in the formats of the
+// previous versions, e.g. with the clear text names, in the current format.
+// The records with clear text names are not found by name until upgraded.
+func upgradeRecords(clnt *client.Client, key common.Key) (err error) {
+	defer releaseCache(clnt.HoldCache(), &err)
+
+	records, err := clnt.ListRecords()
+	if err != nil {
+		return err
+	}
+	upgraded := 0
+	for id := range records {
+		eRecord, err := clnt.GetRecordByID(id)
+		if err != nil {
+			return err
+		}
+		if !crypt.LegacyFormat(eRecord) {
+			continue
+		}
+		eRecord, err = crypt.RewrapRecord(key, key, recordBinding(clnt, id), eRecord)
+		if err != nil {
+			return err
+		}
+		err = clnt.UpdateRecordByID(id, eRecord)
+		if err != nil {
+			return err
+		}
+		upgraded++
+	}
+	log.Printf("%d records are upgraded", upgraded)
+	if !crypt.RejectUnbound {
+		log.Print("set reject_unbound to true to reject the records not upgraded")
+	}
+	return nil
+}
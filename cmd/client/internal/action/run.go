@@ -0,0 +1,158 @@
+package action
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"github.com/alexey-mavrin/graduate-2/cmd/client/internal/config"
+	"github.com/alexey-mavrin/graduate-2/internal/client"
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"github.com/alexey-mavrin/graduate-2/internal/crypt"
+	"github.com/alexey-mavrin/graduate-2/internal/secretref"
+)
+
+// runVars returns the variables of the .env template followed by
+// the variables of the flags, so the flags override the template
+func runVars() ([]secretref.Var, error) {
+	var vars []secretref.Var
+	if config.Op.RunEnvFile != "" {
+		f, err := os.Open(config.Op.RunEnvFile)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		vars, err = secretref.ParseEnv(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", config.Op.RunEnvFile, err)
+		}
+	}
+	for _, s := range config.Op.RunVars {
+		v, err := secretref.ParseVar(s)
+		if err != nil {
+			return nil, err
+		}
+		vars = append(vars, v)
+	}
+	return vars, nil
+}
+
+// refResolver returns the function resolving the secret references
+// to the record field values, each record is fetched once
+func refResolver(clnt *client.Client,
+	key common.Key,
+) func(secretref.Ref) (string, error) {
+	records := make(map[secretref.Ref]common.Record)
+	return func(ref secretref.Ref) (string, error) {
+		recordRef := secretref.Ref{Type: ref.Type, Name: ref.Name}
+		record, ok := records[recordRef]
+		if !ok {
+			id, err := findRecordID(clnt, key, ref.Type, ref.Name)
+			if err != nil {
+				return "", err
+			}
+			eRecord, err := clnt.GetRecordByID(id)
+			if err != nil {
+				return "", err
+			}
+			record, err = crypt.DecryptRecord(key, recordBinding(clnt, id), eRecord)
+			if err != nil {
+				return "", err
+			}
+			records[recordRef] = record
+		}
+		return secretref.Field(record.Opaque, ref.Field)
+	}
+}
+
+// resolveVars returns the environment with the secret references resolved
+// and the secret values resolved
+func resolveVars(clnt *client.Client,
+	key common.Key,
+	vars []secretref.Var,
+) (_ []string, _ []string, err error) {
+	defer releaseCache(clnt.HoldCache(), &err)
+
+	env := make([]string, 0, len(vars))
+	var secrets []string
+	resolve := refResolver(clnt, key)
+	for _, v := range vars {
+		if !secretref.IsRef(v.Value) {
+			env = append(env, v.Name+"="+v.Value)
+			continue
+		}
+		ref, err := secretref.Parse(v.Value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", v.Name, err)
+		}
+		value, err := resolve(ref)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %s: %w", v.Name, ref, err)
+		}
+		env = append(env, v.Name+"="+value)
+		secrets = append(secrets, value)
+	}
+	return env, secrets, nil
+}
+
+// runCommand runs the command with the environment added and forwards
+// the interrupt and termination signals to it. The secrets are masked
+// in the command output if requested.
+func runCommand(args []string, env []string, secrets []string) error {
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	var closers []io.Closer
+	if config.Op.RunMask {
+		stdout := secretref.NewMasker(os.Stdout, secrets)
+		stderr := secretref.NewMasker(os.Stderr, secrets)
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+		closers = append(closers, stdout, stderr)
+	}
+
+	err := cmd.Start()
+	if err != nil {
+		return err
+	}
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		for s := range sig {
+			cmd.Process.Signal(s)
+		}
+	}()
+
+	err = cmd.Wait()
+	signal.Stop(sig)
+	close(sig)
+	for _, c := range closers {
+		if errClose := c.Close(); errClose != nil && err == nil {
+			err = errClose
+		}
+	}
+	return err
+}
+
+func actRun() error {
+	vars, err := runVars()
+	if err != nil {
+		return err
+	}
+	clnt := newClient()
+	key, err := recordKey(clnt)
+	if err != nil {
+		return err
+	}
+	env, secrets, err := resolveVars(clnt, key, vars)
+	if err != nil {
+		return err
+	}
+
+	return runCommand(config.Op.RunCommand, env, secrets)
+}
@@ -0,0 +1,104 @@
+package action
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/alexey-mavrin/graduate-2/cmd/client/internal/config"
+	"github.com/alexey-mavrin/graduate-2/internal/client"
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"github.com/alexey-mavrin/graduate-2/internal/crypt"
+	"github.com/alexey-mavrin/graduate-2/internal/search"
+)
+
+// indexRecords brings the index up to the cached records, the records
+// new or changed since the last update are decrypted and indexed, the
+// records gone are dropped. It returns the number of the records changed.
+// The secret fields are indexed if requested. The records that cannot
+// be decrypted are indexed by their clear text fields only
+// until they are decrypted.
+func indexRecords(clnt *client.Client,
+	key common.Key,
+	ix *search.Index,
+	secrets bool,
+) (int, error) {
+	records, err := clnt.CachedRecords()
+	if err != nil {
+		return 0, err
+	}
+	sums := make(map[int64]string, len(records))
+	for id, record := range records {
+		sums[id] = search.Sum(record)
+	}
+	return ix.Update(sums, func(id int64) (search.Document, error) {
+		record, err := crypt.DecryptRecord(key, recordBinding(clnt, id), records[id])
+		if err != nil {
+			log.Printf("cannot decrypt record %d: %v", id, err)
+			return search.Document{Type: records[id].Type}, search.ErrPartial
+		}
+		doc, err := search.RecordDocument(id, record, secrets)
+		if err != nil {
+			log.Printf("cannot unpack record %d: %v", id, err)
+		}
+		return doc, nil
+	})
+}
+
+// updateSearchIndex updates the search index file by the cached records
+func updateSearchIndex(clnt *client.Client, key common.Key) (*search.Index, error) {
+	ix, err := clnt.LoadSearchIndex()
+	if err != nil {
+		return nil, err
+	}
+	changed, err := indexRecords(clnt, key, ix, false)
+	if err != nil {
+		return nil, err
+	}
+	if changed == 0 {
+		return ix, nil
+	}
+	return ix, clnt.SaveSearchIndex(ix)
+}
+
+// actSearch prints the cached records matching the query. The secret
+// fields are searched by the index built in memory, they are never
+// written to the index file.
+func actSearch() error {
+	clnt := newClient()
+	key := *config.Key
+
+	var ix *search.Index
+	var err error
+	if config.Op.SearchSecrets {
+		ix = search.NewIndex()
+		_, err = indexRecords(clnt, key, ix, true)
+	} else {
+		ix, err = updateSearchIndex(clnt, key)
+	}
+	if err != nil {
+		return err
+	}
+
+	found := ix.Search(config.Op.SearchQuery)
+	if config.Op.JSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(found)
+	}
+	if len(found) == 0 {
+		fmt.Printf("no records match %q\n", config.Op.SearchQuery)
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTYPE\tNAME\tFIELDS")
+	for _, r := range found {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n",
+			r.ID, r.Type, r.Name, strings.Join(r.Fields, ","))
+	}
+	return tw.Flush()
+}
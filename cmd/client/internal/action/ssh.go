@@ -0,0 +1,88 @@
+package action
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/alexey-mavrin/graduate-2/cmd/client/internal/config"
+	"github.com/alexey-mavrin/graduate-2/internal/agent"
+	"github.com/alexey-mavrin/graduate-2/internal/client"
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"github.com/alexey-mavrin/graduate-2/internal/sshkey"
+)
+
+// generateSSHKey returns new ed25519 key, the record name
+// is the key comment by default
+func generateSSHKey(comment string) (common.SSHKey, error) {
+	if comment == "" {
+		comment = config.Op.RecordName
+	}
+	return sshkey.Generate(comment)
+}
+
+// sshKeys returns the SSH key requested by name or ID,
+// or all the SSH keys if none is requested
+func sshKeys(clnt *client.Client, key common.Key) ([]common.SSHKey, error) {
+	var records []common.Record
+	if config.Op.RecordName != "" || config.Op.RecordID != 0 {
+		record, err := getRecord(clnt, key)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	} else {
+		all, err := getRecords(clnt, key, common.SSHRecord)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range all {
+			records = append(records, record)
+		}
+	}
+
+	keys := make([]common.SSHKey, 0, len(records))
+	for _, record := range records {
+		var k common.SSHKey
+		err := json.Unmarshal([]byte(record.Opaque), &k)
+		if err != nil {
+			return nil, fmt.Errorf("SSH key %s: %w", record.Name, err)
+		}
+		if k.Comment == "" {
+			k.Comment = record.Name
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// serveSSHAgent serves the SSH keys decrypted in memory by SSH agent
+// protocol on the Unix socket until interrupted
+func serveSSHAgent(clnt *client.Client, key common.Key) error {
+	keys, err := sshKeys(clnt, key)
+	if err != nil {
+		return err
+	}
+	a, err := sshkey.NewAgent(keys)
+	if err != nil {
+		return err
+	}
+
+	l, err := agent.Listen(config.Op.SSHSocket)
+	if err != nil {
+		return err
+	}
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		l.Close()
+	}()
+
+	fmt.Printf("SSH_AUTH_SOCK=%s; export SSH_AUTH_SOCK;\n", config.Op.SSHSocket)
+	log.Printf("ssh agent is serving %d keys", len(keys))
+	return sshkey.Serve(l, a)
+}
@@ -0,0 +1,88 @@
+package action
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/alexey-mavrin/graduate-2/cmd/client/internal/config"
+	"github.com/alexey-mavrin/graduate-2/internal/client"
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"github.com/alexey-mavrin/graduate-2/internal/otp"
+)
+
+// linkedTOTP returns the TOTP secret linked to the account by name
+func linkedTOTP(clnt *client.Client,
+	key common.Key,
+	account string,
+) (common.TOTP, bool, error) {
+	records, err := getRecords(clnt, key, common.TOTPRecord)
+	if err != nil {
+		return common.TOTP{}, false, err
+	}
+	for id, record := range records {
+		var totp common.TOTP
+		err = json.Unmarshal([]byte(record.Opaque), &totp)
+		if err != nil {
+			log.Printf("cannot unpack TOTP secret %d: %v", id, err)
+			continue
+		}
+		if totp.Account == account {
+			return totp, true, nil
+		}
+	}
+	return common.TOTP{}, false, nil
+}
+
+// printTOTPCode prints the current code of the TOTP secret requested
+// by name or ID, or of the secret linked to the account requested
+func printTOTPCode(clnt *client.Client, key common.Key, account string) error {
+	var totp common.TOTP
+	if config.Op.RecordName == "" && config.Op.RecordID == 0 && account != "" {
+		var found bool
+		var err error
+		totp, found, err = linkedTOTP(clnt, key, account)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("no TOTP secret is linked to account %s", account)
+		}
+	} else {
+		record, err := getRecord(clnt, key)
+		if err != nil {
+			return err
+		}
+		err = json.Unmarshal([]byte(record.Opaque), &totp)
+		if err != nil {
+			return err
+		}
+	}
+
+	code, left, err := otp.Code(totp, time.Now())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s (%d seconds left)\n", code, int(left.Seconds()))
+	return nil
+}
+
+// printLinkedCode prints the current code of the TOTP secret
+// linked to the account if there is one
+func printLinkedCode(clnt *client.Client, key common.Key, account string) {
+	totp, found, err := linkedTOTP(clnt, key, account)
+	if err != nil {
+		log.Printf("cannot get TOTP secret of account %s: %v", account, err)
+		return
+	}
+	if !found {
+		return
+	}
+	code, left, err := otp.Code(totp, time.Now())
+	if err != nil {
+		log.Printf("cannot generate TOTP code of account %s: %v", account, err)
+		return
+	}
+	fmt.Printf("  TOTP code: %s (%d seconds left)\n", code, int(left.Seconds()))
+}
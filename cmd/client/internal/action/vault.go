@@ -0,0 +1,274 @@
+package action
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/alexey-mavrin/graduate-2/cmd/client/internal/config"
+	"github.com/alexey-mavrin/graduate-2/internal/client"
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"github.com/alexey-mavrin/graduate-2/internal/crypt"
+)
+
+// publishKey publishes the user public key derived from the master key
+// to let other users share vaults with the user
+func publishKey(clnt *client.Client, key common.Key) error {
+	pub, _, err := crypt.KeyPair(key)
+	if err != nil {
+		return err
+	}
+	err = clnt.SetPublicKey(hex.EncodeToString(pub[:]))
+	if err != nil {
+		return err
+	}
+	log.Printf("public key is published, fingerprint %s", crypt.Fingerprint(pub))
+	return nil
+}
+
+// vaultKey returns the vault key unwrapped with the user private key
+func vaultKey(clnt *client.Client, vault string) (common.Key, error) {
+	wrapped, err := clnt.GetVaultKey(vault)
+	if err != nil {
+		return common.Key{}, err
+	}
+	pub, priv, err := crypt.KeyPair(*config.Key)
+	if err != nil {
+		return common.Key{}, err
+	}
+	return crypt.UnwrapKey(pub, priv, wrapped)
+}
+
+// memberPublicKey returns the public key of the user. The key published
+// on the server is checked against the fingerprint given or pinned
+// in known_keys, as the server could give its own key to read the vault.
+func memberPublicKey(clnt *client.Client, user, fingerprint string) (common.Key, error) {
+	if user == config.Cfg.UserName {
+		pub, _, err := crypt.KeyPair(*config.Key)
+		return pub, err
+	}
+	pubStr, err := clnt.GetPublicKey(user)
+	if err != nil {
+		return common.Key{}, fmt.Errorf("public key of %s: %w", user, err)
+	}
+	pub, err := crypt.ParsePublicKey(pubStr)
+	if err != nil {
+		return common.Key{}, err
+	}
+	got := crypt.Fingerprint(pub)
+	if fingerprint == "" {
+		fingerprint = config.Cfg.KnownKeys[user]
+	}
+	if fingerprint == "" {
+		return common.Key{}, fmt.Errorf("public key fingerprint of %s is %s, "+
+			"check it with %s and set it with -fingerprint or in known_keys",
+			user, got, user)
+	}
+	if !strings.EqualFold(fingerprint, got) {
+		return common.Key{}, fmt.Errorf("public key fingerprint of %s is %s, "+
+			"not %s: the key is replaced", user, got, fingerprint)
+	}
+	return pub, nil
+}
+
+// wrapKeyFor wraps the key with the public key of the user
+// checked against the fingerprint
+func wrapKeyFor(clnt *client.Client,
+	user string,
+	fingerprint string,
+	key common.Key,
+) (string, error) {
+	pub, err := memberPublicKey(clnt, user, fingerprint)
+	if err != nil {
+		return "", err
+	}
+	return crypt.WrapKey(pub, key)
+}
+
+// vaultRotation generates the new vault key, wraps it for every member
+// but the one removed and re-encrypts all the vault records with it.
+// The records get new data keys as well, since the removed member
+// could have kept the old ones.
+func vaultRotation(clnt *client.Client,
+	vault string,
+	removed string,
+) (_ common.VaultKeyRotation, err error) {
+	defer releaseCache(clnt.HoldCache(), &err)
+
+	rotation := common.VaultKeyRotation{
+		Keys:    make(map[string]string),
+		Records: make(common.Records),
+	}
+	oldKey, err := vaultKey(clnt, vault)
+	if err != nil {
+		return rotation, err
+	}
+	newKey, err := crypt.NewKey()
+	if err != nil {
+		return rotation, err
+	}
+
+	members, err := clnt.ListVaultMembers(vault)
+	if err != nil {
+		return rotation, err
+	}
+	for _, m := range members {
+		if m.Name == removed {
+			continue
+		}
+		rotation.Keys[m.Name], err = wrapKeyFor(clnt, m.Name, "", newKey)
+		if err != nil {
+			return rotation, err
+		}
+	}
+
+	clnt.Vault = vault
+	records, err := clnt.ListRecords()
+	if err != nil {
+		return rotation, err
+	}
+	for id := range records {
+		eRecord, err := clnt.GetRecordByID(id)
+		if err != nil {
+			return rotation, err
+		}
+		b := recordBinding(clnt, id)
+		record, err := crypt.DecryptRecord(oldKey, b, eRecord)
+		if err != nil {
+			return rotation, err
+		}
+		rotation.Records[id], err = crypt.EncryptRecord(newKey, b, record)
+		if err != nil {
+			return rotation, err
+		}
+	}
+	return rotation, nil
+}
+
+// rotateVaultKey replaces the vault key with the new one
+func rotateVaultKey(clnt *client.Client, vault string) error {
+	rotation, err := vaultRotation(clnt, vault, "")
+	if err != nil {
+		return err
+	}
+	return clnt.RotateVaultKey(vault, rotation)
+}
+
+// removeVaultMember removes the member and rotates the vault key.
+// The rotation is prepared before the member is removed, so only
+// the rotation request itself can fail after the removal.
+func removeVaultMember(clnt *client.Client, vault, member string) error {
+	rotation, err := vaultRotation(clnt, vault, member)
+	if err != nil {
+		return fmt.Errorf("vault key rotation: %w, %s is not removed", err, member)
+	}
+	err = clnt.RemoveVaultMember(vault, member)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s is removed from vault %s\n", member, vault)
+	err = clnt.RotateVaultKey(vault, rotation)
+	if err != nil {
+		return fmt.Errorf("vault key is not rotated, %s still holds it: "+
+			"run vault -a rotate -n %s: %w", member, vault, err)
+	}
+	return nil
+}
+
+func actVault(subop config.OpSubtype) error {
+	clnt := newClient()
+	vault := config.Op.Vault
+	switch subop {
+	case config.OpSubtypeVaultCreate:
+		key, err := crypt.NewKey()
+		if err != nil {
+			return err
+		}
+		wrapped, err := wrapKeyFor(clnt, config.Cfg.UserName, "", key)
+		if err != nil {
+			return err
+		}
+		_, err = clnt.CreateVault(vault, wrapped)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("vault %s created\n", vault)
+	case config.OpSubtypeVaultList:
+		vaults, err := clnt.ListVaults()
+		if err != nil {
+			return err
+		}
+		for _, v := range vaults {
+			fmt.Printf("%s (%s)\n", v.Name, v.Role)
+		}
+	case config.OpSubtypeVaultDelete:
+		err := clnt.DeleteVault(vault)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("vault %s deleted\n", vault)
+	case config.OpSubtypeVaultMembers:
+		members, err := clnt.ListVaultMembers(vault)
+		if err != nil {
+			return err
+		}
+		for _, m := range members {
+			fmt.Printf("%s (%s)\n", m.Name, m.Role)
+		}
+	case config.OpSubtypeVaultInvite:
+		key, err := vaultKey(clnt, vault)
+		if err != nil {
+			return err
+		}
+		member := config.Op.VaultMember
+		member.Key, err = wrapKeyFor(clnt,
+			member.Name,
+			config.Op.VaultFingerprint,
+			key,
+		)
+		if err != nil {
+			return err
+		}
+		err = clnt.AddVaultMember(vault, member)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s is invited to vault %s as %s\n",
+			member.Name, vault, member.Role)
+	case config.OpSubtypeVaultRemove:
+		member := config.Op.VaultMember.Name
+		if member == config.Cfg.UserName {
+			// the member leaves the vault and has no access anymore
+			err := clnt.RemoveVaultMember(vault, member)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s is removed from vault %s\n", member, vault)
+			return nil
+		}
+		err := removeVaultMember(clnt, vault, member)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("vault %s key is rotated\n", vault)
+	case config.OpSubtypeVaultRotate:
+		err := rotateVaultKey(clnt, vault)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("vault %s key is rotated\n", vault)
+	case config.OpSubtypeVaultRecords:
+		key, err := vaultKey(clnt, vault)
+		if err != nil {
+			return err
+		}
+		clnt.Vault = vault
+		records, err := clnt.ListRecords()
+		if err != nil {
+			return err
+		}
+		fmt.Println(decryptListed(clnt, key, records))
+	}
+	return nil
+}
@@ -2,9 +2,15 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
+	"path/filepath"
 
+	"github.com/alexey-mavrin/graduate-2/internal/agent"
 	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"github.com/alexey-mavrin/graduate-2/internal/crypt"
+	"github.com/alexey-mavrin/graduate-2/internal/generator"
+	"github.com/alexey-mavrin/graduate-2/internal/urlmatch"
 )
 
 // Key is the encryption key
@@ -19,6 +25,28 @@ type Config struct {
 	CacheFile     string `json:"cache_file"`
 	KeyPhraseFile string `json:"key_phrase_file"`
 	HTTPSInsecure bool   `json:"https_insecure"`
+	// Padding is the padding policy of the record fields:
+	// none, pow2 (default) or block
+	Padding string `json:"padding"`
+	// RejectUnbound makes the records stored with no binding rejected,
+	// it is set once all the records are upgraded
+	RejectUnbound bool `json:"reject_unbound"`
+	// AgentSocket is the unlock agent socket path
+	AgentSocket string `json:"agent_socket"`
+	// SSHAgentSocket is the SSH agent socket path
+	SSHAgentSocket string `json:"ssh_agent_socket"`
+	// Generator is the default policy of the passwords generated
+	Generator generator.Policy `json:"generator"`
+	// SiteRules are the password policies of the sites by host name
+	SiteRules generator.Rules `json:"site_rules"`
+	// URLMatch is the default match of the account URLs found:
+	// domain (default), host or exact
+	URLMatch urlmatch.Level `json:"url_match"`
+	// URLMatchRules are the matches of the sites by host name
+	URLMatchRules urlmatch.Rules `json:"url_match_rules"`
+	// KnownKeys are the public key fingerprints of the vault members
+	// by user name, checked before the vault key is wrapped for them
+	KnownKeys map[string]string `json:"known_keys"`
 }
 
 // Cfg holds global parameters from config file
@@ -37,10 +65,68 @@ func ParseConfigFile(file string) error {
 		return err
 	}
 
-	Key, err = GetKey(Cfg.KeyPhraseFile)
+	crypt.RecordPadding, err = crypt.ParsePadding(Cfg.Padding)
 	if err != nil {
 		return err
 	}
+	crypt.RejectUnbound = Cfg.RejectUnbound
 
 	return nil
 }
+
+// AgentSocket returns the unlock agent socket path
+func AgentSocket() string {
+	if Cfg.AgentSocket != "" {
+		return Cfg.AgentSocket
+	}
+	return agent.DefaultSocket()
+}
+
+// SSHAgentSocket returns the SSH agent socket path: next to
+// the unlock agent socket by default
+func SSHAgentSocket() string {
+	if Cfg.SSHAgentSocket != "" {
+		return Cfg.SSHAgentSocket
+	}
+	return filepath.Join(filepath.Dir(AgentSocket()), "gosecret-ssh.sock")
+}
+
+// URLMatchLevel returns the match of the account URLs
+// required for the site
+func URLMatchLevel(site string) urlmatch.Level {
+	return Cfg.URLMatchRules.ForSite(site, Cfg.URLMatch)
+}
+
+// GeneratorPolicy returns the password policy of the site
+// given by URL or by host name
+func GeneratorPolicy(site string) generator.Policy {
+	return Cfg.SiteRules.ForSite(site, Cfg.Generator)
+}
+
+// LoadSecrets sets the master key from the key phrase file and takes
+// the password from the config file. The secrets not set in the config
+// file are requested from the unlock agent.
+func LoadSecrets() error {
+	var err error
+	if Cfg.KeyPhraseFile != "" {
+		Key, err = GetKey(Cfg.KeyPhraseFile)
+		if err != nil {
+			return err
+		}
+	}
+	if Key != nil && Cfg.Password != "" {
+		return nil
+	}
+
+	key, password, err := agent.NewClient(AgentSocket()).Get()
+	if err != nil {
+		return fmt.Errorf("key phrase file or password is not set, agent: %w", err)
+	}
+	if Key == nil {
+		Key = &key
+	}
+	if Cfg.Password == "" {
+		Cfg.Password = password
+	}
+	return nil
+}
@@ -6,8 +6,15 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/alexey-mavrin/graduate-2/internal/agent"
+	"github.com/alexey-mavrin/graduate-2/internal/audit"
 	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"github.com/alexey-mavrin/graduate-2/internal/generator"
+	"github.com/alexey-mavrin/graduate-2/internal/urlmatch"
 )
 
 type (
@@ -22,14 +29,26 @@ const (
 	OpTypeUser OpType = iota
 	// OpTypeCache is for user operations
 	OpTypeCache
-	// OpTypeAccount is for account operations
-	OpTypeAccount
-	// OpTypeNote is for note operations
-	OpTypeNote
-	// OpTypeCard is for note operations
-	OpTypeCard
-	// OpTypeBinary is for binary operations
-	OpTypeBinary
+	// OpTypeRecord is for the operations on the records of the registered types
+	OpTypeRecord
+	// OpTypeVault is for shared vault operations
+	OpTypeVault
+	// OpTypeAgent is for unlock agent operations
+	OpTypeAgent
+	// OpTypeGen is for password generation
+	OpTypeGen
+	// OpTypeAudit is for the records audit
+	OpTypeAudit
+	// OpTypeRun is for running the command with the secrets in environment
+	OpTypeRun
+	// OpTypeInject is for rendering the template with the secrets
+	OpTypeInject
+	// OpTypeGitCredential is for git credential helper operations
+	OpTypeGitCredential
+	// OpTypeDockerCredential is for Docker credential helper operations
+	OpTypeDockerCredential
+	// OpTypeSearch is for the full-text search over the cached records
+	OpTypeSearch
 )
 
 const (
@@ -39,6 +58,13 @@ const (
 	OpSubtypeUserVerify
 	// OpSubtypeUserPasswordChange is for changing the password
 	OpSubtypeUserPasswordChange
+	// OpSubtypeUserPublishKey is for publishing the user public key
+	OpSubtypeUserPublishKey
+	// OpSubtypeUserRekey is for changing the master key
+	OpSubtypeUserRekey
+	// OpSubtypeUserUpgrade is for re-encrypting the records
+	// of the previous formats
+	OpSubtypeUserUpgrade
 
 	// OpSubtypeCacheSync is the cache sync
 	OpSubtypeCacheSync OpSubtype = iota
@@ -55,37 +81,145 @@ const (
 	OpSubtypeRecordUpdate
 	// OpSubtypeRecordDelete is the removal of the record
 	OpSubtypeRecordDelete
+	// OpSubtypeRecordMove is moving the records to another folder
+	OpSubtypeRecordMove
+	// OpSubtypeRecordRetag is adding and removing the records tags
+	OpSubtypeRecordRetag
+
+	// OpSubtypeVaultCreate is the vault creation
+	OpSubtypeVaultCreate
+	// OpSubtypeVaultList is the listing of the user vaults
+	OpSubtypeVaultList
+	// OpSubtypeVaultDelete is the removal of the vault
+	OpSubtypeVaultDelete
+	// OpSubtypeVaultMembers is the listing of the vault members
+	OpSubtypeVaultMembers
+	// OpSubtypeVaultInvite is the adding of the vault member
+	OpSubtypeVaultInvite
+	// OpSubtypeVaultRemove is the removal of the vault member
+	OpSubtypeVaultRemove
+	// OpSubtypeVaultRotate is the vault key rotation
+	OpSubtypeVaultRotate
+	// OpSubtypeVaultRecords is the listing of all the vault records
+	OpSubtypeVaultRecords
+
+	// OpSubtypeAgentStart is starting the agent in background
+	OpSubtypeAgentStart
+	// OpSubtypeAgentServe is running the agent in foreground
+	OpSubtypeAgentServe
+	// OpSubtypeAgentUnlock is passing the secrets to the agent
+	OpSubtypeAgentUnlock
+	// OpSubtypeAgentLock is wiping the secrets held by the agent
+	OpSubtypeAgentLock
+	// OpSubtypeAgentStatus is checking the agent state
+	OpSubtypeAgentStatus
+	// OpSubtypeAgentStop is stopping the agent
+	OpSubtypeAgentStop
+	// OpSubtypeAuditPasswords is the audit of the account passwords
+	OpSubtypeAuditPasswords
+
+	// OpSubtypeAccountFind is the account lookup by URL
+	OpSubtypeAccountFind
+
+	// OpSubtypeCardExpiring is the listing of the cards about to expire
+	OpSubtypeCardExpiring
+
+	// OpSubtypeTOTPCode is the TOTP code generation
+	OpSubtypeTOTPCode
+
+	// OpSubtypeSSHGenerate is the SSH key generation
+	OpSubtypeSSHGenerate
+	// OpSubtypeSSHAgent is serving the SSH keys by SSH agent protocol
+	OpSubtypeSSHAgent
+
+	// OpSubtypeCredentialGet is the credential lookup by the helper
+	OpSubtypeCredentialGet
+	// OpSubtypeCredentialStore is storing the credential approved
+	OpSubtypeCredentialStore
+	// OpSubtypeCredentialErase is erasing the credential rejected
+	OpSubtypeCredentialErase
+	// OpSubtypeCredentialList is listing the credentials stored
+	OpSubtypeCredentialList
 	// OpSubtypeOther is unknown operation
 	OpSubtypeOther
 )
 
-// RequestedChange indicates if name, opaque or meta of the record
-// is requested to change
+// RequestedChange indicates if name, opaque, meta, folder or tags
+// of the record is requested to change
 type RequestedChange struct {
 	Name   bool
 	Opaque bool
 	Meta   bool
+	Folder bool
+	Tags   bool
 }
 
 // Operation describes the current operation type
 type Operation struct {
-	Op           OpType
-	Subop        OpSubtype
-	User         common.User
-	Account      common.Account
-	accountFlags []string
-	Note         common.Note
-	noteFlags    []string
-	Card         common.Card
-	cardFlags    []string
-	Binary       common.Binary
-	binaryFlags  []string
+	Op    OpType
+	Subop OpSubtype
+	User  common.User
+	// Record is the data of the record of the registered type
+	Record       common.Opaque
+	stdin        bool
 	RecordChange RequestedChange
 	RecordID     int64
 	RecordName   string
 	RecordMeta   string
 	RecordType   common.RecordType
+	// RecordFolder and RecordTags are set on store and update,
+	// the records are listed, moved and retagged by them otherwise
+	RecordFolder string
+	RecordTags   []string
+	MoveTo       string
+	AddTags      []string
+	RemoveTags   []string
 	FileName     string
+	Vault        string
+	VaultMember  common.VaultMember
+	NewKeyFile   string
+	AgentTimeout time.Duration
+	GenPassword  bool
+	GenPolicy    generator.Policy
+	JSON         bool
+
+	// VaultFingerprint is the public key fingerprint of the member invited
+	VaultFingerprint string
+
+	AuditDays       int
+	AuditMinEntropy float64
+	AuditHIBP       string
+	ExpiringDays    int
+	Reveal          bool
+	LinkedCode      bool
+	Force           bool
+	SSHSocket       string
+
+	RunVars    []string
+	RunEnvFile string
+	RunMask    bool
+	RunCommand []string
+
+	InjectInput  string
+	InjectOutput string
+	CacheOnly    bool
+
+	SearchQuery   string
+	SearchSecrets bool
+
+	URLMatch urlmatch.Level
+}
+
+// varFlags is the repeatable NAME=VALUE flag
+type varFlags []string
+
+func (v *varFlags) String() string {
+	return fmt.Sprint(*v)
+}
+
+func (v *varFlags) Set(value string) error {
+	*v = append(*v, value)
+	return nil
 }
 
 func isFlagPassed(set *flag.FlagSet, name string) bool {
@@ -101,6 +235,10 @@ func isFlagPassed(set *flag.FlagSet, name string) bool {
 // Op describes the current operation
 var Op Operation
 
+// DockerHelperName is the client binary name to run as Docker
+// credential helper
+const DockerHelperName = "docker-credential-gosecret"
+
 // ErrUnknownMode returned when unknown operation mode is requested
 var ErrUnknownMode = errors.New("unknown mode")
 
@@ -110,7 +248,13 @@ func Usage(msg string) {
 		fmt.Println(msg)
 	}
 	fmt.Println("usage: 'client MODE -a ACTION flags'")
-	fmt.Println("  where MODE is one of user, cache, acc, note, card or bin")
+	modes := append([]string{"user", "cache", "vault", "agent", "gen", "audit",
+		"run", "inject", "search", "git-credential", "docker-credential",
+	}, RecordModes()...)
+	fmt.Printf("  where MODE is one of %s or %s\n",
+		strings.Join(modes[:len(modes)-1], ", "),
+		modes[len(modes)-1],
+	)
 	fmt.Println("  run 'client MODE -h' for further help")
 }
 
@@ -123,91 +267,189 @@ func checkChanges(set *flag.FlagSet, opaqueFlags []string) RequestedChange {
 		}
 	}
 	r.Meta = isFlagPassed(set, "m")
+	r.Folder = isFlagPassed(set, "folder")
+	r.Tags = isFlagPassed(set, "tag")
 	return r
 }
 
-func actionType(a *string) OpSubtype {
-	switch *a {
-	case "store":
-		return OpSubtypeRecordStore
+// labelFlags are the folder and tags flags of the record modes
+type labelFlags struct {
+	folder *string
+	tags   varFlags
+	to     *string
+	add    varFlags
+	remove varFlags
+}
+
+// newLabelFlags defines the folder and tags flags of the record mode
+func newLabelFlags(set *flag.FlagSet) *labelFlags {
+	l := &labelFlags{}
+	l.folder = set.String("folder",
+		"",
+		"record folder, e.g. work/db; the folder to list, move or retag",
+	)
+	set.Var(&l.tags,
+		"tag",
+		"record tag; the tag to list, move or retag by, repeatable",
+	)
+	l.to = set.String("to", "", "folder to move the records to")
+	set.Var(&l.add, "add", "tag to add on retag, repeatable")
+	set.Var(&l.remove, "rm", "tag to remove on retag, repeatable")
+	return l
+}
+
+// parseLabels sets the folder and tags of the operation and checks
+// the records to move or retag are selected
+func parseLabels(set *flag.FlagSet, l *labelFlags) error {
+	Op.RecordFolder = common.CleanFolder(*l.folder)
+	Op.RecordTags = common.CleanTags(l.tags)
+	Op.MoveTo = common.CleanFolder(*l.to)
+	Op.AddTags = common.CleanTags(l.add)
+	Op.RemoveTags = common.CleanTags(l.remove)
+	if Op.Subop != OpSubtypeRecordMove && Op.Subop != OpSubtypeRecordRetag {
+		return nil
+	}
+	if Op.Subop == OpSubtypeRecordMove && !isFlagPassed(set, "to") {
+		return errors.New("folder to move to is not set")
+	}
+	if Op.Subop == OpSubtypeRecordRetag &&
+		len(Op.AddTags) == 0 && len(Op.RemoveTags) == 0 {
+		return errors.New("tags to add or remove are not set")
+	}
+	if Op.RecordID == 0 && Op.RecordName == "" &&
+		Op.RecordFolder == "" && len(Op.RecordTags) == 0 {
+		return errors.New("records are not selected: set -i, -n, -folder or -tag")
+	}
+	return nil
+}
+
+// credentialAction returns the credential helper operation,
+// the action is the argument appended by the tool calling the helper
+func credentialAction(a string) (OpSubtype, error) {
+	switch a {
 	case "get":
-		return OpSubtypeRecordGet
+		return OpSubtypeCredentialGet, nil
+	case "store":
+		return OpSubtypeCredentialStore, nil
+	case "erase":
+		return OpSubtypeCredentialErase, nil
 	case "list":
-		return OpSubtypeRecordList
-	case "update":
-		return OpSubtypeRecordUpdate
-	case "delete":
-		return OpSubtypeRecordDelete
+		return OpSubtypeCredentialList, nil
 	}
-	return OpSubtypeOther
+	return OpSubtypeOther, fmt.Errorf("unknown credential helper action %q", a)
 }
 
 // ParseFlags parses cmd line arguments
 func ParseFlags() error {
 	userFlags := flag.NewFlagSet("user", flag.ExitOnError)
 	cacheFlags := flag.NewFlagSet("cache", flag.ExitOnError)
-	accFlags := flag.NewFlagSet(string(common.AccountRecord), flag.ExitOnError)
-	noteFlags := flag.NewFlagSet(string(common.NoteRecord), flag.ExitOnError)
-	cardFlags := flag.NewFlagSet(string(common.CardRecord), flag.ExitOnError)
-	binFlags := flag.NewFlagSet(string(common.BinaryRecord), flag.ExitOnError)
-
-	userAction := userFlags.String("a", "verify", "action: verify|register|password")
-	userPass := userFlags.String("p", "", "new password")
+	vaultFlags := flag.NewFlagSet("vault", flag.ExitOnError)
+	agentFlags := flag.NewFlagSet("agent", flag.ExitOnError)
+	genFlags := flag.NewFlagSet("gen", flag.ExitOnError)
+	auditFlags := flag.NewFlagSet("audit", flag.ExitOnError)
+	runFlags := flag.NewFlagSet("run", flag.ExitOnError)
+	injectFlags := flag.NewFlagSet("inject", flag.ExitOnError)
+	gitCredFlags := flag.NewFlagSet("git-credential", flag.ExitOnError)
+	dockerCredFlags := flag.NewFlagSet("docker-credential", flag.ExitOnError)
+	searchFlags := flag.NewFlagSet("search", flag.ExitOnError)
+
+	userAction := userFlags.String("a",
+		"verify",
+		"action: verify|register|password|key|rekey|upgrade",
+	)
+	userPass := userFlags.String("p", "", "new password, - to read from stdin")
+	userKeyFile := userFlags.String("k", "", "new key phrase file")
 
 	cacheAction := cacheFlags.String("a", "sync", "action: sync|clean")
 
-	accAction := accFlags.String("a",
+	vaultAction := vaultFlags.String("a",
 		"list",
-		"action: list|store|get|update|delete",
+		"action: list|create|delete|members|invite|remove|rotate|records",
+	)
+	vaultName := vaultFlags.String("n", "", "vault name")
+	vaultMember := vaultFlags.String("u", "", "vault member user name")
+	vaultRole := vaultFlags.String("r",
+		string(common.VaultReader),
+		"vault member role: owner|admin|writer|reader",
+	)
+	vaultFingerprint := vaultFlags.String("fingerprint",
+		"",
+		"public key fingerprint of the member invited",
 	)
-	accName := accFlags.String("n", "", "account name")
-	// opaque flags
-	accUserName := accFlags.String("u", "", "account user name")
-	accPassword := accFlags.String("p", "", "account password")
-	accURL := accFlags.String("l", "", "account URL")
-	Op.accountFlags = []string{"u", "p", "l"}
 
-	accMeta := accFlags.String("m", "", "account metainfo")
-	accID := accFlags.Int64("i", 0, "account ID")
+	agentAction := agentFlags.String("a",
+		"status",
+		"action: start|serve|unlock|lock|status|stop",
+	)
+	agentTimeout := agentFlags.Duration("t",
+		agent.DefaultIdleTimeout,
+		"idle timeout to lock the agent after",
+	)
 
-	noteAction := noteFlags.String("a",
-		"list",
-		"action: list|store|get|update|delete",
+	genLength := genFlags.Int("len",
+		generator.DefaultLength,
+		"password length",
+	)
+	genClasses := genFlags.String("c",
+		generator.DefaultClasses,
+		"character classes: l - lower, u - upper, d - digits, s - symbols",
+	)
+	genNoAmbiguous := genFlags.Bool("x", false, "exclude ambiguous characters")
+	genExclude := genFlags.String("e", "", "characters to exclude")
+	genWords := genFlags.Int("w", 0, "number of passphrase words")
+	genSeparator := genFlags.String("sep",
+		generator.DefaultSeparator,
+		"passphrase words separator",
 	)
-	noteName := noteFlags.String("n", "", "note name")
-	// opaque flags
-	noteText := noteFlags.String("t", "", "note text")
-	Op.noteFlags = []string{"t"}
+	genSite := genFlags.String("site", "", "site URL to apply the site rule of")
 
-	noteMeta := noteFlags.String("m", "", "note metainfo")
-	noteID := noteFlags.Int64("i", 0, "note ID")
+	auditAction := auditFlags.String("a", "passwords", "action: passwords")
+	auditDays := auditFlags.Int("days",
+		audit.DefaultMaxAgeDays,
+		"days to rotate the passwords within, 0 to skip the check",
+	)
+	auditMinEntropy := auditFlags.Float64("min",
+		audit.DefaultMinEntropy,
+		"password entropy in bits considered weak below",
+	)
+	auditHIBP := auditFlags.String("hibp",
+		"",
+		"HIBP SHA-1 passwords file or range files directory",
+	)
+	auditJSON := auditFlags.Bool("json", false, "print the report as JSON")
+	auditVault := auditFlags.String("vault", "", "shared vault name")
 
-	cardAction := cardFlags.String("a",
-		"list",
-		"action: list|store|get|update|delete",
+	var runVars varFlags
+	runFlags.Var(&runVars,
+		"e",
+		"NAME=VALUE variable, VALUE may be secret://TYPE/NAME/FIELD, repeatable",
 	)
-	cardName := cardFlags.String("n", "", "card name")
-	// opaque flags
-	cardHolder := cardFlags.String("ch", "", "card holder")
-	cardNumber := cardFlags.String("num", "", "card number")
-	cardExpMonth := cardFlags.Int("em", 0, "card expiry month")
-	cardExpYear := cardFlags.Int("ey", 0, "card expiry year")
-	cardCVC := cardFlags.String("c", "", "card CVC code")
-	Op.cardFlags = []string{"ch", "num", "em", "ey", "c"}
-
-	cardMeta := cardFlags.String("m", "", "card metainfo")
-	cardID := cardFlags.Int64("i", 0, "card ID")
-
-	binAction := binFlags.String("a",
-		"list",
-		"action: list|store|get|update|delete",
+	runEnvFile := runFlags.String("env-file", "", ".env template file")
+	runMask := runFlags.Bool("mask", false, "mask the secrets in the command output")
+	runVault := runFlags.String("vault", "", "shared vault name")
+
+	injectInput := injectFlags.String("i", "", "template file")
+	injectOutput := injectFlags.String("o", "", "output file, stdout if not set")
+	injectCacheOnly := injectFlags.Bool("cache-only",
+		false,
+		"read the records from the local cache without contacting the server",
 	)
-	binName := binFlags.String("n", "", "binary record name")
-	// opaque flags
-	binFile := binFlags.String("f", "", "file name")
-	Op.binaryFlags = []string{"f"}
+	injectVault := injectFlags.String("vault", "", "shared vault name")
 
-	binID := binFlags.Int64("i", 0, "binary record ID")
+	gitCredVault := gitCredFlags.String("vault", "", "shared vault name")
+	dockerCredVault := dockerCredFlags.String("vault", "", "shared vault name")
+
+	searchQuery := searchFlags.String("q", "", "words to find, the rest arguments if not set")
+	searchSecrets := searchFlags.Bool("secrets",
+		false,
+		"search the secret fields too: passwords, card numbers, keys",
+	)
+	searchJSON := searchFlags.Bool("json", false, "print the records found as JSON")
+
+	// Docker runs the helper docker-credential-NAME with the action only
+	if filepath.Base(os.Args[0]) == DockerHelperName {
+		os.Args = append([]string{os.Args[0], "docker-credential"}, os.Args[1:]...)
+	}
 
 	if len(os.Args) < 2 {
 		return errors.New("mode is not set")
@@ -218,16 +460,30 @@ func ParseFlags() error {
 		userFlags.Parse(os.Args[2:])
 	case "cache":
 		cacheFlags.Parse(os.Args[2:])
-	case string(common.AccountRecord):
-		accFlags.Parse(os.Args[2:])
-	case string(common.NoteRecord):
-		noteFlags.Parse(os.Args[2:])
-	case string(common.CardRecord):
-		cardFlags.Parse(os.Args[2:])
-	case string(common.BinaryRecord):
-		binFlags.Parse(os.Args[2:])
+	case "vault":
+		vaultFlags.Parse(os.Args[2:])
+	case "agent":
+		agentFlags.Parse(os.Args[2:])
+	case "gen":
+		genFlags.Parse(os.Args[2:])
+	case "audit":
+		auditFlags.Parse(os.Args[2:])
+	case "run":
+		runFlags.Parse(os.Args[2:])
+	case "inject":
+		injectFlags.Parse(os.Args[2:])
+	case "git-credential":
+		gitCredFlags.Parse(os.Args[2:])
+	case "docker-credential":
+		dockerCredFlags.Parse(os.Args[2:])
+	case "search":
+		searchFlags.Parse(os.Args[2:])
 	default:
-		return ErrUnknownMode
+		mode, ok := recordModes[common.RecordType(os.Args[1])]
+		if !ok {
+			return ErrUnknownMode
+		}
+		return parseRecordMode(mode, os.Args[2:])
 	}
 
 	if userFlags.Parsed() {
@@ -239,10 +495,30 @@ func ParseFlags() error {
 			Op.Subop = OpSubtypeUserRegister
 		case "password":
 			Op.Subop = OpSubtypeUserPasswordChange
+		case "key":
+			Op.Subop = OpSubtypeUserPublishKey
+		case "rekey":
+			Op.Subop = OpSubtypeUserRekey
+		case "upgrade":
+			Op.Subop = OpSubtypeUserUpgrade
 		default:
 			return errors.New("unknown user action")
 		}
-		Op.User.Password = *userPass
+		var err error
+		Op.User.Password, err = secretValue(userFlags,
+			"p",
+			*userPass,
+			"new password",
+			Op.Subop == OpSubtypeUserPasswordChange,
+			true,
+		)
+		if err != nil {
+			return err
+		}
+		Op.NewKeyFile = *userKeyFile
+		if Op.Subop == OpSubtypeUserRekey && Op.NewKeyFile == "" {
+			return errors.New("new key phrase file is not set")
+		}
 	} else if cacheFlags.Parsed() {
 		Op.Op = OpTypeCache
 		switch *cacheAction {
@@ -251,63 +527,161 @@ func ParseFlags() error {
 		case "clean":
 			Op.Subop = OpSubtypeCacheClean
 		}
-	} else if accFlags.Parsed() {
-		Op.Op = OpTypeAccount
-		Op.RecordType = common.AccountRecord
-		Op.Subop = actionType(accAction)
-
-		Op.RecordName = *accName
-		Op.Account.UserName = *accUserName
-		Op.Account.Password = *accPassword
-		Op.Account.URL = *accURL
-		Op.RecordMeta = *accMeta
-		Op.RecordID = *accID
-		Op.RecordChange = checkChanges(accFlags, Op.accountFlags)
-	} else if noteFlags.Parsed() {
-		Op.Op = OpTypeNote
-		Op.RecordType = common.NoteRecord
-		Op.Subop = actionType(noteAction)
-
-		Op.RecordName = *noteName
-		Op.Note.Text = *noteText
-		Op.RecordMeta = *noteMeta
-		Op.RecordID = *noteID
-		Op.RecordChange = checkChanges(noteFlags, Op.noteFlags)
-	} else if cardFlags.Parsed() {
-		Op.Op = OpTypeCard
-		Op.RecordType = common.CardRecord
-		Op.Subop = actionType(cardAction)
-
-		Op.RecordName = *cardName
-		Op.Card.Holder = *cardHolder
-		Op.Card.Number = *cardNumber
-		Op.Card.ExpMonth = *cardExpMonth
-		Op.Card.ExpYear = *cardExpYear
-		Op.Card.CVC = *cardCVC
-		Op.RecordMeta = *cardMeta
-		Op.RecordID = *cardID
-		Op.RecordChange = checkChanges(cardFlags, Op.cardFlags)
-	} else if binFlags.Parsed() {
-		Op.Op = OpTypeBinary
-		Op.RecordType = common.BinaryRecord
-		Op.Subop = actionType(binAction)
-
-		Op.RecordName = *binName
+	} else if vaultFlags.Parsed() {
+		Op.Op = OpTypeVault
+		switch *vaultAction {
+		case "list":
+			Op.Subop = OpSubtypeVaultList
+		case "create":
+			Op.Subop = OpSubtypeVaultCreate
+		case "delete":
+			Op.Subop = OpSubtypeVaultDelete
+		case "members":
+			Op.Subop = OpSubtypeVaultMembers
+		case "invite":
+			Op.Subop = OpSubtypeVaultInvite
+		case "remove":
+			Op.Subop = OpSubtypeVaultRemove
+		case "rotate":
+			Op.Subop = OpSubtypeVaultRotate
+		case "records":
+			Op.Subop = OpSubtypeVaultRecords
+		default:
+			return errors.New("unknown vault action")
+		}
+		Op.Vault = *vaultName
+		Op.VaultMember.Name = *vaultMember
+		Op.VaultMember.Role = common.VaultRole(*vaultRole)
+		Op.VaultFingerprint = *vaultFingerprint
+		if Op.Subop != OpSubtypeVaultList && Op.Vault == "" {
+			return errors.New("vault name is not set")
+		}
+		if (Op.Subop == OpSubtypeVaultInvite || Op.Subop == OpSubtypeVaultRemove) &&
+			Op.VaultMember.Name == "" {
+			return errors.New("vault member is not set")
+		}
+		if !Op.VaultMember.Role.Valid() {
+			return errors.New("unknown vault role")
+		}
+	} else if agentFlags.Parsed() {
+		Op.Op = OpTypeAgent
+		switch *agentAction {
+		case "start":
+			Op.Subop = OpSubtypeAgentStart
+		case "serve":
+			Op.Subop = OpSubtypeAgentServe
+		case "unlock":
+			Op.Subop = OpSubtypeAgentUnlock
+		case "lock":
+			Op.Subop = OpSubtypeAgentLock
+		case "status":
+			Op.Subop = OpSubtypeAgentStatus
+		case "stop":
+			Op.Subop = OpSubtypeAgentStop
+		default:
+			return errors.New("unknown agent action")
+		}
+		Op.AgentTimeout = *agentTimeout
+	} else if genFlags.Parsed() {
+		Op.Op = OpTypeGen
+		Op.GenPolicy = GeneratorPolicy(*genSite)
+		if isFlagPassed(genFlags, "len") {
+			Op.GenPolicy.Length = *genLength
+		}
+		if isFlagPassed(genFlags, "c") {
+			Op.GenPolicy.Classes = *genClasses
+		}
+		if isFlagPassed(genFlags, "x") {
+			Op.GenPolicy.NoAmbiguous = *genNoAmbiguous
+		}
+		if isFlagPassed(genFlags, "e") {
+			Op.GenPolicy.Exclude = *genExclude
+		}
+		if isFlagPassed(genFlags, "w") {
+			Op.GenPolicy.Words = *genWords
+		}
+		if isFlagPassed(genFlags, "sep") {
+			Op.GenPolicy.Separator = *genSeparator
+		}
+	} else if auditFlags.Parsed() {
+		Op.Op = OpTypeAudit
+		switch *auditAction {
+		case "passwords":
+			Op.Subop = OpSubtypeAuditPasswords
+		default:
+			return errors.New("unknown audit action")
+		}
+		Op.AuditDays = *auditDays
+		Op.AuditMinEntropy = *auditMinEntropy
+		Op.AuditHIBP = *auditHIBP
+		Op.JSON = *auditJSON
+		Op.Vault = *auditVault
+	} else if runFlags.Parsed() {
+		Op.Op = OpTypeRun
+		Op.RunVars = runVars
+		Op.RunEnvFile = *runEnvFile
+		Op.RunMask = *runMask
+		Op.Vault = *runVault
+		Op.RunCommand = runFlags.Args()
+		if len(Op.RunCommand) == 0 {
+			return errors.New("command to run is not set")
+		}
+	} else if injectFlags.Parsed() {
+		Op.Op = OpTypeInject
+		Op.InjectInput = *injectInput
+		Op.InjectOutput = *injectOutput
+		Op.CacheOnly = *injectCacheOnly
+		Op.Vault = *injectVault
+		if Op.InjectInput == "" {
+			return errors.New("template file is not set")
+		}
+	} else if gitCredFlags.Parsed() {
+		Op.Op = OpTypeGitCredential
+		Op.Vault = *gitCredVault
 		var err error
-		if Op.Subop == OpSubtypeRecordStore || Op.Subop == OpSubtypeRecordUpdate {
-			Op.Binary.Data, err = readEncodeFile(*binFile)
+		Op.Subop, err = credentialAction(gitCredFlags.Arg(0))
+		if err != nil {
+			return err
 		}
-		Op.FileName = *binFile
+		if Op.Subop == OpSubtypeCredentialList {
+			return errors.New("list is not a git credential helper action")
+		}
+	} else if dockerCredFlags.Parsed() {
+		Op.Op = OpTypeDockerCredential
+		Op.Vault = *dockerCredVault
+		var err error
+		Op.Subop, err = credentialAction(dockerCredFlags.Arg(0))
 		if err != nil {
 			return err
 		}
-		Op.RecordID = *binID
-		Op.RecordChange = checkChanges(binFlags, Op.binaryFlags)
+	} else if searchFlags.Parsed() {
+		Op.Op = OpTypeSearch
+		Op.SearchQuery = *searchQuery
+		if Op.SearchQuery == "" {
+			Op.SearchQuery = strings.Join(searchFlags.Args(), " ")
+		}
+		Op.SearchSecrets = *searchSecrets
+		Op.JSON = *searchJSON
+		if Op.SearchQuery == "" {
+			return errors.New("search query is not set")
+		}
 	}
 
 	return nil
 }
 
+// mergeStdinRecord reads the record from stdin over the flags values
+func mergeStdinRecord(data interface{}) error {
+	change, err := readStdinRecord(data)
+	if err != nil {
+		return err
+	}
+	Op.RecordChange.Name = Op.RecordChange.Name || change.Name
+	Op.RecordChange.Opaque = Op.RecordChange.Opaque || change.Opaque
+	Op.RecordChange.Meta = Op.RecordChange.Meta || change.Meta
+	return nil
+}
+
 func readEncodeFile(file string) (string, error) {
 	data, err := os.ReadFile(file)
 	if err != nil {
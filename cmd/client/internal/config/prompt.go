@@ -0,0 +1,128 @@
+package config
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// stdinValue is the flag value to read the secret from stdin
+const stdinValue = "-"
+
+// stdin is shared by all the values read from stdin
+var stdin = bufio.NewReader(os.Stdin)
+
+// ReadSecret prompts for the secret on the terminal
+// and reads it without echo
+func ReadSecret(prompt string) (string, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", fmt.Errorf("cannot prompt for %s: %w", prompt, err)
+	}
+	defer tty.Close()
+
+	fmt.Fprintf(tty, "%s: ", prompt)
+	buf, err := term.ReadPassword(int(tty.Fd()))
+	fmt.Fprintln(tty)
+	if err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// ReadNewSecret prompts for the new secret twice
+// and checks the secrets entered match
+func ReadNewSecret(prompt string) (string, error) {
+	secret, err := ReadSecret(prompt)
+	if err != nil {
+		return "", err
+	}
+	again, err := ReadSecret("repeat " + prompt)
+	if err != nil {
+		return "", err
+	}
+	if secret != again {
+		return "", errors.New("secrets entered do not match")
+	}
+	return secret, nil
+}
+
+// readStdinLine reads the line from stdin without the line ending
+func readStdinLine() (string, error) {
+	line, err := stdin.ReadString('\n')
+	if err != nil && !(err == io.EOF && line != "") {
+		return "", fmt.Errorf("read stdin: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// secretValue returns the value of the secret flag. The value "-" means
+// the secret is read from stdin, with no-echo prompt if stdin is the
+// terminal. The omitted secret is prompted if required.
+// The new secrets are prompted twice to confirm.
+func secretValue(set *flag.FlagSet,
+	name string,
+	value string,
+	prompt string,
+	required bool,
+	isNew bool,
+) (string, error) {
+	read := ReadSecret
+	if isNew {
+		read = ReadNewSecret
+	}
+	if value == stdinValue {
+		if term.IsTerminal(int(os.Stdin.Fd())) {
+			return read(prompt)
+		}
+		return readStdinLine()
+	}
+	if required && !isFlagPassed(set, name) {
+		return read(prompt)
+	}
+	return value, nil
+}
+
+// stdinRecord is the record piped in as JSON. Data holds
+// the record type specific fields, e.g. url, user_name and password
+// for the account.
+type stdinRecord struct {
+	Name string          `json:"name"`
+	Meta *string         `json:"meta"`
+	Data json.RawMessage `json:"data"`
+}
+
+// readStdinRecord reads the record from stdin, unmarshals the record
+// type specific fields into data and sets the operation record name
+// and meta if they are present
+func readStdinRecord(data interface{}) (RequestedChange, error) {
+	var change RequestedChange
+	var r stdinRecord
+	err := json.NewDecoder(stdin).Decode(&r)
+	if err != nil {
+		return change, fmt.Errorf("read record from stdin: %w", err)
+	}
+	if r.Name != "" {
+		Op.RecordName = r.Name
+		change.Name = true
+	}
+	if r.Meta != nil {
+		Op.RecordMeta = *r.Meta
+		change.Meta = true
+	}
+	if len(r.Data) != 0 {
+		err = json.Unmarshal(r.Data, data)
+		if err != nil {
+			return change, fmt.Errorf("record data: %w", err)
+		}
+		change.Opaque = true
+	}
+	return change, nil
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"bufio"
+	"flag"
+	"strings"
+	"testing"
+
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setStdin(t *testing.T, s string) {
+	saved := stdin
+	stdin = bufio.NewReader(strings.NewReader(s))
+	t.Cleanup(func() { stdin = saved })
+}
+
+func Test_secretValue(t *testing.T) {
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	p := set.String("p", "", "password")
+	c := set.String("c", "", "code")
+	require.NoError(t, set.Parse([]string{"-p", "-", "-c", "123"}))
+
+	setStdin(t, "pass from stdin\r\nnext line\n")
+
+	value, err := secretValue(set, "p", *p, "password", true, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "pass from stdin", value)
+
+	value, err = secretValue(set, "c", *c, "code", true, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "123", value)
+
+	// the omitted secret is not prompted if not required
+	value, err = secretValue(set, "x", "", "other", false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "", value)
+}
+
+func Test_readStdinRecord(t *testing.T) {
+	saved := Op
+	defer func() { Op = saved }()
+
+	setStdin(t, `{"name":"acc1","meta":"",`+
+		`"data":{"url":"http://example.com","user_name":"user","password":"pass"}}`)
+
+	var account common.Account
+	change, err := readStdinRecord(&account)
+	assert.NoError(t, err)
+	assert.Equal(t, RequestedChange{Name: true, Opaque: true, Meta: true}, change)
+	assert.Equal(t, "acc1", Op.RecordName)
+	assert.Equal(t, common.Account{
+		URL:      "http://example.com",
+		UserName: "user",
+		Password: "pass",
+	}, account)
+
+	// the fields missing are not changed
+	setStdin(t, `{"data":{"text":"note text"}}`)
+	var note common.Note
+	change, err = readStdinRecord(&note)
+	assert.NoError(t, err)
+	assert.Equal(t, RequestedChange{Opaque: true}, change)
+	assert.Equal(t, "note text", note.Text)
+
+	setStdin(t, `not a json`)
+	_, err = readStdinRecord(&note)
+	assert.Error(t, err)
+}
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+)
+
+// recordActions are the actions of all the record modes
+var recordActions = []modeAction{
+	{"list", OpSubtypeRecordList},
+	{"store", OpSubtypeRecordStore},
+	{"get", OpSubtypeRecordGet},
+	{"update", OpSubtypeRecordUpdate},
+	{"delete", OpSubtypeRecordDelete},
+	{"move", OpSubtypeRecordMove},
+	{"retag", OpSubtypeRecordRetag},
+}
+
+// modeAction is the action of the record mode
+type modeAction struct {
+	name  string
+	subop OpSubtype
+}
+
+// recordMode is the client mode of the registered record type.
+// The record flags common to the modes are defined for each mode:
+// action, name, ID and metainfo, the vault, folder and tags flags
+// unless the records are personal, and stdin if the mode reads
+// the record from stdin.
+type recordMode struct {
+	recordType common.RecordType
+	// actions are the mode actions in addition to the record ones
+	actions []modeAction
+	// personal records are not stored in the vaults and have no labels
+	personal bool
+	// stdin is true if the record can be read from stdin as JSON
+	stdin bool
+	// opaque are the names of the record data flags
+	opaque []string
+	// define defines the record data flags. The function returned
+	// gets the record data from the flags parsed, the common record
+	// flags are already set in the operation.
+	define func(set *flag.FlagSet) func() (common.Opaque, error)
+}
+
+var recordModes = make(map[common.RecordType]recordMode)
+
+// registerRecordMode adds the client mode of the registered record type
+func registerRecordMode(m recordMode) {
+	if !m.recordType.Valid() {
+		panic("config: record type is not registered: " + m.recordType)
+	}
+	if _, dup := recordModes[m.recordType]; dup {
+		panic("config: record mode registered twice: " + m.recordType)
+	}
+	recordModes[m.recordType] = m
+}
+
+// RecordModes returns the names of the record modes
+// in the record types registration order
+func RecordModes() []string {
+	var modes []string
+	for _, t := range common.RecordTypes() {
+		if _, ok := recordModes[t]; ok {
+			modes = append(modes, string(t))
+		}
+	}
+	return modes
+}
+
+// title returns the name of the mode records used in the flag help
+func (m recordMode) title() string {
+	info, _ := common.LookupType(m.recordType)
+	return info.Title
+}
+
+// modeActions returns all the actions of the mode
+func (m recordMode) modeActions() []modeAction {
+	var actions []modeAction
+	for _, a := range recordActions {
+		if m.personal && (a.subop == OpSubtypeRecordMove || a.subop == OpSubtypeRecordRetag) {
+			continue
+		}
+		actions = append(actions, a)
+	}
+	return append(actions, m.actions...)
+}
+
+// actionType returns the operation subtype of the mode action
+func (m recordMode) actionType(action string) OpSubtype {
+	for _, a := range m.modeActions() {
+		if a.name == action {
+			return a.subop
+		}
+	}
+	return OpSubtypeOther
+}
+
+// parseRecordMode parses the record mode flags into the operation
+func parseRecordMode(m recordMode, args []string) error {
+	title := m.title()
+	set := flag.NewFlagSet(string(m.recordType), flag.ExitOnError)
+
+	var names []string
+	for _, a := range m.modeActions() {
+		names = append(names, a.name)
+	}
+	action := set.String("a", "list", "action: "+strings.Join(names, "|"))
+	name := set.String("n", "", title+" name")
+	// opaque flags
+	parse := m.define(set)
+
+	meta := set.String("m", "", title+" metainfo")
+	id := set.Int64("i", 0, title+" ID")
+	var vault *string
+	var labels *labelFlags
+	if !m.personal {
+		vault = set.String("vault", "", "shared vault name")
+		labels = newLabelFlags(set)
+	}
+	var stdin *bool
+	if m.stdin {
+		stdin = set.Bool("stdin", false, "read JSON record from stdin")
+	}
+	set.Parse(args)
+
+	Op.Op = OpTypeRecord
+	Op.RecordType = m.recordType
+	Op.Subop = m.actionType(*action)
+	if Op.Subop == OpSubtypeOther {
+		return fmt.Errorf("unknown %s action %s", m.recordType, *action)
+	}
+	Op.RecordName = *name
+	Op.RecordMeta = *meta
+	Op.RecordID = *id
+	Op.RecordChange = checkChanges(set, m.opaque)
+	if !m.personal {
+		Op.Vault = *vault
+		if err := parseLabels(set, labels); err != nil {
+			return err
+		}
+	}
+	Op.stdin = stdin != nil && *stdin
+
+	var err error
+	Op.Record, err = parse()
+	return err
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordModes(t *testing.T) {
+	modes := RecordModes()
+	for _, recordType := range common.RecordTypes() {
+		assert.Contains(t, modes, string(recordType))
+	}
+
+	acc := recordModes[common.AccountRecord]
+	assert.Equal(t, OpSubtypeRecordStore, acc.actionType("store"))
+	assert.Equal(t, OpSubtypeAccountFind, acc.actionType("find"))
+	assert.Equal(t, OpSubtypeOther, acc.actionType("code"))
+
+	schema := recordModes[common.SchemaRecord]
+	assert.Equal(t, OpSubtypeOther, schema.actionType("move"))
+
+	assert.Panics(t, func() { registerRecordMode(acc) })
+	assert.Panics(t, func() { registerRecordMode(recordMode{recordType: "blob"}) })
+}
+
+func Test_parseRecordMode(t *testing.T) {
+	saved := Op
+	t.Cleanup(func() { Op = saved })
+
+	err := parseRecordMode(recordModes[common.NoteRecord], []string{
+		"-a", "update", "-n", "wifi", "-t", "guest network", "-tag", "home",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, OpTypeRecord, Op.Op)
+	assert.Equal(t, OpSubtypeRecordUpdate, Op.Subop)
+	assert.Equal(t, common.NoteRecord, Op.RecordType)
+	assert.Equal(t, "wifi", Op.RecordName)
+	assert.Equal(t, common.Note{Text: "guest network"}, Op.Record)
+	assert.Equal(t, []string{"home"}, Op.RecordTags)
+	assert.Equal(t, RequestedChange{Name: true, Opaque: true, Tags: true},
+		Op.RecordChange)
+
+	setStdin(t, `{"name":"db","data":{"fields":[{"name":"host","value":"db1"}]}}`)
+	err = parseRecordMode(recordModes[common.GenericRecord], []string{
+		"-a", "store", "-stdin", "-schema", "database",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "db", Op.RecordName)
+	assert.Equal(t, common.Generic{
+		Schema: "database",
+		Fields: []common.Field{{Name: "host", Value: "db1"}},
+	}, Op.Record)
+
+	err = parseRecordMode(recordModes[common.SchemaRecord], []string{"-a", "retag"})
+	assert.Error(t, err)
+
+	err = parseRecordMode(recordModes[common.AccountRecord], []string{
+		"-a", "get", "-n", "github", "-code",
+	})
+	assert.NoError(t, err)
+	assert.True(t, Op.LinkedCode)
+
+	err = parseRecordMode(recordModes[common.AccountRecord], []string{
+		"-a", "list", "-code",
+	})
+	assert.Error(t, err)
+}
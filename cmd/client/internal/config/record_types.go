@@ -0,0 +1,368 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"os"
+
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"github.com/alexey-mavrin/graduate-2/internal/otp"
+	"github.com/alexey-mavrin/graduate-2/internal/sshkey"
+	"github.com/alexey-mavrin/graduate-2/internal/urlmatch"
+)
+
+func init() {
+	registerRecordMode(recordMode{
+		recordType: common.AccountRecord,
+		actions:    []modeAction{{"find", OpSubtypeAccountFind}},
+		stdin:      true,
+		opaque:     []string{"u", "p", "l"},
+		define:     defineAccount,
+	})
+	registerRecordMode(recordMode{
+		recordType: common.NoteRecord,
+		stdin:      true,
+		opaque:     []string{"t"},
+		define:     defineNote,
+	})
+	registerRecordMode(recordMode{
+		recordType: common.CardRecord,
+		actions:    []modeAction{{"expiring", OpSubtypeCardExpiring}},
+		stdin:      true,
+		opaque:     []string{"ch", "num", "em", "ey", "c"},
+		define:     defineCard,
+	})
+	registerRecordMode(recordMode{
+		recordType: common.BinaryRecord,
+		opaque:     []string{"f"},
+		define:     defineBinary,
+	})
+	registerRecordMode(recordMode{
+		recordType: common.TOTPRecord,
+		actions:    []modeAction{{"code", OpSubtypeTOTPCode}},
+		stdin:      true,
+		opaque:     []string{"s", "uri", "alg", "digits", "period", "issuer", "acc"},
+		define:     defineTOTP,
+	})
+	registerRecordMode(recordMode{
+		recordType: common.SSHRecord,
+		actions: []modeAction{
+			{"gen", OpSubtypeSSHGenerate},
+			{"agent", OpSubtypeSSHAgent},
+		},
+		opaque: []string{"f", "passphrase", "comment"},
+		define: defineSSH,
+	})
+	registerRecordMode(recordMode{
+		recordType: common.GenericRecord,
+		stdin:      true,
+		opaque:     []string{"f", "schema"},
+		define:     defineGeneric,
+	})
+	registerRecordMode(recordMode{
+		recordType: common.SchemaRecord,
+		personal:   true,
+		opaque:     []string{"f"},
+		define:     defineSchema,
+	})
+}
+
+func defineAccount(set *flag.FlagSet) func() (common.Opaque, error) {
+	userName := set.String("u", "", "account user name")
+	password := set.String("p", "", "account password, - to read from stdin")
+	url := set.String("l", "", "account URL")
+	gen := set.Bool("gen", false, "generate new password")
+	match := set.String("match",
+		"",
+		"URL match to find: domain, host or exact, by config rules if not set",
+	)
+	printJSON := set.Bool("json", false, "print the accounts found as JSON")
+	code := set.Bool("code", false, "print the code of the linked TOTP secret on get")
+
+	return func() (common.Opaque, error) {
+		account := common.Account{UserName: *userName, URL: *url}
+		if *code {
+			if Op.Subop != OpSubtypeRecordGet {
+				return nil, errors.New("TOTP code is printed on get only")
+			}
+			Op.LinkedCode = true
+		}
+		if Op.Subop == OpSubtypeAccountFind {
+			Op.JSON = *printJSON
+			if *url == "" {
+				return nil, errors.New("URL to find is not set")
+			}
+			Op.URLMatch = URLMatchLevel(*url)
+			if *match != "" {
+				var err error
+				Op.URLMatch, err = urlmatch.ParseLevel(*match)
+				if err != nil {
+					return nil, err
+				}
+			}
+			return account, nil
+		}
+		if *gen {
+			if Op.Subop != OpSubtypeRecordStore && Op.Subop != OpSubtypeRecordUpdate {
+				return nil, errors.New("password is generated on store or update only")
+			}
+			if isFlagPassed(set, "p") {
+				return nil, errors.New("password is either set or generated")
+			}
+			Op.GenPassword = true
+			Op.RecordChange.Opaque = true
+		}
+		if Op.stdin {
+			account.Password = *password
+			return account, mergeStdinRecord(&account)
+		}
+		var err error
+		account.Password, err = secretValue(set,
+			"p",
+			*password,
+			"account password",
+			Op.Subop == OpSubtypeRecordStore && !Op.GenPassword,
+			true,
+		)
+		return account, err
+	}
+}
+
+func defineNote(set *flag.FlagSet) func() (common.Opaque, error) {
+	text := set.String("t", "", "note text")
+
+	return func() (common.Opaque, error) {
+		note := common.Note{Text: *text}
+		if Op.stdin {
+			return note, mergeStdinRecord(&note)
+		}
+		return note, nil
+	}
+}
+
+func defineCard(set *flag.FlagSet) func() (common.Opaque, error) {
+	holder := set.String("ch", "", "card holder")
+	number := set.String("num", "", "card number, - to read from stdin")
+	expMonth := set.Int("em", 0, "card expiry month")
+	expYear := set.Int("ey", 0, "card expiry year")
+	cvc := set.String("c", "", "card CVC code, - to read from stdin")
+	days := set.Int("days", 60, "days to list the cards expiring within")
+	reveal := set.Bool("reveal", false, "show card number and CVC code")
+	force := set.Bool("force", false, "store expired card")
+
+	return func() (common.Opaque, error) {
+		Op.ExpiringDays = *days
+		Op.Reveal = *reveal
+		Op.Force = *force
+		card := common.Card{
+			Holder:   *holder,
+			ExpMonth: *expMonth,
+			ExpYear:  *expYear,
+		}
+		if Op.stdin {
+			card.Number = *number
+			card.CVC = *cvc
+			return card, mergeStdinRecord(&card)
+		}
+		var err error
+		card.Number, err = secretValue(set,
+			"num",
+			*number,
+			"card number",
+			Op.Subop == OpSubtypeRecordStore,
+			false,
+		)
+		if err != nil {
+			return nil, err
+		}
+		card.CVC, err = secretValue(set,
+			"c",
+			*cvc,
+			"card CVC code",
+			Op.Subop == OpSubtypeRecordStore,
+			false,
+		)
+		return card, err
+	}
+}
+
+func defineBinary(set *flag.FlagSet) func() (common.Opaque, error) {
+	file := set.String("f", "", "file name")
+
+	return func() (common.Opaque, error) {
+		var binary common.Binary
+		Op.FileName = *file
+		if Op.Subop == OpSubtypeRecordStore || Op.Subop == OpSubtypeRecordUpdate {
+			var err error
+			binary.Data, err = readEncodeFile(*file)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return binary, nil
+	}
+}
+
+func defineTOTP(set *flag.FlagSet) func() (common.Opaque, error) {
+	secret := set.String("s", "", "base32 encoded secret, - to read from stdin")
+	uri := set.String("uri", "", "otpauth URI to import, - to read from stdin")
+	algorithm := set.String("alg",
+		common.DefaultTOTPAlgorithm,
+		"algorithm: SHA1|SHA256|SHA512",
+	)
+	digits := set.Int("digits", common.DefaultTOTPDigits, "code digits")
+	period := set.Int("period", common.DefaultTOTPPeriod, "code period in seconds")
+	issuer := set.String("issuer", "", "issuer of the secret")
+	account := set.String("acc",
+		"",
+		"name of the account record the secret is used for",
+	)
+
+	return func() (common.Opaque, error) {
+		totp := common.TOTP{
+			Algorithm: *algorithm,
+			Digits:    *digits,
+			Period:    *period,
+			Issuer:    *issuer,
+			Account:   *account,
+		}
+		if Op.stdin {
+			totp.Secret = *secret
+			return totp, mergeStdinRecord(&totp)
+		}
+		if isFlagPassed(set, "uri") {
+			value, err := secretValue(set, "uri", *uri, "otpauth URI", true, false)
+			if err != nil {
+				return nil, err
+			}
+			totp, err = otp.ParseURI(value)
+			if err != nil {
+				return nil, err
+			}
+			if isFlagPassed(set, "issuer") {
+				totp.Issuer = *issuer
+			}
+			totp.Account = *account
+			return totp, nil
+		}
+		var err error
+		totp.Secret, err = secretValue(set,
+			"s",
+			*secret,
+			"TOTP secret",
+			Op.Subop == OpSubtypeRecordStore,
+			false,
+		)
+		return totp, err
+	}
+}
+
+func defineSSH(set *flag.FlagSet) func() (common.Opaque, error) {
+	file := set.String("f", "", "private key file to import")
+	passphrase := set.String("passphrase",
+		"",
+		"private key passphrase, - to read from stdin",
+	)
+	comment := set.String("comment", "", "key comment")
+	reveal := set.Bool("reveal", false, "show private key and passphrase")
+	socket := set.String("sock", "", "SSH agent socket path")
+
+	return func() (common.Opaque, error) {
+		Op.Reveal = *reveal
+		Op.SSHSocket = *socket
+		if Op.SSHSocket == "" {
+			Op.SSHSocket = SSHAgentSocket()
+		}
+		if *file != "" {
+			return importSSHKey(set, *file, *passphrase, *comment)
+		}
+		return common.SSHKey{Comment: *comment}, nil
+	}
+}
+
+// importSSHKey reads the private key file, the passphrase is prompted
+// if the key is encrypted and the passphrase is not set
+func importSSHKey(set *flag.FlagSet,
+	file, passphrase, comment string,
+) (common.SSHKey, error) {
+	private, err := os.ReadFile(file)
+	if err != nil {
+		return common.SSHKey{}, err
+	}
+	passphrase, err = secretValue(set,
+		"passphrase",
+		passphrase,
+		"passphrase of "+file,
+		false,
+		false,
+	)
+	if err != nil {
+		return common.SSHKey{}, err
+	}
+	key, err := sshkey.Import(private, passphrase, comment)
+	if errors.Is(err, sshkey.ErrPassphraseMissing) {
+		passphrase, err = ReadSecret("passphrase of " + file)
+		if err != nil {
+			return common.SSHKey{}, err
+		}
+		key, err = sshkey.Import(private, passphrase, comment)
+	}
+	return key, err
+}
+
+func defineGeneric(set *flag.FlagSet) func() (common.Opaque, error) {
+	var fields varFlags
+	set.Var(&fields,
+		"f",
+		"field NAME[:TYPE]=VALUE, VALUE - to read from stdin, empty VALUE removes the field on update; "+
+			"TYPE: text|secret|url|number|date|totp, by schema or text if not set; repeatable",
+	)
+	schema := set.String("schema", "", "schema to check the fields against")
+	reveal := set.Bool("reveal", false, "show secret field values")
+
+	return func() (common.Opaque, error) {
+		Op.Reveal = *reveal
+		generic := common.Generic{Schema: *schema}
+		if Op.stdin {
+			return generic, mergeStdinRecord(&generic)
+		}
+		for _, s := range fields {
+			field, err := common.ParseField(s)
+			if err != nil {
+				return nil, err
+			}
+			field.Value, err = secretValue(set,
+				"f",
+				field.Value,
+				"value of "+field.Name,
+				false,
+				false,
+			)
+			if err != nil {
+				return nil, err
+			}
+			generic.Fields = append(generic.Fields, field)
+		}
+		return generic, nil
+	}
+}
+
+func defineSchema(set *flag.FlagSet) func() (common.Opaque, error) {
+	var fields varFlags
+	set.Var(&fields,
+		"f",
+		"field NAME[:TYPE][:required], TYPE: text|secret|url|number|date|totp; repeatable",
+	)
+
+	return func() (common.Opaque, error) {
+		var schema common.Schema
+		for _, s := range fields {
+			spec, err := common.ParseFieldSpec(s)
+			if err != nil {
+				return nil, err
+			}
+			schema.Fields = append(schema.Fields, spec)
+		}
+		return schema, nil
+	}
+}
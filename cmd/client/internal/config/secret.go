@@ -38,9 +38,15 @@ func GetKey(file string) (*common.Key, error) {
 	if err != nil {
 		return nil, err
 	}
-	if len(buf) < minPhraseLen {
+	return KeyFromPhrase(string(buf))
+}
+
+// KeyFromPhrase returns a key computed from the secret phrase
+// checking the phrase strength
+func KeyFromPhrase(phrase string) (*common.Key, error) {
+	if len(phrase) < minPhraseLen {
 		return nil, errors.New("key phrase is too short")
 	}
-	key := crypt.MakeKey(string(buf))
+	key := crypt.MakeKey(phrase)
 	return &key, nil
 }
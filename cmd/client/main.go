@@ -1,8 +1,10 @@
 package main
 
 import (
+	"errors"
 	"log"
 	"os"
+	"os/exec"
 
 	"github.com/alexey-mavrin/graduate-2/cmd/client/internal/action"
 	"github.com/alexey-mavrin/graduate-2/cmd/client/internal/config"
@@ -39,7 +41,19 @@ func main() {
 		log.Fatal(err)
 	}
 
+	if config.Op.Op != config.OpTypeAgent && config.Op.Op != config.OpTypeGen {
+		err = config.LoadSecrets()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	err = action.ChooseAct()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+		// the exit code of the command run is passed through
+		os.Exit(exitErr.ExitCode())
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
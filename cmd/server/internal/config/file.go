@@ -5,12 +5,18 @@ import (
 	"os"
 )
 
-// Config contains client config parameters set in the config file
+// Config contains client config parameters set in the config file.
+// The record types accepted and the size limits are optional,
+// the server defaults are used if not set.
 type Config struct {
-	StoreFile  string `json:"store_file"`
-	ServerKey  string `json:"server_key"`
-	ServerCRT  string `json:"server_crt"`
-	ListenPort int    `json:"listen_port"`
+	StoreFile      string         `json:"store_file"`
+	ServerKey      string         `json:"server_key"`
+	ServerCRT      string         `json:"server_crt"`
+	ListenPort     int            `json:"listen_port"`
+	RecordTypes    []string       `json:"record_types"`
+	MaxBodySize    int64          `json:"max_body_size"`
+	MaxNameSize    int            `json:"max_name_size"`
+	MaxPayloadSize map[string]int `json:"max_payload_size"`
 }
 
 // Cfg holds global parameters from config file
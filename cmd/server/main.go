@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/alexey-mavrin/graduate-2/cmd/server/internal/config"
+	"github.com/alexey-mavrin/graduate-2/internal/common"
 	"github.com/alexey-mavrin/graduate-2/internal/server"
 )
 
@@ -23,6 +24,11 @@ func main() {
 		log.Fatal(err)
 	}
 
+	err = server.SetLimits(limits(config.Cfg))
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	err = server.StartServer(
 		config.Cfg.ListenPort,
 		config.Cfg.StoreFile,
@@ -33,3 +39,19 @@ func main() {
 		log.Fatal(err)
 	}
 }
+
+// limits returns the server limits set in the config file
+func limits(cfg config.Config) server.Limits {
+	l := server.Limits{
+		MaxBodySize:    cfg.MaxBodySize,
+		MaxNameSize:    cfg.MaxNameSize,
+		MaxPayloadSize: make(map[common.RecordType]int),
+	}
+	for _, t := range cfg.RecordTypes {
+		l.RecordTypes = append(l.RecordTypes, common.RecordType(t))
+	}
+	for t, size := range cfg.MaxPayloadSize {
+		l.MaxPayloadSize[common.RecordType(t)] = size
+	}
+	return l
+}
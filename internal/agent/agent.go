@@ -0,0 +1,264 @@
+package agent
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+)
+
+const (
+	// DefaultIdleTimeout is the idle time the agent is locked after
+	DefaultIdleTimeout = 15 * time.Minute
+
+	socketMode    = 0600
+	socketDirMode = 0700
+	connTimeout   = 5 * time.Second
+)
+
+// commands of the agent protocol
+const (
+	CommandUnlock = "unlock"
+	CommandLock   = "lock"
+	CommandGet    = "get"
+	CommandStatus = "status"
+	CommandStop   = "stop"
+)
+
+const statusOK = "ok"
+
+// ErrLocked is returned when the secrets are requested from the locked agent
+var ErrLocked = errors.New("agent is locked")
+
+// ErrRunning is returned when the agent is already listening on the socket
+var ErrRunning = errors.New("agent is already running")
+
+// ErrSocketDir is returned when the socket directory is not private
+// to the current user, the secrets are neither served nor sent there
+var ErrSocketDir = errors.New("socket directory is not private")
+
+// Request is the request to the agent, one per connection
+type Request struct {
+	Command  string `json:"command"`
+	Key      string `json:"key,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// Response is the agent response
+type Response struct {
+	Status   string `json:"status"`
+	Locked   bool   `json:"locked"`
+	Key      string `json:"key,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// Agent holds the master key and the user password in memory
+// and serves them to the client processes over the Unix socket.
+// The agent is locked, i.e. the secrets are wiped, after the idle timeout.
+type Agent struct {
+	mutex    sync.Mutex
+	key      *common.Key
+	password string
+	timeout  time.Duration
+	timer    *time.Timer
+	listener net.Listener
+}
+
+// NewAgent returns new locked agent
+func NewAgent(timeout time.Duration) *Agent {
+	if timeout <= 0 {
+		timeout = DefaultIdleTimeout
+	}
+	return &Agent{
+		timeout: timeout,
+	}
+}
+
+// DefaultSocket returns the default agent socket path: in the user
+// runtime directory if it is set or in the user directory in the temp dir
+func DefaultSocket() string {
+	if dir, ok := os.LookupEnv("XDG_RUNTIME_DIR"); ok && dir != "" {
+		return filepath.Join(dir, "gosecret-agent.sock")
+	}
+	return filepath.Join(os.TempDir(),
+		fmt.Sprintf("gosecret-%d", os.Getuid()),
+		"agent.sock",
+	)
+}
+
+// checkSocketDir checks the socket directory is owned by the current user
+// and is not accessible by others. Otherwise another user could create
+// the directory in the temp dir beforehand and take over the socket.
+func checkSocketDir(socket string) error {
+	dir := filepath.Dir(socket)
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%w: %s is not a directory", ErrSocketDir, dir)
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fmt.Errorf("%w: %s owner is unknown", ErrSocketDir, dir)
+	}
+	if int(stat.Uid) != os.Getuid() {
+		return fmt.Errorf("%w: %s is owned by uid %d", ErrSocketDir, dir, stat.Uid)
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return fmt.Errorf("%w: %s is accessible by others", ErrSocketDir, dir)
+	}
+	return nil
+}
+
+// Listen creates the socket accessible by the current user only.
+// The stale socket left by the agent not running is removed.
+func Listen(socket string) (net.Listener, error) {
+	err := os.MkdirAll(filepath.Dir(socket), socketDirMode)
+	if err != nil {
+		return nil, err
+	}
+	err = checkSocketDir(socket)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(socket); err == nil {
+		conn, err := net.DialTimeout("unix", socket, connTimeout)
+		if err == nil {
+			conn.Close()
+			return nil, ErrRunning
+		}
+		err = os.Remove(socket)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+	err = os.Chmod(socket, socketMode)
+	if err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// Serve serves the requests until the stop command is received
+// or the listener is closed
+func (a *Agent) Serve(l net.Listener) error {
+	a.mutex.Lock()
+	a.listener = l
+	a.mutex.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		go a.handle(conn)
+	}
+}
+
+func (a *Agent) handle(conn net.Conn) {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(connTimeout))
+
+	var req Request
+	err := json.NewDecoder(conn).Decode(&req)
+	if err != nil {
+		log.Printf("agent request: %v", err)
+		return
+	}
+	resp := a.process(req)
+	err = json.NewEncoder(conn).Encode(resp)
+	if err != nil {
+		log.Printf("agent response: %v", err)
+	}
+	if req.Command == CommandStop && resp.Status == statusOK {
+		a.mutex.Lock()
+		a.listener.Close()
+		a.mutex.Unlock()
+	}
+}
+
+func (a *Agent) process(req Request) Response {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	resp := Response{Status: statusOK}
+	switch req.Command {
+	case CommandUnlock:
+		buf, err := hex.DecodeString(req.Key)
+		if err != nil || len(buf) != len(common.Key{}) {
+			resp.Status = "wrong key"
+			break
+		}
+		var key common.Key
+		copy(key[:], buf)
+		a.key = &key
+		a.password = req.Password
+		a.touch()
+	case CommandLock, CommandStop:
+		a.lock()
+	case CommandGet:
+		if a.key == nil {
+			resp.Status = ErrLocked.Error()
+			break
+		}
+		resp.Key = hex.EncodeToString(a.key[:])
+		resp.Password = a.password
+		a.touch()
+	case CommandStatus:
+	default:
+		resp.Status = "unknown command"
+	}
+	resp.Locked = a.key == nil
+	return resp
+}
+
+// touch restarts the idle timer
+func (a *Agent) touch() {
+	if a.timer != nil {
+		a.timer.Stop()
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(a.timeout, func() {
+		a.mutex.Lock()
+		defer a.mutex.Unlock()
+		if a.timer != timer {
+			// the timer is restarted or stopped meanwhile
+			return
+		}
+		a.lock()
+		log.Print("agent is locked after the idle timeout")
+	})
+	a.timer = timer
+}
+
+// lock wipes the secrets
+func (a *Agent) lock() {
+	if a.timer != nil {
+		a.timer.Stop()
+		a.timer = nil
+	}
+	if a.key != nil {
+		*a.key = common.Key{}
+		a.key = nil
+	}
+	a.password = ""
+}
@@ -0,0 +1,108 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func startAgent(t *testing.T, timeout time.Duration) (*Client, chan error) {
+	socket := filepath.Join(t.TempDir(), "agent", "agent.sock")
+	l, err := Listen(socket)
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- NewAgent(timeout).Serve(l)
+	}()
+	return NewClient(socket), done
+}
+
+func Test_agent(t *testing.T) {
+	clnt, done := startAgent(t, time.Minute)
+
+	info, err := os.Stat(clnt.Socket)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(socketMode), info.Mode().Perm())
+
+	// the second agent cannot listen on the same socket
+	_, err = Listen(clnt.Socket)
+	assert.ErrorIs(t, err, ErrRunning)
+
+	locked, err := clnt.Status()
+	assert.NoError(t, err)
+	assert.True(t, locked)
+
+	_, _, err = clnt.Get()
+	assert.ErrorIs(t, err, ErrLocked)
+
+	key := common.Key{1, 2, 3}
+	err = clnt.Unlock(key, "pass")
+	assert.NoError(t, err)
+
+	gotKey, password, err := clnt.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, key, gotKey)
+	assert.Equal(t, "pass", password)
+
+	err = clnt.Lock()
+	assert.NoError(t, err)
+	_, _, err = clnt.Get()
+	assert.ErrorIs(t, err, ErrLocked)
+
+	err = clnt.Stop()
+	assert.NoError(t, err)
+	select {
+	case err = <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("agent is not stopped")
+	}
+
+	// the stale socket is removed
+	l, err := Listen(clnt.Socket)
+	assert.NoError(t, err)
+	l.Close()
+}
+
+func Test_agentSocketDir(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.Chmod(dir, 0755))
+	_, err := Listen(filepath.Join(dir, "agent.sock"))
+	assert.ErrorIs(t, err, ErrSocketDir)
+
+	// the secrets are not sent to the socket in the directory
+	// created by another user
+	clnt, _ := startAgent(t, time.Minute)
+	defer clnt.Stop()
+	socketDir := filepath.Dir(clnt.Socket)
+	err = os.Chown(socketDir, os.Getuid()+1, -1)
+	if err != nil {
+		t.Skipf("cannot change the socket directory owner: %v", err)
+	}
+	err = clnt.Unlock(common.Key{1}, "pass")
+	assert.ErrorIs(t, err, ErrSocketDir)
+	_, err = Listen(clnt.Socket)
+	assert.ErrorIs(t, err, ErrSocketDir)
+	require.NoError(t, os.Chown(socketDir, os.Getuid(), -1))
+}
+
+func Test_agentIdleTimeout(t *testing.T) {
+	clnt, _ := startAgent(t, 50*time.Millisecond)
+	defer clnt.Stop()
+
+	err := clnt.Unlock(common.Key{1}, "pass")
+	require.NoError(t, err)
+	_, _, err = clnt.Get()
+	assert.NoError(t, err)
+
+	time.Sleep(100 * time.Millisecond)
+	locked, err := clnt.Status()
+	assert.NoError(t, err)
+	assert.True(t, locked)
+}
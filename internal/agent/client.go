@@ -0,0 +1,101 @@
+package agent
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+)
+
+// Client talks to the agent over the socket
+type Client struct {
+	Socket  string
+	Timeout time.Duration
+}
+
+// NewClient returns new agent client
+func NewClient(socket string) *Client {
+	return &Client{
+		Socket:  socket,
+		Timeout: connTimeout,
+	}
+}
+
+func (c *Client) do(req Request) (Response, error) {
+	var resp Response
+	err := checkSocketDir(c.Socket)
+	if err != nil {
+		return resp, err
+	}
+	conn, err := net.DialTimeout("unix", c.Socket, c.Timeout)
+	if err != nil {
+		return resp, err
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(c.Timeout))
+
+	err = json.NewEncoder(conn).Encode(req)
+	if err != nil {
+		return resp, err
+	}
+	err = json.NewDecoder(conn).Decode(&resp)
+	if err != nil {
+		return resp, err
+	}
+	if resp.Status == ErrLocked.Error() {
+		return resp, ErrLocked
+	}
+	if resp.Status != statusOK {
+		return resp, errors.New(resp.Status)
+	}
+	return resp, nil
+}
+
+// Unlock passes the secrets to the agent
+func (c *Client) Unlock(key common.Key, password string) error {
+	_, err := c.do(Request{
+		Command:  CommandUnlock,
+		Key:      hex.EncodeToString(key[:]),
+		Password: password,
+	})
+	return err
+}
+
+// Lock makes the agent to wipe the secrets
+func (c *Client) Lock() error {
+	_, err := c.do(Request{Command: CommandLock})
+	return err
+}
+
+// Stop makes the agent to wipe the secrets and exit
+func (c *Client) Stop() error {
+	_, err := c.do(Request{Command: CommandStop})
+	return err
+}
+
+// Status returns true if the agent is locked
+func (c *Client) Status() (bool, error) {
+	resp, err := c.do(Request{Command: CommandStatus})
+	return resp.Locked, err
+}
+
+// Get returns the master key and the password held by the agent
+func (c *Client) Get() (common.Key, string, error) {
+	var key common.Key
+	resp, err := c.do(Request{Command: CommandGet})
+	if err != nil {
+		return key, "", err
+	}
+	buf, err := hex.DecodeString(resp.Key)
+	if err != nil {
+		return key, "", err
+	}
+	if len(buf) != len(key) {
+		return key, "", errors.New("wrong key length")
+	}
+	copy(key[:], buf)
+	return key, resp.Password, nil
+}
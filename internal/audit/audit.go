@@ -0,0 +1,201 @@
+package audit
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+)
+
+const (
+	// DefaultMinEntropy is the entropy in bits the password
+	// is considered weak below
+	DefaultMinEntropy = 60
+	// DefaultMaxAgeDays is the number of days the password
+	// should be rotated within
+	DefaultMaxAgeDays = 180
+)
+
+// Item is the account audited
+type Item struct {
+	ID      int64
+	Name    string
+	Account common.Account
+}
+
+// Options are the audit options
+type Options struct {
+	// MinEntropy is the entropy in bits the password is considered weak below
+	MinEntropy float64
+	// MaxAge is the age of the password not rotated, 0 disables the check
+	MaxAge time.Duration
+	// Breaches are the numbers of the breaches by the password
+	// found in the breach database
+	Breaches map[string]int
+	// Now is the time of the audit
+	Now time.Time
+}
+
+// Finding describes the issues of the account password
+type Finding struct {
+	ID       int64    `json:"id"`
+	Name     string   `json:"name"`
+	URL      string   `json:"url,omitempty"`
+	Entropy  float64  `json:"entropy"`
+	Weak     bool     `json:"weak"`
+	Patterns []string `json:"patterns,omitempty"`
+	// ReusedWith are the IDs of the accounts with the same password
+	ReusedWith []int64 `json:"reused_with,omitempty"`
+	// Changed is the time the password was changed,
+	// nil if it is not tracked
+	Changed *time.Time `json:"changed,omitempty"`
+	Old     bool       `json:"old"`
+	// AgeUnknown is set if the password age is checked,
+	// but the time the password was changed is not tracked
+	AgeUnknown bool `json:"age_unknown"`
+	// Breached is the number of the breaches the password was found in
+	Breached int `json:"breached,omitempty"`
+}
+
+// Report is the audit result
+type Report struct {
+	Accounts int `json:"accounts"`
+	Weak     int `json:"weak"`
+	Reused   int `json:"reused"`
+	Old      int `json:"old"`
+	// AgeUnknown is the number of the accounts with the password age
+	// unknown, they are not counted as old
+	AgeUnknown int `json:"age_unknown"`
+	Breached   int `json:"breached"`
+	// Findings are the accounts with issues ordered by ID
+	Findings []Finding `json:"findings"`
+}
+
+// Audit checks the account passwords for being weak, reused by
+// other accounts, not rotated within the maximal age or breached.
+// The accounts with the password change time not tracked are reported
+// with the age unknown.
+func Audit(items []Item, opts Options) Report {
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].ID < items[j].ID
+	})
+
+	// the accounts with no password do not share it
+	byPassword := make(map[string][]int64)
+	for _, item := range items {
+		if item.Account.Password == "" {
+			continue
+		}
+		byPassword[item.Account.Password] = append(
+			byPassword[item.Account.Password],
+			item.ID,
+		)
+	}
+
+	report := Report{
+		Accounts: len(items),
+		Findings: []Finding{},
+	}
+	for _, item := range items {
+		changed := item.Account.PasswordChanged
+		if changed != nil && changed.IsZero() {
+			// the zero time stored by the previous versions
+			changed = nil
+		}
+		f := Finding{
+			ID:       item.ID,
+			Name:     item.Name,
+			URL:      item.Account.URL,
+			Changed:  changed,
+			Breached: opts.Breaches[item.Account.Password],
+		}
+		f.Entropy, f.Patterns = Entropy(item.Account.Password)
+		f.Weak = f.Entropy < opts.MinEntropy
+		for _, id := range byPassword[item.Account.Password] {
+			if id != item.ID {
+				f.ReusedWith = append(f.ReusedWith, id)
+			}
+		}
+		if opts.MaxAge > 0 {
+			f.AgeUnknown = f.Changed == nil
+			f.Old = f.Changed != nil && opts.Now.Sub(*f.Changed) > opts.MaxAge
+		}
+
+		if f.Weak {
+			report.Weak++
+		}
+		if len(f.ReusedWith) > 0 {
+			report.Reused++
+		}
+		if f.Old {
+			report.Old++
+		}
+		if f.AgeUnknown {
+			report.AgeUnknown++
+		}
+		if f.Breached > 0 {
+			report.Breached++
+		}
+		if f.Weak || len(f.ReusedWith) > 0 || f.Old || f.AgeUnknown ||
+			f.Breached > 0 {
+			report.Findings = append(report.Findings, f)
+		}
+	}
+	return report
+}
+
+// issues returns the human readable issues of the account
+func (f Finding) issues() string {
+	var issues []string
+	if f.Weak {
+		weak := "weak"
+		if len(f.Patterns) > 0 {
+			weak += " (" + strings.Join(f.Patterns, ", ") + ")"
+		}
+		issues = append(issues, weak)
+	}
+	if len(f.ReusedWith) > 0 {
+		ids := make([]string, len(f.ReusedWith))
+		for i, id := range f.ReusedWith {
+			ids[i] = fmt.Sprint(id)
+		}
+		issues = append(issues, "reused by "+strings.Join(ids, ", "))
+	}
+	if f.Old {
+		issues = append(issues, "not rotated")
+	}
+	if f.AgeUnknown {
+		issues = append(issues, "age unknown")
+	}
+	if f.Breached > 0 {
+		issues = append(issues, fmt.Sprintf("breached %d times", f.Breached))
+	}
+	return strings.Join(issues, "; ")
+}
+
+// WriteTable writes the report as the human readable table
+func (r Report) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tENTROPY\tCHANGED\tISSUES")
+	for _, f := range r.Findings {
+		changed := "unknown"
+		if f.Changed != nil {
+			changed = f.Changed.Format("2006-01-02")
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%.0f\t%s\t%s\n",
+			f.ID, f.Name, f.Entropy, changed, f.issues())
+	}
+	err := tw.Flush()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w,
+		"%d accounts: %d weak, %d reused, %d not rotated, %d age unknown, %d breached\n",
+		r.Accounts, r.Weak, r.Reused, r.Old, r.AgeUnknown, r.Breached,
+	)
+	return err
+}
@@ -0,0 +1,175 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEntropy(t *testing.T) {
+	tests := []struct {
+		password string
+		weak     bool
+		pattern  string
+	}{
+		{"", true, ""},
+		{"password", true, PatternCommon},
+		{"P@ssw0rd2022!", true, PatternCommon},
+		{"123456", true, PatternCommon},
+		{"aaaaaaaaaaaaaaaaaaaa", true, PatternRepeat},
+		{"abcdefghijklmnopqrstuvwxyz", true, PatternSequence},
+		{"qwertyuiopasdfghjkl", true, PatternKeyboard},
+		{"Kx9#mQ2$vL8@nR4!", false, ""},
+		{"orphan-blouse-bank-relax", false, ""},
+	}
+	for _, tt := range tests {
+		bits, patterns := Entropy(tt.password)
+		assert.Equal(t, tt.weak, bits < DefaultMinEntropy,
+			"%q entropy %.1f", tt.password, bits)
+		if tt.pattern != "" {
+			assert.Contains(t, patterns, tt.pattern, tt.password)
+		}
+	}
+
+	withYear, patterns := Entropy("Kx9#mQ1987")
+	withoutYear, _ := Entropy("Kx9#mQ1a8b")
+	assert.Contains(t, patterns, PatternYear)
+	assert.Less(t, withYear, withoutYear)
+}
+
+func TestAudit(t *testing.T) {
+	now := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	strong := "Kx9#mQ2$vL8@nR4!"
+	old := now.AddDate(-1, 0, 0)
+	items := []Item{
+		{ID: 3, Name: "weak", Account: common.Account{
+			Password:        "qwerty",
+			PasswordChanged: &now,
+		}},
+		{ID: 1, Name: "reused1", Account: common.Account{
+			Password:        strong,
+			PasswordChanged: &now,
+		}},
+		{ID: 2, Name: "reused2", Account: common.Account{
+			Password:        strong,
+			PasswordChanged: &now,
+		}},
+		{ID: 4, Name: "old", Account: common.Account{
+			Password:        "Zq7!pW3^tY6&jH1*",
+			PasswordChanged: &old,
+		}},
+		{ID: 5, Name: "fine", Account: common.Account{
+			Password:        "Ab3$Cd5^Ef7&Gh9*",
+			PasswordChanged: &now,
+		}},
+	}
+
+	report := Audit(items, Options{
+		MinEntropy: DefaultMinEntropy,
+		MaxAge:     DefaultMaxAgeDays * 24 * time.Hour,
+		Breaches:   map[string]int{"qwerty": 100},
+		Now:        now,
+	})
+	assert.Equal(t, 5, report.Accounts)
+	assert.Equal(t, 1, report.Weak)
+	assert.Equal(t, 2, report.Reused)
+	assert.Equal(t, 1, report.Old)
+	assert.Equal(t, 1, report.Breached)
+
+	require.Len(t, report.Findings, 4)
+	assert.Equal(t, int64(1), report.Findings[0].ID)
+	assert.Equal(t, []int64{2}, report.Findings[0].ReusedWith)
+	assert.Equal(t, int64(3), report.Findings[2].ID)
+	assert.True(t, report.Findings[2].Weak)
+	assert.Equal(t, 100, report.Findings[2].Breached)
+	assert.True(t, report.Findings[3].Old)
+
+	var buf bytes.Buffer
+	require.NoError(t, report.WriteTable(&buf))
+	assert.Contains(t, buf.String(), "reused by 2")
+	assert.Contains(t, buf.String(), "breached 100 times")
+	assert.Contains(t, buf.String(),
+		"5 accounts: 1 weak, 2 reused, 1 not rotated, 0 age unknown, 1 breached")
+}
+
+func TestAudit_untracked(t *testing.T) {
+	items := []Item{
+		{ID: 1, Account: common.Account{Password: "Kx9#mQ2$vL8@nR4!"}},
+	}
+	report := Audit(items, Options{
+		MaxAge: time.Hour,
+		Now:    time.Now(),
+	})
+	require.Len(t, report.Findings, 1)
+	assert.False(t, report.Findings[0].Old)
+	assert.True(t, report.Findings[0].AgeUnknown)
+	assert.Equal(t, 0, report.Old)
+	assert.Equal(t, 1, report.AgeUnknown)
+
+	var buf bytes.Buffer
+	require.NoError(t, report.WriteTable(&buf))
+	assert.Contains(t, buf.String(), "unknown  age unknown")
+
+	report = Audit(items, Options{Now: time.Now()})
+	assert.Empty(t, report.Findings)
+}
+
+func TestAudit_noPassword(t *testing.T) {
+	items := []Item{
+		{ID: 1, Account: common.Account{UserName: "alice"}},
+		{ID: 2, Account: common.Account{UserName: "bob"}},
+	}
+	report := Audit(items, Options{Now: time.Now()})
+	assert.Equal(t, 0, report.Reused)
+	for _, f := range report.Findings {
+		assert.Empty(t, f.ReusedWith)
+	}
+}
+
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func TestBreaches(t *testing.T) {
+	dir := t.TempDir()
+	breached := sha1Hex("qwerty")
+	padded := sha1Hex("padded")
+
+	file := filepath.Join(dir, "pwned.txt")
+	content := "0000000000000000000000000000000000000000:1\n" +
+		breached + ":3912816\n" +
+		padded + ":0\n"
+	require.NoError(t, os.WriteFile(file, []byte(content), 0600))
+
+	got, err := Breaches(file, []string{"qwerty", "padded", "unknown"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"qwerty": 3912816}, got)
+
+	ranges := filepath.Join(dir, "ranges")
+	require.NoError(t, os.Mkdir(ranges, 0700))
+	require.NoError(t, os.WriteFile(
+		filepath.Join(ranges, breached[:hibpPrefixLen]),
+		[]byte(strings.ToLower(breached[hibpPrefixLen:])+":12\r\n"),
+		0600,
+	))
+	got, err = Breaches(ranges, []string{"qwerty"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"qwerty": 12}, got)
+
+	_, err = Breaches(ranges, []string{"unknown"})
+	assert.Error(t, err, "range file is missing")
+
+	require.NoError(t, os.WriteFile(file, []byte("bad line\n"), 0600))
+	_, err = Breaches(file, []string{"qwerty"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,94 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// hibpPrefixLen is the length of the hash prefix of the range files
+const hibpPrefixLen = 5
+
+// Breaches looks the passwords up in the local copy of the HIBP Pwned
+// Passwords SHA-1 database and returns the numbers of the breaches
+// of the passwords found. The database is either the file of HASH:COUNT
+// lines or the directory of the range files named by the hash prefix
+// of 5 hex digits with SUFFIX:COUNT lines, as returned by the range API.
+func Breaches(path string, passwords []string) (map[string]int, error) {
+	// the passwords by the upper case hex SHA-1 hash
+	hashes := make(map[string]string)
+	for _, p := range passwords {
+		sum := sha1.Sum([]byte(p))
+		hashes[strings.ToUpper(hex.EncodeToString(sum[:]))] = p
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	breaches := make(map[string]int)
+	if !info.IsDir() {
+		err = scanHashFile(path, "", hashes, breaches)
+		return breaches, err
+	}
+
+	prefixes := make(map[string]bool)
+	for hash := range hashes {
+		prefixes[hash[:hibpPrefixLen]] = true
+	}
+	for prefix := range prefixes {
+		file := filepath.Join(path, prefix)
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			file += ".txt"
+		}
+		err = scanHashFile(file, prefix, hashes, breaches)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return breaches, nil
+}
+
+// scanHashFile scans the file of the hash lines with the prefix
+// omitted for the hashes of the passwords
+func scanHashFile(file, prefix string,
+	hashes map[string]string,
+	breaches map[string]int,
+) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		hash, count, ok := strings.Cut(text, ":")
+		if !ok {
+			return fmt.Errorf("%s:%d: bad hash line", file, line)
+		}
+		password, found := hashes[prefix+strings.ToUpper(hash)]
+		if !found {
+			continue
+		}
+		n, err := strconv.Atoi(count)
+		if err != nil {
+			return fmt.Errorf("%s:%d: bad count: %w", file, line, err)
+		}
+		// the range API pads the responses with zero count hashes
+		if n > 0 {
+			breaches[password] = n
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,174 @@
+package audit
+
+import (
+	_ "embed" // the common passwords list is embedded
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// patterns decreasing the password entropy
+const (
+	PatternCommon   = "common password"
+	PatternRepeat   = "repeated characters"
+	PatternSequence = "sequence"
+	PatternKeyboard = "keyboard sequence"
+	PatternYear     = "year"
+)
+
+// sizes of the character classes
+const (
+	lowerPool  = 26
+	upperPool  = 26
+	digitPool  = 10
+	symbolPool = 33
+	otherPool  = 100
+)
+
+//go:embed common.txt
+var commonList string
+
+// commonPasswords are the most common passwords
+var commonPasswords = func() map[string]bool {
+	set := make(map[string]bool)
+	for _, p := range strings.Fields(commonList) {
+		set[p] = true
+	}
+	return set
+}()
+
+// keyboardRows are the rows of the keyboard to find
+// the keyboard sequences like qwerty in
+var keyboardRows = []string{
+	"`1234567890-=",
+	"qwertyuiop[]",
+	"asdfghjkl;'",
+	"zxcvbnm,./",
+}
+
+// leet reverts the common character substitutions like p@ssw0rd
+var leet = strings.NewReplacer(
+	"0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t",
+	"@", "a", "$", "s", "!", "i",
+)
+
+var yearRe = regexp.MustCompile(`(19|20)\d\d`)
+
+// Entropy estimates the entropy of the password in bits and returns
+// the patterns found that decrease it. The characters repeating the previous
+// one or continuing the sequences like abc, 321 or qwerty add no entropy.
+// The common password, possibly with the character substitutions and
+// the digits or symbols appended, is as strong as the guess from
+// the common passwords list.
+func Entropy(password string) (float64, []string) {
+	if password == "" {
+		return 0, nil
+	}
+
+	lower := strings.ToLower(password)
+	base := strings.TrimRightFunc(lower, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	if base == "" {
+		base = lower
+	}
+	if commonPasswords[base] || commonPasswords[leet.Replace(base)] {
+		suffix := []rune(lower[len(base):])
+		bits := math.Log2(float64(len(commonPasswords))) +
+			float64(len(suffix))*math.Log2(digitPool+symbolPool)
+		return bits, []string{PatternCommon}
+	}
+
+	var patterns []string
+	addPattern := func(p string) {
+		for _, found := range patterns {
+			if found == p {
+				return
+			}
+		}
+		patterns = append(patterns, p)
+	}
+
+	runes := []rune(lower)
+	length := 0
+	for i, r := range runes {
+		switch {
+		case i > 0 && r == runes[i-1]:
+			addPattern(PatternRepeat)
+		case i > 1 && isSequence(runes[i-2], runes[i-1], r):
+			addPattern(PatternSequence)
+		case i > 1 && isKeyboardSequence(string(runes[i-2:i+1])):
+			addPattern(PatternKeyboard)
+		default:
+			length++
+		}
+	}
+	// the year is one of a couple of hundreds rather than of 10000 numbers
+	for range yearRe.FindAllString(password, -1) {
+		if length > 2 {
+			length -= 2
+			addPattern(PatternYear)
+		}
+	}
+
+	return float64(length) * math.Log2(float64(poolSize(password))), patterns
+}
+
+// poolSize returns the number of the characters of the classes
+// the password characters belong to
+func poolSize(password string) int {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range password {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < unicode.MaxASCII && unicode.IsPrint(r):
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	size := 0
+	for _, class := range []struct {
+		found bool
+		size  int
+	}{
+		{lower, lowerPool},
+		{upper, upperPool},
+		{digit, digitPool},
+		{symbol, symbolPool},
+		{other, otherPool},
+	} {
+		if class.found {
+			size += class.size
+		}
+	}
+	return size
+}
+
+// isSequence checks the characters follow each other like abc or 321
+func isSequence(a, b, c rune) bool {
+	d := b - a
+	return (d == 1 || d == -1) && c-b == d
+}
+
+// isKeyboardSequence checks the characters are adjacent
+// on the keyboard row in any direction
+func isKeyboardSequence(s string) bool {
+	reversed := []rune(s)
+	for i, j := 0, len(reversed)-1; i < j; i, j = i+1, j-1 {
+		reversed[i], reversed[j] = reversed[j], reversed[i]
+	}
+	for _, row := range keyboardRows {
+		if strings.Contains(row, s) || strings.Contains(row, string(reversed)) {
+			return true
+		}
+	}
+	return false
+}
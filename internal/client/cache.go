@@ -2,8 +2,17 @@ package client
 
 import "github.com/alexey-mavrin/graduate-2/internal/common"
 
-// SyncCacheByType tries to cache all records of the given type
-func (c *Client) SyncCacheByType(t common.RecordType) error {
+// SyncCacheByType tries to cache all records of the given type.
+// The cache file is written once after all the records are cached.
+func (c *Client) SyncCacheByType(t common.RecordType) (err error) {
+	release := c.HoldCache()
+	defer func() {
+		releaseErr := release()
+		if err == nil {
+			err = releaseErr
+		}
+	}()
+
 	records, err := c.ListRecordsByType(t)
 	if err != nil {
 		return err
@@ -18,18 +27,21 @@ func (c *Client) SyncCacheByType(t common.RecordType) error {
 	return nil
 }
 
-// CleanCache erases all cached records
+// CleanCache erases all cached records and wipes the cache file
+// and the search index file
 func (c *Client) CleanCache() error {
-	records, err := c.cacheListRecords()
-	if err != nil {
-		return err
-	}
-
-	for id := range records {
-		err := c.cacheDeleteRecordByID(id)
+	if c.Store != nil {
+		err := c.Store.CloseDB()
 		if err != nil {
 			return err
 		}
+		c.Store = nil
 	}
-	return nil
+	if c.CacheFile == "" {
+		return nil
+	}
+	if err := wipeFile(c.searchIndexFile()); err != nil {
+		return err
+	}
+	return wipeFile(c.CacheFile)
 }
@@ -0,0 +1,227 @@
+package client
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"github.com/alexey-mavrin/graduate-2/internal/crypt"
+	"github.com/alexey-mavrin/graduate-2/internal/store"
+)
+
+// secretFileMode is the mode of the cache file and the other files
+// written by WriteSecretFile
+const secretFileMode = 0600
+
+// cacheAD is the additional data authenticated with the cache file content
+var cacheAD = []byte("gosecret cache v1")
+
+// sqliteHeader starts the cache files of the previous versions
+// kept in clear text
+var sqliteHeader = []byte("SQLite format 3\x00")
+
+// cacheContent is the content of the cache file
+type cacheContent struct {
+	User    string         `json:"user"`
+	Records common.Records `json:"records"`
+}
+
+// cacheStore returns the in-memory cache store. The store is loaded
+// from the encrypted cache file on the first use.
+func (c *Client) cacheStore() (*store.Store, error) {
+	if c.Store != nil {
+		return c.Store, nil
+	}
+
+	s, err := store.NewStore(store.MemoryStore)
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.AddUser(common.User{
+		Name: c.UserName,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := c.readCacheFile()
+	if err != nil {
+		return nil, err
+	}
+	for id, record := range content.Records {
+		err = s.StoreRecordWithID(id, c.UserName, record)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	c.Store = s
+	return s, nil
+}
+
+// readCacheFile reads and decrypts the cache file. The cache file that
+// cannot be decrypted, e.g. encrypted with the previous master key,
+// is dropped: the cache is refilled from the server. The clear text
+// cache file of the previous versions is wiped.
+func (c *Client) readCacheFile() (cacheContent, error) {
+	content := cacheContent{
+		User:    c.UserName,
+		Records: make(common.Records),
+	}
+
+	buf, err := os.ReadFile(c.CacheFile)
+	if os.IsNotExist(err) {
+		return content, nil
+	}
+	if err != nil {
+		return content, err
+	}
+
+	if bytes.HasPrefix(buf, sqliteHeader) {
+		log.Printf("clear text cache file %s is wiped", c.CacheFile)
+		return content, wipeFile(c.CacheFile)
+	}
+
+	clearText, err := crypt.DecryptAD(*c.CacheKey, buf, cacheAD)
+	if err != nil {
+		log.Printf("cannot decrypt cache file %s, it is dropped: %v",
+			c.CacheFile, err)
+		return content, nil
+	}
+
+	var stored cacheContent
+	err = json.Unmarshal(clearText, &stored)
+	if err != nil {
+		return content, err
+	}
+	if stored.User != c.UserName {
+		log.Printf("cache file %s belongs to user %s, it is dropped",
+			c.CacheFile, stored.User)
+		return content, nil
+	}
+	if stored.Records != nil {
+		content.Records = stored.Records
+	}
+	return content, nil
+}
+
+// saveCache writes all the cached records to the encrypted cache file.
+// The file is replaced atomically.
+func (c *Client) saveCache() error {
+	if c.Store == nil {
+		return nil
+	}
+	if c.cacheHold > 0 {
+		c.cacheDirty = true
+		return nil
+	}
+	c.cacheDirty = false
+
+	records, err := c.Store.ListRecords(c.UserName)
+	if err != nil {
+		return err
+	}
+	content := cacheContent{
+		User:    c.UserName,
+		Records: make(common.Records),
+	}
+	for id := range records {
+		content.Records[id], err = c.Store.GetRecordByID(c.UserName, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	clearText, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+	return writeEncrypted(c.CacheFile, *c.CacheKey, clearText, cacheAD)
+}
+
+// writeEncrypted encrypts the content and replaces the file
+// with it atomically
+func writeEncrypted(file string, key common.Key, clearText, ad []byte) error {
+	buf, err := crypt.EncryptAD(key, clearText, ad)
+	if err != nil {
+		return err
+	}
+	return WriteSecretFile(file, buf)
+}
+
+// WriteSecretFile replaces the file with the data readable by the owner
+// only. The data is written to the temporary file renamed then, so
+// the file is never left partially written and the mode of the existing
+// file is set too.
+func WriteSecretFile(file string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(file), filepath.Base(file)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	err = tmp.Chmod(secretFileMode)
+	if err == nil {
+		_, err = tmp.Write(data)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// HoldCache defers writing the cache file until the returned function
+// is called, to write the cache file once for a number of records read
+// or changed. The holds nest, the file is written by the last release.
+func (c *Client) HoldCache() func() error {
+	c.cacheHold++
+	released := false
+	return func() error {
+		if released {
+			return nil
+		}
+		released = true
+		c.cacheHold--
+		if c.cacheHold > 0 || !c.cacheDirty || !c.cacheEnabled() {
+			return nil
+		}
+		return c.saveCache()
+	}
+}
+
+// wipeFile overwrites the file with random data and removes it
+func wipeFile(file string) error {
+	f, err := os.OpenFile(file, os.O_WRONLY, 0)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err == nil {
+		_, err = io.CopyN(f, rand.Reader, info.Size())
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Remove(file)
+}
@@ -0,0 +1,158 @@
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"github.com/alexey-mavrin/graduate-2/internal/crypt"
+	"github.com/alexey-mavrin/graduate-2/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_cacheFileEncrypted(t *testing.T) {
+	ts, err := newHTTPServer()
+	require.NoError(t, err)
+	defer ts.Close()
+
+	cacheName := "cache_storage.db"
+	store.DropStore(cacheName)
+	clnt := NewClient(ts.URL, userName, userPass, cacheName, false)
+	clnt.CacheKey = &cacheKey
+
+	_, err = clnt.RegisterUser("Full Name")
+	assert.NoError(t, err)
+
+	record := sealTestRecord(common.Record{
+		Name:   "prod-db-root",
+		Type:   common.NoteRecord,
+		Opaque: "secret opaque",
+		Meta:   "secret meta",
+	})
+	id, err := clnt.StoreRecord(record)
+	assert.NoError(t, err)
+
+	buf, err := os.ReadFile(cacheName)
+	require.NoError(t, err)
+	for _, s := range []string{record.Name, record.Opaque, record.Meta,
+		userName, "Full Name", string(record.Type)} {
+		assert.NotContains(t, string(buf), s)
+	}
+	info, err := os.Stat(cacheName)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(secretFileMode), info.Mode().Perm())
+
+	ts.Close()
+
+	// the cache file is read by the new client with the same key
+	clnt = NewClient(ts.URL, userName, userPass, cacheName, false)
+	clnt.CacheKey = &cacheKey
+	gotRecord, err := clnt.GetRecordByID(id)
+	assert.NoError(t, err)
+	assert.Equal(t, record, gotRecord)
+
+	// the cache file encrypted with another key is dropped
+	otherKey := crypt.MakeKey("another cache key phrase")
+	clnt = NewClient(ts.URL, userName, userPass, cacheName, false)
+	clnt.CacheKey = &otherKey
+	_, err = clnt.GetRecordByID(id)
+	assert.Error(t, err)
+
+	err = clnt.CleanCache()
+	assert.NoError(t, err)
+	_, err = os.Stat(cacheName)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func Test_cacheFileClearTextWiped(t *testing.T) {
+	cacheName := "cache_storage.db"
+	store.DropStore(cacheName)
+
+	// the clear text cache of the previous versions
+	s, err := store.NewStore(cacheName)
+	require.NoError(t, err)
+	_, err = s.AddUser(common.User{Name: userName})
+	require.NoError(t, err)
+	require.NoError(t, s.CloseDB())
+
+	clnt := NewClient("http://localhost:1", userName, userPass, cacheName, false)
+	clnt.CacheKey = &cacheKey
+	records, err := clnt.cacheListRecords()
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+
+	_, err = os.Stat(cacheName)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func Test_cacheFileHeld(t *testing.T) {
+	ts, err := newHTTPServer()
+	require.NoError(t, err)
+	defer ts.Close()
+
+	cacheName := "cache_storage.db"
+	store.DropStore(cacheName)
+	defer store.DropStore(cacheName)
+	clnt := NewClient(ts.URL, userName, userPass, cacheName, false)
+	clnt.CacheKey = &cacheKey
+
+	_, err = clnt.RegisterUser("")
+	require.NoError(t, err)
+
+	// nothing is written for the records read while held
+	release := clnt.HoldCache()
+	inner := clnt.HoldCache()
+	var ids []int64
+	for _, name := range []string{"rec1", "rec2"} {
+		id, err := clnt.StoreRecord(sealTestRecord(common.Record{
+			Name: name,
+			Type: common.NoteRecord,
+		}))
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+	assert.NoError(t, inner())
+	_, err = os.Stat(cacheName)
+	assert.True(t, os.IsNotExist(err))
+
+	// the cache file is written once by the last release
+	assert.NoError(t, release())
+	assert.NoError(t, release())
+	clnt = NewClient(ts.URL, userName, userPass, cacheName, false)
+	clnt.CacheKey = &cacheKey
+	records, err := clnt.cacheListRecords()
+	assert.NoError(t, err)
+	for _, id := range ids {
+		assert.Contains(t, records, id)
+	}
+
+	// the cache file is not rewritten if nothing is changed
+	info, err := os.Stat(cacheName)
+	require.NoError(t, err)
+	require.NoError(t, os.Chtimes(cacheName, info.ModTime(), info.ModTime().Add(-time.Hour)))
+	release = clnt.HoldCache()
+	assert.NoError(t, release())
+	after, err := os.Stat(cacheName)
+	require.NoError(t, err)
+	assert.Equal(t, info.ModTime().Add(-time.Hour), after.ModTime())
+}
+
+func TestWriteSecretFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "secrets.env")
+	require.NoError(t, os.WriteFile(file, []byte("old"), 0644))
+
+	require.NoError(t, WriteSecretFile(file, []byte("DB_PASSWORD=secret")))
+	buf, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "DB_PASSWORD=secret", string(buf))
+	info, err := os.Stat(file)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(secretFileMode), info.Mode().Perm())
+
+	entries, err := os.ReadDir(filepath.Dir(file))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary file is removed")
+}
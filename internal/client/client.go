@@ -3,10 +3,12 @@ package client
 import (
 	"bytes"
 	"crypto/tls"
-	"log"
+	"errors"
 	"net/http"
+	"net/url"
 	"time"
 
+	"github.com/alexey-mavrin/graduate-2/internal/common"
 	"github.com/alexey-mavrin/graduate-2/internal/store"
 )
 
@@ -14,6 +16,10 @@ const (
 	defaultClientTimeout = time.Second * 1
 )
 
+// ErrNoCache is returned when the records are requested from the cache
+// only, but the cache file is not set or the vault records are requested
+var ErrNoCache = errors.New("records are not cached")
+
 // Client describes general client configuration
 type Client struct {
 	ServerAddr    string
@@ -23,6 +29,20 @@ type Client struct {
 	Store         *store.Store
 	Timeout       time.Duration
 	HTTPSInsecure bool
+	// Vault is the name of the shared vault to operate on,
+	// personal records are used if empty
+	Vault string
+	// CacheKey is the key the cache file is encrypted with,
+	// the records are not cached if it is not set
+	CacheKey *common.Key
+	// CacheOnly makes the records read from the cache without
+	// contacting the server
+	CacheOnly bool
+	// cacheHold is the number of the holds deferring writing
+	// the cache file
+	cacheHold int
+	// cacheDirty tells the cache is changed while held
+	cacheDirty bool
 }
 
 // NewClient returns new client
@@ -32,15 +52,6 @@ func NewClient(serverAddr string,
 	cacheFile string,
 	httpsInsecure bool,
 ) *Client {
-	var s *store.Store
-	var err error
-	if cacheFile != "" {
-		s, err = store.NewStore(cacheFile)
-		if err != nil {
-			log.Print(err)
-			return nil
-		}
-	}
 	return &Client{
 		ServerAddr:    serverAddr,
 		UserName:      userName,
@@ -48,7 +59,6 @@ func NewClient(serverAddr string,
 		CacheFile:     cacheFile,
 		Timeout:       defaultClientTimeout,
 		HTTPSInsecure: httpsInsecure,
-		Store:         s,
 	}
 }
 
@@ -65,6 +75,29 @@ func (c *Client) prepaReq(method, path string, body []byte) (*http.Request, erro
 	return req, nil
 }
 
+// recordsPath returns the path prefix of the records to operate on
+func (c *Client) recordsPath() string {
+	if c.Vault != "" {
+		return "/vaults/" + url.PathEscape(c.Vault) + "/records"
+	}
+	return "/records"
+}
+
+// cacheEnabled returns true if the records are to be cached.
+// Vault records are not cached.
+func (c *Client) cacheEnabled() bool {
+	return c.CacheFile != "" && c.CacheKey != nil && c.Vault == ""
+}
+
+// checkCacheOnly returns error if the records are to be read
+// from the cache only, but they are not cached
+func (c *Client) checkCacheOnly() error {
+	if !c.cacheEnabled() {
+		return ErrNoCache
+	}
+	return nil
+}
+
 func (c *Client) httpClient() *http.Client {
 	tr := &http.Transport{
 		TLSClientConfig: &tls.Config{
@@ -0,0 +1,74 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"github.com/alexey-mavrin/graduate-2/internal/store"
+)
+
+// the errors the server responses are matched with by errors.Is
+var (
+	// ErrNotFound is returned when the record, the user or the vault
+	// is not found on the server or in the cache
+	ErrNotFound = store.ErrNotFound
+	// ErrUnauthorized is returned when the user name or password is wrong
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrForbidden is returned when the vault role does not allow
+	// the request
+	ErrForbidden = errors.New("forbidden")
+	// ErrConflict is returned when the request conflicts with the data
+	// stored, the errors of the entities already existing match it too
+	ErrConflict = errors.New("conflict")
+	// ErrAlreadyExists is returned when the user, the vault, the member
+	// or the record with the same type and name already exists
+	ErrAlreadyExists = store.ErrAlreadyExists
+)
+
+// codeErrors are the errors of the response error codes
+var codeErrors = map[common.ErrorCode]error{
+	common.CodeNotFound:      ErrNotFound,
+	common.CodeUnauthorized:  ErrUnauthorized,
+	common.CodeForbidden:     ErrForbidden,
+	common.CodeConflict:      ErrConflict,
+	common.CodeAlreadyExists: ErrAlreadyExists,
+}
+
+// Error is the error response of the server
+type Error struct {
+	StatusCode int
+	common.ErrorResponse
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("http status %d: %s", e.StatusCode, e.Message)
+	if e.Field != "" {
+		msg += ": " + e.Field
+	}
+	return msg
+}
+
+// Is reports whether the error code matches the target error
+func (e *Error) Is(target error) bool {
+	if target == ErrConflict && e.StatusCode == http.StatusConflict {
+		return true
+	}
+	return codeErrors[e.Code] == target
+}
+
+// responseError returns the error of the failed response. The code
+// of the response without the error envelope is derived from the status.
+func responseError(resp *http.Response, body []byte) error {
+	e := &Error{StatusCode: resp.StatusCode}
+	_ = json.Unmarshal(body, &e.ErrorResponse)
+	if e.Code == "" {
+		e.Code = common.StatusErrorCode(resp.StatusCode)
+	}
+	if e.Message == "" {
+		e.Message = http.StatusText(resp.StatusCode)
+	}
+	return e
+}
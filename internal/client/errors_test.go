@@ -0,0 +1,56 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_responseErrors(t *testing.T) {
+	ts, err := newHTTPServer()
+	require.NoError(t, err)
+	defer ts.Close()
+
+	clnt := NewClient(ts.URL, userName, userPass, "", false)
+	_, err = clnt.RegisterUser("")
+	require.NoError(t, err)
+
+	_, err = clnt.RegisterUser("")
+	assert.ErrorIs(t, err, ErrAlreadyExists)
+
+	record := sealTestRecord(common.Record{
+		Name:   "record1",
+		Type:   common.NoteRecord,
+		Opaque: "1111",
+	})
+	id, err := clnt.StoreRecord(record)
+	require.NoError(t, err)
+	_, err = clnt.StoreRecord(record)
+	assert.ErrorIs(t, err, ErrAlreadyExists)
+	assert.ErrorIs(t, err, ErrConflict)
+	assert.NotErrorIs(t, err, ErrNotFound)
+
+	_, err = clnt.GetRecordByID(id + 1)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = clnt.GetRecordID(common.NoteRecord, "record2")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	var respErr *Error
+	record.Opaque = "1111"
+	err = clnt.UpdateRecordByID(id, record)
+	require.True(t, errors.As(err, &respErr))
+	assert.Equal(t, http.StatusBadRequest, respErr.StatusCode)
+	assert.Equal(t, common.CodeInvalidRecord, respErr.Code)
+	assert.Equal(t, "opaque", respErr.Field)
+
+	wrongPass := NewClient(ts.URL, userName, "wrong", "", false)
+	err = wrongPass.VerifyUser()
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	unknown := NewClient(ts.URL, "user2", userPass, "", false)
+	_, err = unknown.GetRecordByID(id)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+}
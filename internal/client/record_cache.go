@@ -6,79 +6,115 @@ import (
 )
 
 func (c *Client) cacheDeleteRecordByID(id int64) error {
-	if c.CacheFile == "" {
+	if !c.cacheEnabled() {
 		return nil
 	}
-	err := c.Store.DeleteRecordByID(c.UserName, id)
+	s, err := c.cacheStore()
 	if err != nil {
 		return err
 	}
-	return nil
+	err = s.DeleteRecordByID(c.UserName, id)
+	if err != nil {
+		return err
+	}
+	return c.saveCache()
 }
 
 func (c *Client) cacheRecordWithID(storeID int64, record common.Record) error {
-	if c.CacheFile == "" {
+	if !c.cacheEnabled() {
 		return nil
 	}
-
-	_, err := c.Store.AddUser(common.User{
-		Name: c.UserName,
-	})
-	if err != nil && err != store.ErrAlreadyExists {
+	s, err := c.cacheStore()
+	if err != nil {
 		return err
 	}
 
-	err = c.Store.DeleteRecordByID(c.UserName, storeID)
+	err = s.DeleteRecordByID(c.UserName, storeID)
 	if err != nil && err != store.ErrNotFound {
 		return err
 	}
 
-	err = c.Store.StoreRecordWithID(storeID, c.UserName, record)
+	err = s.StoreRecordWithID(storeID, c.UserName, record)
 	if err != nil {
 		return err
 	}
-	return nil
+	return c.saveCache()
 }
 
 func (c *Client) cacheGetRecordByID(id int64) (common.Record, error) {
-	if c.CacheFile == "" {
+	if !c.cacheEnabled() {
 		return common.Record{}, nil
 	}
-	return c.Store.GetRecordByID(c.UserName, id)
+	s, err := c.cacheStore()
+	if err != nil {
+		return common.Record{}, err
+	}
+	return s.GetRecordByID(c.UserName, id)
 }
 
 func (c *Client) cacheGetRecordID(t common.RecordType,
 	name string,
 ) (int64, error) {
-	if c.CacheFile == "" {
+	if !c.cacheEnabled() {
 		return 0, nil
 	}
-	return c.Store.GetRecordID(c.UserName, t, name)
+	s, err := c.cacheStore()
+	if err != nil {
+		return 0, err
+	}
+	return s.GetRecordID(c.UserName, t, name)
 }
 
 func (c *Client) cacheGetRecordByTypeName(t common.RecordType,
 	name string,
 ) (common.Record, error) {
-	if c.CacheFile == "" {
+	if !c.cacheEnabled() {
 		return common.Record{}, nil
 	}
-	return c.Store.GetRecordByTypeName(c.UserName, t, name)
+	s, err := c.cacheStore()
+	if err != nil {
+		return common.Record{}, err
+	}
+	return s.GetRecordByTypeName(c.UserName, t, name)
 }
 
 func (c *Client) cacheListRecords() (common.Records, error) {
 	records := make(common.Records)
-	if c.CacheFile == "" {
+	if !c.cacheEnabled() {
 		return records, nil
 	}
-	return c.Store.ListRecords(c.UserName)
+	s, err := c.cacheStore()
+	if err != nil {
+		return records, err
+	}
+	return s.ListRecords(c.UserName)
 }
 
 func (c *Client) cacheListRecordsByType(
 	t common.RecordType,
 ) (common.Records, error) {
 	records := make(common.Records)
-	if c.CacheFile == "" {
+	if !c.cacheEnabled() {
 		return records, nil
 	}
-	return c.Store.ListRecordsByType(c.UserName, t)
+	s, err := c.cacheStore()
+	if err != nil {
+		return records, err
+	}
+	return s.ListRecordsByType(c.UserName, t)
+}
+
+func (c *Client) cacheListRecordsByLabels(t common.RecordType,
+	labels []string,
+) (common.Records, error) {
+	records, err := c.cacheListRecordsByType(t)
+	if err != nil {
+		return records, err
+	}
+	for id, record := range records {
+		if !record.HasLabels(labels) {
+			delete(records, id)
+		}
+	}
+	return records, nil
 }
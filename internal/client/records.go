@@ -2,50 +2,74 @@ package client
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
 
 	"github.com/alexey-mavrin/graduate-2/internal/common"
 )
 
-// ListRecordsByType lists records for the current user
+// ListRecordsByType lists records for the current user,
+// the listing is requested by pages
 func (c *Client) ListRecordsByType(t common.RecordType) (common.Records, error) {
-	var records common.Records
-	path := fmt.Sprintf("/records/by_type/%s", t)
-	req, err := c.prepaReq(http.MethodGet, path, nil)
-	if err != nil {
-		return records, err
-	}
-
-	client := c.httpClient()
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Printf("cannot contact the server: %v, trying local cache", err)
-		records, err := c.cacheListRecordsByType(t)
-		return records, err
-	}
-	defer resp.Body.Close()
+	return c.ListRecordsByLabels(t, nil)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		err = fmt.Errorf(
-			"getting %s list: http status %d",
-			t, resp.StatusCode,
-		)
-		return records, err
+// ListRecordsByLabels lists records of the type having all the label
+// indexes given, the listing is requested by pages
+func (c *Client) ListRecordsByLabels(t common.RecordType,
+	labels []string,
+) (common.Records, error) {
+	records := make(common.Records)
+	if c.CacheOnly {
+		if err := c.checkCacheOnly(); err != nil {
+			return records, err
+		}
+		return c.cacheListRecordsByLabels(t, labels)
+	}
+
+	q := common.RecordQuery{
+		Types:  []common.RecordType{t},
+		Labels: labels,
+		Limit:  common.MaxPageLimit,
+	}
+	for {
+		page, err := c.QueryRecords(q)
+		var netErr *url.Error
+		if errors.As(err, &netErr) {
+			log.Printf("cannot contact the server: %v, trying local cache", err)
+			return c.cacheListRecordsByLabels(t, labels)
+		}
+		if err != nil {
+			return records, fmt.Errorf("getting %s list: %w", t, err)
+		}
+		for _, r := range page.Records {
+			records[r.ID] = r.Record
+		}
+		if page.Next == "" {
+			return records, nil
+		}
+		q.Cursor = page.Next
 	}
+}
 
-	respBody, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return records, err
-	}
+// QueryRecords returns the page of the records listing
+// for the current user or vault
+func (c *Client) QueryRecords(q common.RecordQuery) (common.RecordPage, error) {
+	var page common.RecordPage
+	path := c.recordsPath() + "?" + q.Values().Encode()
+	err := c.doRequest(http.MethodGet, path, nil, &page)
+	return page, err
+}
 
-	err = json.Unmarshal(respBody, &records)
-	if err != nil {
-		return records, err
-	}
-	return records, nil
+// ListRecords lists all records for the current user or vault
+func (c *Client) ListRecords() (common.Records, error) {
+	var records common.Records
+	err := c.doRequest(http.MethodGet, c.recordsPath(), nil, &records)
+	return records, err
 }
 
 // GetRecordID returns ID of the record with the given type and name
@@ -53,8 +77,14 @@ func (c *Client) GetRecordID(t common.RecordType,
 	name string,
 ) (int64, error) {
 	var getIDResp common.StoreRecordResponse
+	if c.CacheOnly {
+		if err := c.checkCacheOnly(); err != nil {
+			return 0, err
+		}
+		return c.cacheGetRecordID(t, name)
+	}
 
-	path := fmt.Sprintf("/records/%s/%s", t, name)
+	path := fmt.Sprintf("%s/%s/%s", c.recordsPath(), t, name)
 	req, err := c.prepaReq(http.MethodGet, path, nil)
 	if err != nil {
 		return 0, err
@@ -68,19 +98,15 @@ func (c *Client) GetRecordID(t common.RecordType,
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		err = fmt.Errorf(
-			"get record: http status %d",
-			resp.StatusCode,
-		)
-		return 0, err
-	}
-
 	respBody, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return 0, err
 	}
 
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("get record: %w", responseError(resp, respBody))
+	}
+
 	err = json.Unmarshal(respBody, &getIDResp)
 	if err != nil {
 		return 0, err
@@ -102,7 +128,7 @@ func (c *Client) DeleteRecordByTypeName(t common.RecordType,
 
 // DeleteRecordByID deletes record record with the given id
 func (c *Client) DeleteRecordByID(id int64) error {
-	path := fmt.Sprintf("/records/%d", id)
+	path := fmt.Sprintf("%s/%d", c.recordsPath(), id)
 	req, err := c.prepaReq(http.MethodDelete, path, nil)
 	if err != nil {
 		return err
@@ -115,22 +141,15 @@ func (c *Client) DeleteRecordByID(id int64) error {
 	}
 	defer resp.Body.Close()
 
-	var status common.StoreRecordResponse
 	respBody, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return err
 	}
-	err = json.Unmarshal(respBody, &status)
-	if err != nil {
-		return err
-	}
 
 	if resp.StatusCode != http.StatusOK {
-		err = fmt.Errorf(
-			"delete record %d: http status %d: %s",
-			id, resp.StatusCode, status.Status,
+		return fmt.Errorf("delete record %d: %w",
+			id, responseError(resp, respBody),
 		)
-		return err
 	}
 
 	err = c.cacheDeleteRecordByID(id)
@@ -155,8 +174,14 @@ func (c *Client) GetRecordByTypeName(t common.RecordType,
 // GetRecordByID returns record with the given id
 func (c *Client) GetRecordByID(id int64) (common.Record, error) {
 	var record common.Record
+	if c.CacheOnly {
+		if err := c.checkCacheOnly(); err != nil {
+			return record, err
+		}
+		return c.cacheGetRecordByID(id)
+	}
 
-	path := fmt.Sprintf("/records/%d", id)
+	path := fmt.Sprintf("%s/%d", c.recordsPath(), id)
 	req, err := c.prepaReq(http.MethodGet, path, nil)
 	if err != nil {
 		return record, err
@@ -171,19 +196,17 @@ func (c *Client) GetRecordByID(id int64) (common.Record, error) {
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		err = fmt.Errorf(
-			"get record: http status %d",
-			resp.StatusCode,
-		)
-		return record, err
-	}
-
 	respBody, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return record, err
 	}
 
+	if resp.StatusCode != http.StatusOK {
+		return record, fmt.Errorf("get record: %w",
+			responseError(resp, respBody),
+		)
+	}
+
 	err = json.Unmarshal(respBody, &record)
 	if err != nil {
 		return record, err
@@ -197,6 +220,18 @@ func (c *Client) GetRecordByID(id int64) (common.Record, error) {
 	return record, nil
 }
 
+// GetCachedRecordByID returns record with the given id from the cache,
+// the record not cached is requested from the server
+func (c *Client) GetCachedRecordByID(id int64) (common.Record, error) {
+	if c.cacheEnabled() {
+		record, err := c.cacheGetRecordByID(id)
+		if err == nil {
+			return record, nil
+		}
+	}
+	return c.GetRecordByID(id)
+}
+
 // UpdateRecordByTypeName updates record with the given type and name
 func (c *Client) UpdateRecordByTypeName(t common.RecordType,
 	name string,
@@ -216,7 +251,7 @@ func (c *Client) UpdateRecordByID(id int64, record common.Record) error {
 		return err
 	}
 
-	path := fmt.Sprintf("/records/%d", id)
+	path := fmt.Sprintf("%s/%d", c.recordsPath(), id)
 	req, err := c.prepaReq(http.MethodPut, path, body)
 	if err != nil {
 		return err
@@ -229,22 +264,14 @@ func (c *Client) UpdateRecordByID(id int64, record common.Record) error {
 	}
 	defer resp.Body.Close()
 
-	var status common.StoreRecordResponse
 	respBody, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return err
 	}
-	err = json.Unmarshal(respBody, &status)
-	if err != nil {
-		return err
-	}
 	if resp.StatusCode != http.StatusOK {
-		err = fmt.Errorf(
-			"updating record: http status %d: %s",
-			resp.StatusCode,
-			status.Status,
+		return fmt.Errorf("updating record: %w",
+			responseError(resp, respBody),
 		)
-		return err
 	}
 
 	err = c.cacheRecordWithID(id, record)
@@ -262,7 +289,7 @@ func (c *Client) StoreRecord(record common.Record) (int64, error) {
 		return 0, err
 	}
 
-	path := fmt.Sprintf("/records")
+	path := c.recordsPath()
 	req, err := c.prepaReq(http.MethodPost, path, body)
 	if err != nil {
 		return 0, err
@@ -275,22 +302,19 @@ func (c *Client) StoreRecord(record common.Record) (int64, error) {
 	}
 	defer resp.Body.Close()
 
-	var status common.StoreRecordResponse
 	respBody, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return 0, err
 	}
-	err = json.Unmarshal(respBody, &status)
-	if err != nil {
-		return 0, err
-	}
-
 	if resp.StatusCode != http.StatusOK {
-		err = fmt.Errorf(
-			"storing record: http status %d: %s",
-			resp.StatusCode,
-			status.Status,
+		return 0, fmt.Errorf("storing record: %w",
+			responseError(resp, respBody),
 		)
+	}
+
+	var status common.StoreRecordResponse
+	err = json.Unmarshal(respBody, &status)
+	if err != nil {
 		return 0, err
 	}
 
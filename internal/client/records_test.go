@@ -1,9 +1,11 @@
 package client
 
 import (
+	"encoding/hex"
 	"testing"
 
 	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"github.com/alexey-mavrin/graduate-2/internal/crypt"
 	"github.com/alexey-mavrin/graduate-2/internal/store"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -14,6 +16,26 @@ const (
 	userPass = "pass"
 )
 
+var cacheKey = crypt.MakeKey("cache key phrase")
+
+// testCipherText returns the well-formed envelope of the text
+// the server accepts, the text is kept readable in the ciphertext
+func testCipherText(text string) string {
+	buf := append(make([]byte, 1+12), text...)
+	buf[0] = 2
+	return hex.EncodeToString(append(buf, make([]byte, 16)...))
+}
+
+// sealTestRecord returns the record with the fields sealed
+// as the records are sent to the server
+func sealTestRecord(r common.Record) common.Record {
+	r.Name = testCipherText(r.Name)
+	r.Opaque = testCipherText(r.Opaque)
+	r.Meta = testCipherText(r.Meta)
+	r.DataKey = testCipherText("key")
+	return r
+}
+
 func Test_records(t *testing.T) {
 	ts, err := newHTTPServer()
 	require.NoError(t, err)
@@ -25,11 +47,11 @@ func Test_records(t *testing.T) {
 	assert.NoError(t, err)
 
 	recType := common.NoteRecord
-	record := common.Record{
+	record := sealTestRecord(common.Record{
 		Name:   "record1",
 		Type:   recType,
 		Opaque: "1111",
-	}
+	})
 
 	id, err := clnt.StoreRecord(record)
 	assert.NoError(t, err)
@@ -38,11 +60,12 @@ func Test_records(t *testing.T) {
 	assert.NoError(t, err)
 	expRecord := record
 	expRecord.Opaque = ""
+	expRecord.Meta = ""
 
 	assert.Equal(t, expRecord, records[id])
 
 	updateRecord := record
-	updateRecord.Opaque = "2222"
+	updateRecord.Opaque = testCipherText("2222")
 
 	err = clnt.UpdateRecordByID(id, updateRecord)
 	assert.NoError(t, err)
@@ -67,15 +90,16 @@ func Test_recordsCache(t *testing.T) {
 	cacheName := "cache_storage.db"
 	store.DropStore(cacheName)
 	clnt := NewClient(ts.URL, userName, userPass, cacheName, false)
+	clnt.CacheKey = &cacheKey
 
 	_, err = clnt.RegisterUser("")
 	assert.NoError(t, err)
 
-	record := common.Record{
+	record := sealTestRecord(common.Record{
 		Name:   "record1",
 		Type:   common.NoteRecord,
 		Opaque: "1111",
-	}
+	})
 
 	id, err := clnt.StoreRecord(record)
 	assert.NoError(t, err)
@@ -88,6 +112,86 @@ func Test_recordsCache(t *testing.T) {
 	assert.Equal(t, gotRecord, record)
 }
 
+func Test_recordsCacheOnly(t *testing.T) {
+	ts, err := newHTTPServer()
+	require.NoError(t, err)
+	defer ts.Close()
+
+	cacheName := "cache_storage.db"
+	store.DropStore(cacheName)
+	clnt := NewClient(ts.URL, userName, userPass, cacheName, false)
+	clnt.CacheKey = &cacheKey
+
+	_, err = clnt.RegisterUser("")
+	assert.NoError(t, err)
+
+	record := sealTestRecord(common.Record{
+		Name:   "record1",
+		Type:   common.NoteRecord,
+		Opaque: "1111",
+	})
+	id, err := clnt.StoreRecord(record)
+	assert.NoError(t, err)
+
+	// the record is deleted on the server only
+	noCache := NewClient(ts.URL, userName, userPass, "", false)
+	err = noCache.DeleteRecordByID(id)
+	assert.NoError(t, err)
+
+	clnt.CacheOnly = true
+	gotID, err := clnt.GetRecordID(record.Type, record.Name)
+	assert.NoError(t, err)
+	assert.Equal(t, id, gotID)
+	gotRecord, err := clnt.GetRecordByID(id)
+	assert.NoError(t, err)
+	assert.Equal(t, record, gotRecord)
+	records, err := clnt.ListRecordsByType(record.Type)
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+
+	noCache.CacheOnly = true
+	_, err = noCache.GetRecordByID(id)
+	assert.ErrorIs(t, err, ErrNoCache)
+}
+
+func Test_recordsCachedFirst(t *testing.T) {
+	ts, err := newHTTPServer()
+	require.NoError(t, err)
+	defer ts.Close()
+
+	cacheName := "cache_storage.db"
+	store.DropStore(cacheName)
+	clnt := NewClient(ts.URL, userName, userPass, cacheName, false)
+	clnt.CacheKey = &cacheKey
+
+	_, err = clnt.RegisterUser("")
+	assert.NoError(t, err)
+
+	record := sealTestRecord(common.Record{
+		Name:   "record1",
+		Type:   common.NoteRecord,
+		Opaque: "1111",
+	})
+	id, err := clnt.StoreRecord(record)
+	assert.NoError(t, err)
+
+	// the record is updated on the server only
+	noCache := NewClient(ts.URL, userName, userPass, "", false)
+	updated := record
+	updated.Opaque = testCipherText("2222")
+	err = noCache.UpdateRecordByID(id, updated)
+	assert.NoError(t, err)
+
+	gotRecord, err := clnt.GetCachedRecordByID(id)
+	assert.NoError(t, err)
+	assert.Equal(t, record, gotRecord)
+
+	// the record not cached is requested from the server
+	gotRecord, err = noCache.GetCachedRecordByID(id)
+	assert.NoError(t, err)
+	assert.Equal(t, updated, gotRecord)
+}
+
 func Test_recordsUpdateCache(t *testing.T) {
 	ts, err := newHTTPServer()
 	require.NoError(t, err)
@@ -96,22 +200,23 @@ func Test_recordsUpdateCache(t *testing.T) {
 	cacheName := "cache_storage.db"
 	store.DropStore(cacheName)
 	clnt := NewClient(ts.URL, userName, userPass, cacheName, false)
+	clnt.CacheKey = &cacheKey
 
 	_, err = clnt.RegisterUser("")
 	assert.NoError(t, err)
 
 	recType := common.NoteRecord
-	record := common.Record{
+	record := sealTestRecord(common.Record{
 		Name:   "record 1",
 		Type:   recType,
 		Opaque: "1111",
-	}
+	})
 
-	recordUpd := common.Record{
+	recordUpd := sealTestRecord(common.Record{
 		Name:   "record 1 update",
 		Type:   recType,
 		Opaque: "2222",
-	}
+	})
 
 	id, err := clnt.StoreRecord(record)
 	assert.NoError(t, err)
@@ -136,15 +241,16 @@ func Test_recordsDeleteCache(t *testing.T) {
 	store.DropStore(cacheName)
 
 	clnt := NewClient(ts.URL, userName, userPass, cacheName, false)
+	clnt.CacheKey = &cacheKey
 
 	_, err = clnt.RegisterUser("")
 	assert.NoError(t, err)
 
-	record := common.Record{
+	record := sealTestRecord(common.Record{
 		Name:   "record1",
 		Type:   common.NoteRecord,
 		Opaque: "1111",
-	}
+	})
 
 	id, err := clnt.StoreRecord(record)
 	assert.NoError(t, err)
@@ -158,3 +264,80 @@ func Test_recordsDeleteCache(t *testing.T) {
 	_, err = clnt.GetRecordByID(id)
 	assert.Error(t, err)
 }
+
+func Test_queryRecords(t *testing.T) {
+	ts, err := newHTTPServer()
+	require.NoError(t, err)
+	defer ts.Close()
+
+	clnt := NewClient(ts.URL, userName, userPass, "", false)
+
+	_, err = clnt.RegisterUser("")
+	assert.NoError(t, err)
+
+	for _, name := range []string{"b", "a", "c"} {
+		_, err = clnt.StoreRecord(sealTestRecord(common.Record{
+			Name:   name,
+			Type:   common.NoteRecord,
+			Opaque: "1111",
+		}))
+		assert.NoError(t, err)
+	}
+
+	page, err := clnt.QueryRecords(common.RecordQuery{Sort: "-id", Limit: 2})
+	assert.NoError(t, err)
+	assert.Len(t, page.Records, 2)
+	assert.Equal(t, testCipherText("c"), page.Records[0].Name)
+	assert.Equal(t, testCipherText("a"), page.Records[1].Name)
+	assert.NotEmpty(t, page.Next)
+
+	records, err := clnt.ListRecordsByType(common.NoteRecord)
+	assert.NoError(t, err)
+	assert.Len(t, records, 3)
+}
+
+func Test_listRecordsByLabels(t *testing.T) {
+	ts, err := newHTTPServer()
+	require.NoError(t, err)
+	defer ts.Close()
+
+	cacheName := "cache_storage.db"
+	store.DropStore(cacheName)
+	clnt := NewClient(ts.URL, userName, userPass, cacheName, false)
+	clnt.CacheKey = &cacheKey
+	defer clnt.CleanCache()
+
+	_, err = clnt.RegisterUser("")
+	assert.NoError(t, err)
+
+	labeled := sealTestRecord(common.Record{
+		Name:       "labeled",
+		Type:       common.NoteRecord,
+		Opaque:     "1111",
+		LabelIndex: []string{"folder1", "tag1"},
+	})
+	id, err := clnt.StoreRecord(labeled)
+	assert.NoError(t, err)
+	_, err = clnt.StoreRecord(sealTestRecord(common.Record{
+		Name:   "plain",
+		Type:   common.NoteRecord,
+		Opaque: "2222",
+	}))
+	assert.NoError(t, err)
+
+	records, err := clnt.ListRecordsByLabels(common.NoteRecord, []string{"tag1"})
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, labeled.LabelIndex, records[id].LabelIndex)
+
+	// the cached records are filtered by the client
+	clnt.CacheOnly = true
+	records, err = clnt.ListRecordsByLabels(common.NoteRecord, []string{"folder1", "tag1"})
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Contains(t, records, id)
+
+	records, err = clnt.ListRecordsByLabels(common.NoteRecord, []string{"tag2"})
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+}
@@ -0,0 +1,86 @@
+package client
+
+import (
+	"log"
+	"os"
+
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"github.com/alexey-mavrin/graduate-2/internal/crypt"
+	"github.com/alexey-mavrin/graduate-2/internal/search"
+)
+
+// searchIndexAD is the additional data authenticated with the search
+// index file content
+var searchIndexAD = []byte("gosecret search index v1")
+
+// searchIndexFile returns the search index file name,
+// it is kept next to the cache file
+func (c *Client) searchIndexFile() string {
+	return c.CacheFile + ".index"
+}
+
+// CachedRecords returns all the cached records
+func (c *Client) CachedRecords() (common.Records, error) {
+	records := make(common.Records)
+	if err := c.checkCacheOnly(); err != nil {
+		return records, err
+	}
+	s, err := c.cacheStore()
+	if err != nil {
+		return records, err
+	}
+	list, err := s.ListRecords(c.UserName)
+	if err != nil {
+		return records, err
+	}
+	for id := range list {
+		records[id], err = s.GetRecordByID(c.UserName, id)
+		if err != nil {
+			return records, err
+		}
+	}
+	return records, nil
+}
+
+// LoadSearchIndex reads and decrypts the search index file. The empty
+// index is returned if the file does not exist or cannot be decrypted:
+// the index is rebuilt from the cache then.
+func (c *Client) LoadSearchIndex() (*search.Index, error) {
+	if err := c.checkCacheOnly(); err != nil {
+		return nil, err
+	}
+	file := c.searchIndexFile()
+	buf, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return search.NewIndex(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	clearText, err := crypt.DecryptAD(*c.CacheKey, buf, searchIndexAD)
+	if err != nil {
+		log.Printf("cannot decrypt search index %s, it is rebuilt: %v",
+			file, err)
+		return search.NewIndex(), nil
+	}
+	ix, err := search.Unmarshal(clearText)
+	if err != nil {
+		log.Printf("cannot read search index %s, it is rebuilt: %v",
+			file, err)
+		return search.NewIndex(), nil
+	}
+	return ix, nil
+}
+
+// SaveSearchIndex writes the search index to the encrypted index file
+func (c *Client) SaveSearchIndex(ix *search.Index) error {
+	if err := c.checkCacheOnly(); err != nil {
+		return err
+	}
+	clearText, err := ix.Marshal()
+	if err != nil {
+		return err
+	}
+	return writeEncrypted(c.searchIndexFile(), *c.CacheKey, clearText, searchIndexAD)
+}
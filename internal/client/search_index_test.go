@@ -0,0 +1,76 @@
+package client
+
+import (
+	"os"
+	"testing"
+
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"github.com/alexey-mavrin/graduate-2/internal/crypt"
+	"github.com/alexey-mavrin/graduate-2/internal/search"
+	"github.com/alexey-mavrin/graduate-2/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_searchIndexFile(t *testing.T) {
+	ts, err := newHTTPServer()
+	require.NoError(t, err)
+	defer ts.Close()
+
+	cacheName := "cache_storage.db"
+	store.DropStore(cacheName)
+	clnt := NewClient(ts.URL, userName, userPass, cacheName, false)
+	clnt.CacheKey = &cacheKey
+
+	_, err = clnt.RegisterUser("")
+	assert.NoError(t, err)
+
+	record := common.Record{
+		Name:   "vpn",
+		Type:   common.NoteRecord,
+		Opaque: `{"text":"office psk"}`,
+	}
+	sealed := sealTestRecord(record)
+	id, err := clnt.StoreRecord(sealed)
+	assert.NoError(t, err)
+
+	records, err := clnt.CachedRecords()
+	assert.NoError(t, err)
+	assert.Equal(t, common.Records{id: sealed}, records)
+
+	ix, err := clnt.LoadSearchIndex()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, ix.Len())
+
+	doc, err := search.RecordDocument(id, record, false)
+	require.NoError(t, err)
+	ix.Add(doc)
+	require.NoError(t, clnt.SaveSearchIndex(ix))
+
+	indexName := cacheName + ".index"
+	buf, err := os.ReadFile(indexName)
+	require.NoError(t, err)
+	for _, s := range []string{"vpn", "office", "psk"} {
+		assert.NotContains(t, string(buf), s)
+	}
+	info, err := os.Stat(indexName)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(secretFileMode), info.Mode().Perm())
+
+	ix, err = clnt.LoadSearchIndex()
+	assert.NoError(t, err)
+	assert.Len(t, ix.Search("office psk"), 1)
+
+	// the index encrypted with another key is rebuilt
+	otherKey := crypt.MakeKey("another cache key phrase")
+	other := NewClient(ts.URL, userName, userPass, cacheName, false)
+	other.CacheKey = &otherKey
+	ix, err = other.LoadSearchIndex()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, ix.Len())
+
+	err = clnt.CleanCache()
+	assert.NoError(t, err)
+	_, err = os.Stat(indexName)
+	assert.True(t, os.IsNotExist(err))
+}
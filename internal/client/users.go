@@ -30,11 +30,11 @@ func (c Client) ChangePassword(user common.User) error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		err = fmt.Errorf(
-			"change password: http status %d",
-			resp.StatusCode,
-		)
-		return err
+		respBody, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("change password: %w", responseError(resp, respBody))
 	}
 
 	return nil
@@ -55,11 +55,11 @@ func (c Client) VerifyUser() error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		err = fmt.Errorf(
-			"verify user: http status %d",
-			resp.StatusCode,
-		)
-		return err
+		respBody, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("verify user: %w", responseError(resp, respBody))
 	}
 
 	return nil
@@ -102,18 +102,17 @@ func (c Client) RegisterUser(fullName string) (int64, error) {
 		return 0, err
 	}
 
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("register user: %w",
+			responseError(resp, respBody),
+		)
+	}
+
 	var addUserResp common.StoreRecordResponse
 	err = json.Unmarshal(respBody, &addUserResp)
 	if err != nil {
 		return 0, err
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return 0, fmt.Errorf("http error %d, %s",
-			resp.StatusCode,
-			addUserResp.Status,
-		)
-	}
-
 	return addUserResp.ID, nil
 }
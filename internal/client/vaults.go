@@ -0,0 +1,163 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+)
+
+// doRequest sends the request with in marshaled as the body
+// and unmarshals the response into out if it is not nil
+func (c *Client) doRequest(method, path string, in, out interface{}) error {
+	var body []byte
+	var err error
+	if in != nil {
+		body, err = json.Marshal(in)
+		if err != nil {
+			return err
+		}
+	}
+
+	req, err := c.prepaReq(method, path, body)
+	if err != nil {
+		return err
+	}
+
+	client := c.httpClient()
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: %w",
+			method, path, responseError(resp, respBody),
+		)
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(respBody, out)
+}
+
+func vaultPath(vault string) string {
+	return "/vaults/" + url.PathEscape(vault)
+}
+
+// SetPublicKey publishes the public key of the current user
+func (c *Client) SetPublicKey(publicKey string) error {
+	return c.doRequest(http.MethodPut,
+		"/public_key",
+		common.User{PublicKey: publicKey},
+		nil,
+	)
+}
+
+// GetPublicKey returns the public key of the user
+func (c *Client) GetPublicKey(user string) (string, error) {
+	var u common.User
+	err := c.doRequest(http.MethodGet,
+		"/users/"+url.PathEscape(user)+"/public_key",
+		nil,
+		&u,
+	)
+	return u.PublicKey, err
+}
+
+// CreateVault creates new vault owned by the current user.
+// wrappedKey is the vault key wrapped with the user public key.
+func (c *Client) CreateVault(vault, wrappedKey string) (int64, error) {
+	var resp common.StoreRecordResponse
+	err := c.doRequest(http.MethodPost,
+		"/vaults",
+		common.Vault{Name: vault, Key: wrappedKey},
+		&resp,
+	)
+	return resp.ID, err
+}
+
+// ListVaults returns the vaults the current user is member of
+func (c *Client) ListVaults() ([]common.Vault, error) {
+	var vaults []common.Vault
+	err := c.doRequest(http.MethodGet, "/vaults", nil, &vaults)
+	return vaults, err
+}
+
+// DeleteVault deletes the vault with all its records
+func (c *Client) DeleteVault(vault string) error {
+	return c.doRequest(http.MethodDelete, vaultPath(vault), nil, nil)
+}
+
+// ListVaultMembers returns the members of the vault
+func (c *Client) ListVaultMembers(vault string) ([]common.VaultMember, error) {
+	var members []common.VaultMember
+	err := c.doRequest(http.MethodGet,
+		vaultPath(vault)+"/members",
+		nil,
+		&members,
+	)
+	return members, err
+}
+
+// AddVaultMember adds the member to the vault.
+// member.Key is to be wrapped with the member public key.
+func (c *Client) AddVaultMember(vault string, member common.VaultMember) error {
+	return c.doRequest(http.MethodPost,
+		vaultPath(vault)+"/members",
+		member,
+		nil,
+	)
+}
+
+// RemoveVaultMember removes the member from the vault.
+// The vault key is to be rotated after that.
+func (c *Client) RemoveVaultMember(vault, member string) error {
+	return c.doRequest(http.MethodDelete,
+		vaultPath(vault)+"/members/"+url.PathEscape(member),
+		nil,
+		nil,
+	)
+}
+
+// SetVaultMemberKey replaces the vault key wrapped for the member
+func (c *Client) SetVaultMemberKey(vault, member, wrappedKey string) error {
+	return c.doRequest(http.MethodPut,
+		vaultPath(vault)+"/members/"+url.PathEscape(member),
+		common.VaultMember{Name: member, Key: wrappedKey},
+		nil,
+	)
+}
+
+// GetVaultKey returns the vault key wrapped for the current user
+func (c *Client) GetVaultKey(vault string) (string, error) {
+	var member common.VaultMember
+	err := c.doRequest(http.MethodGet,
+		vaultPath(vault)+"/key",
+		nil,
+		&member,
+	)
+	return member.Key, err
+}
+
+// RotateVaultKey replaces the vault key for all the members
+// and all the vault records at once
+func (c *Client) RotateVaultKey(vault string,
+	rotation common.VaultKeyRotation,
+) error {
+	return c.doRequest(http.MethodPut,
+		vaultPath(vault)+"/key",
+		rotation,
+		nil,
+	)
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_vaults(t *testing.T) {
+	ts, err := newHTTPServer()
+	require.NoError(t, err)
+	defer ts.Close()
+
+	vault := "team"
+	owner := NewClient(ts.URL, userName, userPass, "", false)
+	member := NewClient(ts.URL, "user2", "pass2", "", false)
+
+	for _, clnt := range []*Client{owner, member} {
+		_, err = clnt.RegisterUser("")
+		require.NoError(t, err)
+	}
+
+	err = member.SetPublicKey("member public key")
+	assert.NoError(t, err)
+	pub, err := owner.GetPublicKey("user2")
+	assert.NoError(t, err)
+	assert.Equal(t, "member public key", pub)
+
+	_, err = owner.CreateVault(vault, "owner key")
+	assert.NoError(t, err)
+
+	err = owner.AddVaultMember(vault, common.VaultMember{
+		Name: "user2",
+		Role: common.VaultReader,
+		Key:  "member key",
+	})
+	assert.NoError(t, err)
+
+	vaults, err := member.ListVaults()
+	assert.NoError(t, err)
+	assert.Equal(t, []common.Vault{{Name: vault, Role: common.VaultReader}}, vaults)
+
+	key, err := member.GetVaultKey(vault)
+	assert.NoError(t, err)
+	assert.Equal(t, "member key", key)
+
+	record := sealTestRecord(common.Record{
+		Name:   "record1",
+		Type:   common.NoteRecord,
+		Opaque: "1111",
+	})
+
+	owner.Vault = vault
+	id, err := owner.StoreRecord(record)
+	assert.NoError(t, err)
+
+	member.Vault = vault
+	gotRecord, err := member.GetRecordByTypeName(record.Type, record.Name)
+	assert.NoError(t, err)
+	assert.Equal(t, record, gotRecord)
+
+	// reader cannot change the vault records
+	err = member.DeleteRecordByID(id)
+	assert.Error(t, err)
+
+	err = owner.RemoveVaultMember(vault, "user2")
+	assert.NoError(t, err)
+
+	_, err = member.GetRecordByID(id)
+	assert.Error(t, err)
+}
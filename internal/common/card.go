@@ -0,0 +1,181 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CardBrand is the payment system of the card
+type CardBrand string
+
+// card brands detected by the number prefix
+const (
+	BrandVisa       CardBrand = "Visa"
+	BrandMasterCard CardBrand = "MasterCard"
+	BrandMir        CardBrand = "Mir"
+	BrandAmex       CardBrand = "American Express"
+	BrandDiscover   CardBrand = "Discover"
+	BrandJCB        CardBrand = "JCB"
+	BrandDiners     CardBrand = "Diners Club"
+	BrandUnionPay   CardBrand = "UnionPay"
+	BrandMaestro    CardBrand = "Maestro"
+	BrandUnknown    CardBrand = "unknown"
+)
+
+// ErrCardNumber is to indicate the card number is not valid
+var ErrCardNumber = errors.New("invalid card number")
+
+// ErrCardCVC is to indicate the CVC code is not valid
+var ErrCardCVC = errors.New("invalid card CVC code")
+
+// ErrCardExpiry is to indicate the expiry date is not valid
+var ErrCardExpiry = errors.New("invalid card expiry date")
+
+// ErrCardExpired is to indicate the card is valid but already expired
+var ErrCardExpired = errors.New("card is expired")
+
+// cardRule describes the numbers of the brand:
+// the number prefix range, the number lengths and the CVC length
+type cardRule struct {
+	brand    CardBrand
+	from, to int
+	minLen   int
+	maxLen   int
+	cvcLen   int
+}
+
+// cardRules are checked in order, the first one matching
+// the number prefix defines the brand
+var cardRules = []cardRule{
+	{BrandAmex, 34, 34, 15, 15, 4},
+	{BrandAmex, 37, 37, 15, 15, 4},
+	{BrandMir, 2200, 2204, 16, 19, 3},
+	{BrandMasterCard, 51, 55, 16, 16, 3},
+	{BrandMasterCard, 2221, 2720, 16, 16, 3},
+	{BrandVisa, 4, 4, 13, 19, 3},
+	{BrandDiscover, 6011, 6011, 16, 19, 3},
+	{BrandDiscover, 644, 649, 16, 19, 3},
+	{BrandDiscover, 65, 65, 16, 19, 3},
+	{BrandJCB, 3528, 3589, 16, 19, 3},
+	{BrandDiners, 300, 305, 14, 19, 3},
+	{BrandDiners, 36, 36, 14, 19, 3},
+	{BrandDiners, 38, 39, 14, 19, 3},
+	{BrandUnionPay, 62, 62, 16, 19, 3},
+	{BrandMaestro, 50, 50, 12, 19, 3},
+	{BrandMaestro, 56, 58, 12, 19, 3},
+	{BrandMaestro, 6, 6, 12, 19, 3},
+}
+
+// unknownCardRule applies to the numbers of the unknown brands
+var unknownCardRule = cardRule{BrandUnknown, 0, 0, 12, 19, 0}
+
+// cardDigits returns the card number without the spaces and dashes
+func cardDigits(number string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(number)
+}
+
+// findCardRule returns the rule of the card number brand
+func findCardRule(digits string) cardRule {
+	for _, r := range cardRules {
+		prefixLen := len(strconv.Itoa(r.from))
+		if len(digits) < prefixLen {
+			continue
+		}
+		prefix, err := strconv.Atoi(digits[:prefixLen])
+		if err != nil {
+			continue
+		}
+		if prefix >= r.from && prefix <= r.to {
+			return r
+		}
+	}
+	return unknownCardRule
+}
+
+// Brand returns the brand of the card detected by the number
+func (c Card) Brand() CardBrand {
+	return findCardRule(cardDigits(c.Number)).brand
+}
+
+// luhnValid checks the Luhn checksum of the digits
+func luhnValid(digits string) bool {
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if d < 0 || d > 9 {
+			return false
+		}
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// expiryYear returns the four digits expiry year
+func (c Card) expiryYear() int {
+	if c.ExpYear < 100 {
+		return 2000 + c.ExpYear
+	}
+	return c.ExpYear
+}
+
+// Expires returns the time the card expires at:
+// the beginning of the month following the expiry month
+func (c Card) Expires() time.Time {
+	return time.Date(c.expiryYear(), time.Month(c.ExpMonth)+1, 1,
+		0, 0, 0, 0, time.Local)
+}
+
+// checkAt checks the card number, CVC code and expiry date
+// are valid, and the card is not expired at the time given
+func (c Card) checkAt(now time.Time) error {
+	digits := cardDigits(c.Number)
+	rule := findCardRule(digits)
+	if !luhnValid(digits) || len(digits) < rule.minLen || len(digits) > rule.maxLen {
+		return fmt.Errorf("%w for %s", ErrCardNumber, rule.brand)
+	}
+
+	cvcValid := len(c.CVC) == rule.cvcLen ||
+		rule.cvcLen == 0 && (len(c.CVC) == 3 || len(c.CVC) == 4)
+	for _, d := range c.CVC {
+		cvcValid = cvcValid && d >= '0' && d <= '9'
+	}
+	if !cvcValid {
+		return fmt.Errorf("%w for %s", ErrCardCVC, rule.brand)
+	}
+
+	if c.ExpMonth < 1 || c.ExpMonth > 12 {
+		return fmt.Errorf("%w: month %d", ErrCardExpiry, c.ExpMonth)
+	}
+	if year := c.expiryYear(); year < 2000 || year > 2099 {
+		return fmt.Errorf("%w: year %d", ErrCardExpiry, c.ExpYear)
+	}
+
+	if !now.Before(c.Expires()) {
+		return fmt.Errorf("%w: %02d/%d", ErrCardExpired, c.ExpMonth, c.ExpYear)
+	}
+	return nil
+}
+
+// Masked returns the card with the number masked but the last
+// four digits and the CVC code hidden
+func (c Card) Masked() Card {
+	digits := cardDigits(c.Number)
+	if len(digits) > 4 {
+		c.Number = strings.Repeat("*", len(digits)-4) + digits[len(digits)-4:]
+	}
+	if c.CVC != "" {
+		c.CVC = "***"
+	}
+	return c
+}
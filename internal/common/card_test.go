@@ -0,0 +1,80 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCard_Brand(t *testing.T) {
+	tests := []struct {
+		number string
+		want   CardBrand
+	}{
+		{"4111111111111111", BrandVisa},
+		{"5555 5555 5555 4444", BrandMasterCard},
+		{"2221000000000009", BrandMasterCard},
+		{"2200-0000-0000-0004", BrandMir},
+		{"378282246310005", BrandAmex},
+		{"6011111111111117", BrandDiscover},
+		{"3530111333300000", BrandJCB},
+		{"30569309025904", BrandDiners},
+		{"6200000000000005", BrandUnionPay},
+		{"6759649826438453", BrandMaestro},
+		{"9999999999999995", BrandUnknown},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Card{Number: tt.number}.Brand(), tt.number)
+	}
+}
+
+func TestCard_checkAt(t *testing.T) {
+	now := time.Date(2022, 5, 15, 12, 0, 0, 0, time.Local)
+	valid := Card{
+		Holder:   "Card Holder",
+		Number:   "4111 1111 1111 1111",
+		ExpMonth: 5,
+		ExpYear:  2022,
+		CVC:      "123",
+	}
+	tests := []struct {
+		name    string
+		change  func(c *Card)
+		wantErr error
+	}{
+		{"valid", func(c *Card) {}, nil},
+		{"short year", func(c *Card) { c.ExpYear = 22 }, nil},
+		{"luhn", func(c *Card) { c.Number = "4111111111111112" }, ErrCardNumber},
+		{"length", func(c *Card) { c.Number = "411111111111" }, ErrCardNumber},
+		{"letters", func(c *Card) { c.Number = "4111a11111111111" }, ErrCardNumber},
+		{"amex", func(c *Card) {
+			c.Number = "378282246310005"
+			c.CVC = "1234"
+		}, nil},
+		{"amex cvc", func(c *Card) { c.Number = "378282246310005" }, ErrCardCVC},
+		{"visa cvc", func(c *Card) { c.CVC = "1234" }, ErrCardCVC},
+		{"cvc digits", func(c *Card) { c.CVC = "12a" }, ErrCardCVC},
+		{"month", func(c *Card) { c.ExpMonth = 13 }, ErrCardExpiry},
+		{"year", func(c *Card) { c.ExpYear = 1999 }, ErrCardExpiry},
+		{"expired", func(c *Card) { c.ExpMonth = 4 }, ErrCardExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid
+			tt.change(&c)
+			err := c.checkAt(now)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCard_Masked(t *testing.T) {
+	c := Card{Number: "4111 1111 1111 1234", CVC: "123"}.Masked()
+	assert.Equal(t, "************1234", c.Number)
+	assert.Equal(t, "***", c.CVC)
+}
@@ -0,0 +1,58 @@
+package common
+
+import "net/http"
+
+// ErrorCode is the machine-readable code of the failed request
+type ErrorCode string
+
+// the error codes of the server responses
+const (
+	CodeBadRequest    ErrorCode = "bad_request"
+	CodeUnauthorized  ErrorCode = "unauthorized"
+	CodeForbidden     ErrorCode = "forbidden"
+	CodeNotFound      ErrorCode = "not_found"
+	CodeConflict      ErrorCode = "conflict"
+	CodeAlreadyExists ErrorCode = "already_exists"
+	CodeUnknownType   ErrorCode = "unknown_type"
+	CodeInvalidRecord ErrorCode = "invalid_record"
+	CodeTooLarge      ErrorCode = "too_large"
+	CodeInternal      ErrorCode = "internal"
+)
+
+// StatusError is the status of the error responses
+const StatusError = "error"
+
+// ErrorResponse is the response of the failed request. Field is
+// the JSON name of the request field rejected, if any.
+type ErrorResponse struct {
+	Status  string    `json:"status"`
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+	Field   string    `json:"field,omitempty"`
+}
+
+// StatusResponse is the response of the request succeeded
+// with no data to return
+type StatusResponse struct {
+	Status string `json:"status"`
+}
+
+// StatusErrorCode returns the error code of the HTTP status,
+// the responses without the code are treated by their status
+func StatusErrorCode(status int) ErrorCode {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusRequestEntityTooLarge:
+		return CodeTooLarge
+	}
+	return CodeInternal
+}
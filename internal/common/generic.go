@@ -0,0 +1,272 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// custom field types
+const (
+	FieldText   FieldType = "text"
+	FieldSecret FieldType = "secret"
+	FieldURL    FieldType = "url"
+	FieldNumber FieldType = "number"
+	FieldDate   FieldType = "date"
+	FieldTOTP   FieldType = "totp"
+)
+
+// DateLayout is the layout of the date fields
+const DateLayout = "2006-01-02"
+
+// fieldRequired is the field spec suffix of the required fields
+const fieldRequired = "required"
+
+// ErrBadField is to indicate the custom field is not valid
+var ErrBadField = errors.New("invalid field")
+
+var fieldTypes = map[FieldType]bool{
+	FieldText:   true,
+	FieldSecret: true,
+	FieldURL:    true,
+	FieldNumber: true,
+	FieldDate:   true,
+	FieldTOTP:   true,
+}
+
+// Valid returns true if the field type is known
+func (t FieldType) Valid() bool {
+	return fieldTypes[t]
+}
+
+// IsSecret returns true if the field value should not be shown
+// unless requested
+func (t FieldType) IsSecret() bool {
+	return t == FieldSecret || t == FieldTOTP
+}
+
+// ParseField parses the field NAME[:TYPE]=VALUE, the type is left empty
+// if not set to be taken from the schema
+func ParseField(s string) (Field, error) {
+	spec, value, ok := strings.Cut(s, "=")
+	if !ok {
+		return Field{}, fmt.Errorf("%w %q: want NAME[:TYPE]=VALUE", ErrBadField, s)
+	}
+	name, t, _ := strings.Cut(spec, ":")
+	f := Field{Name: name, Type: FieldType(t), Value: value}
+	if f.Name == "" || f.Type != "" && !f.Type.Valid() {
+		return Field{}, fmt.Errorf("%w %q: want NAME[:TYPE]=VALUE", ErrBadField, s)
+	}
+	return f, nil
+}
+
+// ParseFieldSpec parses the schema field NAME[:TYPE][:required],
+// the type is text if not set
+func ParseFieldSpec(s string) (FieldSpec, error) {
+	parts := strings.Split(s, ":")
+	spec := FieldSpec{Name: parts[0], Type: FieldText}
+	if len(parts) > 1 && parts[len(parts)-1] == fieldRequired {
+		spec.Required = true
+		parts = parts[:len(parts)-1]
+	}
+	if len(parts) == 2 {
+		spec.Type = FieldType(parts[1])
+	}
+	if spec.Name == "" || len(parts) > 2 || !spec.Type.Valid() {
+		return FieldSpec{}, fmt.Errorf("%w %q: want NAME[:TYPE][:%s]",
+			ErrBadField, s, fieldRequired)
+	}
+	return spec, nil
+}
+
+// Check checks the field value matches the field type,
+// the empty values are not checked
+func (f Field) Check() error {
+	if f.Name == "" {
+		return fmt.Errorf("%w: no name", ErrBadField)
+	}
+	if !f.Type.Valid() {
+		return fmt.Errorf("%w %s: unknown type %q", ErrBadField, f.Name, f.Type)
+	}
+	if f.Value == "" {
+		return nil
+	}
+	var err error
+	switch f.Type {
+	case FieldURL:
+		var u *url.URL
+		u, err = url.Parse(f.Value)
+		if err == nil && (u.Scheme == "" || u.Host == "") {
+			err = errors.New("no scheme or host")
+		}
+	case FieldNumber:
+		_, err = strconv.ParseFloat(f.Value, 64)
+	case FieldDate:
+		_, err = time.Parse(DateLayout, f.Value)
+	case FieldTOTP:
+		_, err = TOTP{Secret: f.Value}.Key()
+	}
+	if err != nil {
+		return fmt.Errorf("%w %s: %s value: %v", ErrBadField, f.Name, f.Type, err)
+	}
+	return nil
+}
+
+// Field returns the field by name
+func (g Generic) Field(name string) (Field, bool) {
+	for _, f := range g.Fields {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+// Set returns the fields with the fields given set: the fields
+// are replaced by name or added, the fields with empty value are removed
+func (g Generic) Set(fields []Field) Generic {
+	result := Generic{Schema: g.Schema}
+	set := make(map[string]Field, len(fields))
+	for _, f := range fields {
+		set[f.Name] = f
+	}
+	for _, f := range g.Fields {
+		if n, ok := set[f.Name]; ok {
+			if n.Type == "" {
+				n.Type = f.Type
+			}
+			f = n
+			delete(set, f.Name)
+		}
+		if f.Value != "" {
+			result.Fields = append(result.Fields, f)
+		}
+	}
+	for _, f := range fields {
+		if _, ok := set[f.Name]; ok && f.Value != "" {
+			result.Fields = append(result.Fields, f)
+		}
+	}
+	return result
+}
+
+// Masked returns the generic record with the secret field values hidden
+func (g Generic) Masked() Generic {
+	fields := make([]Field, len(g.Fields))
+	for i, f := range g.Fields {
+		if f.Type.IsSecret() && f.Value != "" {
+			f.Value = "***"
+		}
+		fields[i] = f
+	}
+	g.Fields = fields
+	return g
+}
+
+// Pack converts Generic to string
+func (g Generic) Pack() (string, error) {
+	opaque, err := json.Marshal(g)
+	if err != nil {
+		return "", err
+	}
+	return string(opaque), nil
+}
+
+// Check checks the fields are named uniquely and their values
+// match their types
+func (g Generic) Check() error {
+	names := make(map[string]bool, len(g.Fields))
+	for _, f := range g.Fields {
+		if err := f.Check(); err != nil {
+			return err
+		}
+		if names[f.Name] {
+			return fmt.Errorf("%w %s: duplicate", ErrBadField, f.Name)
+		}
+		names[f.Name] = true
+	}
+	return nil
+}
+
+// Pack converts Schema to string
+func (s Schema) Pack() (string, error) {
+	opaque, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+	return string(opaque), nil
+}
+
+// Check checks the schema fields are named uniquely and typed
+func (s Schema) Check() error {
+	if len(s.Fields) == 0 {
+		return fmt.Errorf("Fields: %w", ErrDefaultFields)
+	}
+	names := make(map[string]bool, len(s.Fields))
+	for _, spec := range s.Fields {
+		if err := (Field{Name: spec.Name, Type: spec.Type}).Check(); err != nil {
+			return err
+		}
+		if names[spec.Name] {
+			return fmt.Errorf("%w %s: duplicate", ErrBadField, spec.Name)
+		}
+		names[spec.Name] = true
+	}
+	return nil
+}
+
+// Apply returns the generic record with the field types unset taken
+// from the schema, the fields unknown to the schema are text by default
+func (s Schema) Apply(g Generic) Generic {
+	types := make(map[string]FieldType, len(s.Fields))
+	for _, spec := range s.Fields {
+		types[spec.Name] = spec.Type
+	}
+	fields := make([]Field, len(g.Fields))
+	for i, f := range g.Fields {
+		if f.Type == "" {
+			f.Type = types[f.Name]
+		}
+		if f.Type == "" {
+			f.Type = FieldText
+		}
+		fields[i] = f
+	}
+	g.Fields = fields
+	return g
+}
+
+// SchemaGeneric is the generic record checked against its schema.
+// It is packed as the generic record, the schema is not stored with it.
+type SchemaGeneric struct {
+	Generic
+	Spec Schema
+}
+
+// Check checks the generic record has the required fields of the schema
+// set and the schema fields are of the schema types. The fields unknown
+// to the schema are allowed.
+func (g SchemaGeneric) Check() error {
+	if err := g.Generic.Check(); err != nil {
+		return err
+	}
+	var missing []string
+	for _, spec := range g.Spec.Fields {
+		f, ok := g.Field(spec.Name)
+		if ok && f.Type != spec.Type {
+			return fmt.Errorf("%w %s: want %s, got %s",
+				ErrBadField, f.Name, spec.Type, f.Type)
+		}
+		if spec.Required && f.Value == "" {
+			missing = append(missing, spec.Name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s: %w", strings.Join(missing, ", "), ErrDefaultFields)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseField(t *testing.T) {
+	f, err := ParseField("port:number=5432")
+	assert.NoError(t, err)
+	assert.Equal(t, Field{Name: "port", Type: FieldNumber, Value: "5432"}, f)
+
+	f, err = ParseField("dsn=host=db user=app")
+	assert.NoError(t, err)
+	assert.Equal(t, Field{Name: "dsn", Value: "host=db user=app"}, f)
+
+	for _, s := range []string{"port", "=1", "port:float=1"} {
+		_, err = ParseField(s)
+		assert.ErrorIs(t, err, ErrBadField, s)
+	}
+}
+
+func TestParseFieldSpec(t *testing.T) {
+	tests := []struct {
+		spec string
+		want FieldSpec
+	}{
+		{"host", FieldSpec{Name: "host", Type: FieldText}},
+		{"host:required", FieldSpec{Name: "host", Type: FieldText, Required: true}},
+		{"port:number", FieldSpec{Name: "port", Type: FieldNumber}},
+		{"key:secret:required", FieldSpec{Name: "key", Type: FieldSecret, Required: true}},
+	}
+	for _, tt := range tests {
+		spec, err := ParseFieldSpec(tt.spec)
+		assert.NoError(t, err, tt.spec)
+		assert.Equal(t, tt.want, spec)
+	}
+	for _, s := range []string{"", ":number", "port:float", "a:text:b:required"} {
+		_, err := ParseFieldSpec(s)
+		assert.ErrorIs(t, err, ErrBadField, s)
+	}
+}
+
+func TestField_Check(t *testing.T) {
+	tests := []struct {
+		field Field
+		valid bool
+	}{
+		{Field{"note", FieldText, "any text"}, true},
+		{Field{"endpoint", FieldURL, "https://api.example.com/v1"}, true},
+		{Field{"endpoint", FieldURL, "api.example.com"}, false},
+		{Field{"port", FieldNumber, "5432"}, true},
+		{Field{"port", FieldNumber, "five"}, false},
+		{Field{"expires", FieldDate, "2024-02-29"}, true},
+		{Field{"expires", FieldDate, "29.02.2024"}, false},
+		{Field{"otp", FieldTOTP, "JBSWY3DPEHPK3PXP"}, true},
+		{Field{"otp", FieldTOTP, "not base32!"}, false},
+		{Field{"port", FieldNumber, ""}, true},
+		{Field{"", FieldText, "x"}, false},
+		{Field{"x", "float", "1"}, false},
+	}
+	for _, tt := range tests {
+		err := tt.field.Check()
+		if tt.valid {
+			assert.NoError(t, err, tt.field)
+		} else {
+			assert.ErrorIs(t, err, ErrBadField, tt.field)
+		}
+	}
+}
+
+func TestGeneric_Set(t *testing.T) {
+	g := Generic{Schema: "db", Fields: []Field{
+		{"host", FieldText, "db1"},
+		{"port", FieldNumber, "5432"},
+		{"password", FieldSecret, "old"},
+	}}
+	g = g.Set([]Field{
+		{Name: "host", Value: "db2"},
+		{Name: "password", Value: ""},
+		{Name: "db", Type: FieldText, Value: "app"},
+	})
+	assert.Equal(t, Generic{Schema: "db", Fields: []Field{
+		{"host", FieldText, "db2"},
+		{"port", FieldNumber, "5432"},
+		{"db", FieldText, "app"},
+	}}, g)
+}
+
+func TestGeneric_Masked(t *testing.T) {
+	g := Generic{Fields: []Field{
+		{"host", FieldText, "db1"},
+		{"password", FieldSecret, "pw"},
+		{"otp", FieldTOTP, ""},
+	}}
+	assert.Equal(t, []Field{
+		{"host", FieldText, "db1"},
+		{"password", FieldSecret, "***"},
+		{"otp", FieldTOTP, ""},
+	}, g.Masked().Fields)
+	assert.Equal(t, "pw", g.Fields[1].Value)
+}
+
+func TestSchemaGeneric_Check(t *testing.T) {
+	schema := Schema{Fields: []FieldSpec{
+		{Name: "host", Type: FieldText, Required: true},
+		{Name: "port", Type: FieldNumber, Required: true},
+		{Name: "password", Type: FieldSecret},
+	}}
+	require.NoError(t, schema.Check())
+
+	g := schema.Apply(Generic{Schema: "db", Fields: []Field{
+		{Name: "host", Value: "db1"},
+		{Name: "port", Value: "5432"},
+		{Name: "comment", Value: "primary"},
+	}})
+	assert.Equal(t, FieldNumber, g.Fields[1].Type)
+	assert.Equal(t, FieldText, g.Fields[2].Type)
+	assert.NoError(t, SchemaGeneric{Generic: g, Spec: schema}.Check())
+
+	opaque, err := SchemaGeneric{Generic: g, Spec: schema}.Pack()
+	assert.NoError(t, err)
+	assert.NotContains(t, opaque, "required")
+
+	missing := Generic{Fields: []Field{{"host", FieldText, "db1"}}}
+	assert.ErrorIs(t, SchemaGeneric{Generic: missing, Spec: schema}.Check(),
+		ErrDefaultFields)
+
+	mistyped := Generic{Fields: []Field{
+		{"host", FieldText, "db1"},
+		{"port", FieldText, "5432"},
+	}}
+	assert.ErrorIs(t, SchemaGeneric{Generic: mistyped, Spec: schema}.Check(),
+		ErrBadField)
+
+	duplicate := Generic{Fields: []Field{{"a", FieldText, "1"}, {"a", FieldText, "2"}}}
+	assert.ErrorIs(t, duplicate.Check(), ErrBadField)
+
+	assert.ErrorIs(t, Schema{}.Check(), ErrDefaultFields)
+}
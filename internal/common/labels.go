@@ -0,0 +1,71 @@
+package common
+
+import (
+	"sort"
+	"strings"
+)
+
+// FolderSeparator separates the folder path elements
+const FolderSeparator = "/"
+
+// CleanFolder returns the folder path without empty elements and
+// the spaces around them, e.g. "work/db" for " /work// db/"
+func CleanFolder(folder string) string {
+	var elems []string
+	for _, elem := range strings.Split(folder, FolderSeparator) {
+		elem = strings.TrimSpace(elem)
+		if elem != "" {
+			elems = append(elems, elem)
+		}
+	}
+	return strings.Join(elems, FolderSeparator)
+}
+
+// FolderPrefixes returns the folder and all its parent folders,
+// the record in the folder is listed in each of them
+func FolderPrefixes(folder string) []string {
+	folder = CleanFolder(folder)
+	if folder == "" {
+		return nil
+	}
+	elems := strings.Split(folder, FolderSeparator)
+	prefixes := make([]string, len(elems))
+	for i := range elems {
+		prefixes[i] = strings.Join(elems[:i+1], FolderSeparator)
+	}
+	return prefixes
+}
+
+// CleanTags returns the sorted tags without the spaces around them,
+// empty tags and duplicates
+func CleanTags(tags []string) []string {
+	seen := make(map[string]bool)
+	var clean []string
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		clean = append(clean, tag)
+	}
+	sort.Strings(clean)
+	return clean
+}
+
+// HasLabels reports if the record has all the label indexes given
+func (r Record) HasLabels(labels []string) bool {
+	for _, label := range labels {
+		found := false
+		for _, l := range r.LabelIndex {
+			if l == label {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
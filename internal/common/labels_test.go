@@ -0,0 +1,28 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCleanFolder(t *testing.T) {
+	assert.Equal(t, "work/db", CleanFolder(" /work// db/"))
+	assert.Equal(t, "", CleanFolder("//"))
+	assert.Equal(t, []string{"work", "work/db", "work/db/prod"},
+		FolderPrefixes("work/db//prod/"))
+	assert.Nil(t, FolderPrefixes(" / "))
+}
+
+func TestCleanTags(t *testing.T) {
+	assert.Equal(t, []string{"prod", "vpn"},
+		CleanTags([]string{" vpn", "prod", "", "vpn "}))
+	assert.Nil(t, CleanTags([]string{" "}))
+}
+
+func TestRecord_HasLabels(t *testing.T) {
+	r := Record{LabelIndex: []string{"a", "b"}}
+	assert.True(t, r.HasLabels(nil))
+	assert.True(t, r.HasLabels([]string{"b", "a"}))
+	assert.False(t, r.HasLabels([]string{"a", "c"}))
+}
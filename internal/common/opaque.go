@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 )
 
 // Opaque is the type fit for "sub-record" of the record
@@ -39,6 +40,24 @@ func (c Card) Pack() (string, error) {
 	return string(opaque), nil
 }
 
+// Pack converts TOTP to string
+func (t TOTP) Pack() (string, error) {
+	opaque, err := json.Marshal(t)
+	if err != nil {
+		return "", err
+	}
+	return string(opaque), nil
+}
+
+// Pack converts SSHKey to string
+func (k SSHKey) Pack() (string, error) {
+	opaque, err := json.Marshal(k)
+	if err != nil {
+		return "", err
+	}
+	return string(opaque), nil
+}
+
 // Pack returns Data field of Binary
 func (b Binary) Pack() (string, error) {
 	return b.Data, nil
@@ -70,6 +89,35 @@ func (c Card) Check() error {
 		return fmt.Errorf("Holder, Number, ExpMonth, ExpYear or CVC: %w",
 			ErrDefaultFields)
 	}
+	return c.checkAt(time.Now())
+}
+
+// Check checks TOTP struct for correctness
+func (t TOTP) Check() error {
+	if t.Secret == "" || t.Algorithm == "" || t.Digits == 0 || t.Period == 0 {
+		return fmt.Errorf("Secret, Algorithm, Digits or Period: %w",
+			ErrDefaultFields)
+	}
+	if _, err := t.Key(); err != nil {
+		return err
+	}
+	if _, err := t.Hash(); err != nil {
+		return err
+	}
+	if t.Digits < 6 || t.Digits > 8 {
+		return fmt.Errorf("TOTP digits %d: should be from 6 to 8", t.Digits)
+	}
+	if t.Period < 0 {
+		return fmt.Errorf("TOTP period %d: should be positive", t.Period)
+	}
+	return nil
+}
+
+// Check checks SSHKey struct for correctness
+func (k SSHKey) Check() error {
+	if k.PrivateKey == "" || k.PublicKey == "" {
+		return fmt.Errorf("PrivateKey or PublicKey: %w", ErrDefaultFields)
+	}
 	return nil
 }
 
@@ -78,3 +126,10 @@ func (b Binary) Check() error {
 	// do not expose any requirements on the Binary content
 	return nil
 }
+
+// Masked returns the SSH key with the private key and the passphrase hidden
+func (k SSHKey) Masked() SSHKey {
+	k.PrivateKey = ""
+	k.Passphrase = ""
+	return k
+}
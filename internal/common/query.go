@@ -0,0 +1,170 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// record listing sort orders, the "-" prefix reverses the order
+const (
+	SortByID       = "id"
+	SortByType     = "type"
+	SortByModified = "modified"
+)
+
+const (
+	// DefaultPageLimit is the number of records in the page
+	// if the limit is not set
+	DefaultPageLimit = 100
+	// MaxPageLimit is the maximum number of records in the page
+	MaxPageLimit = 1000
+)
+
+// record listing query parameters
+const (
+	queryType          = "type"
+	queryLabel         = "label"
+	queryModifiedSince = "modified_since"
+	querySort          = "sort"
+	queryLimit         = "limit"
+	queryCursor        = "cursor"
+)
+
+// queryNameFilters are the name filters not supported: the names are
+// encrypted client-side, so the records are filtered by name
+// on the client
+var queryNameFilters = []string{"name_prefix", "name_contains"}
+
+// ErrBadQuery is returned when the listing query is malformed
+var ErrBadQuery = errors.New("bad records query")
+
+// RecordQuery filters, orders and pages the records listing.
+// The records are neither filtered nor sorted by name, the encrypted
+// names are not known to the server. Labels are the blind indexes of the folder and tags the records
+// must all have. Cursor is the next page token of the previous page.
+type RecordQuery struct {
+	Types         []RecordType
+	Labels        []string
+	ModifiedSince time.Time
+	Sort          string
+	Limit         int
+	Cursor        string
+}
+
+// ListedRecord is the record of the ordered listing with the name,
+// type, data key, name index, folder and tags fields filled
+type ListedRecord struct {
+	ID int64 `json:"id"`
+	Record
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// RecordPage is the page of the ordered records listing, Next is
+// the token of the next page, it is empty for the last page
+type RecordPage struct {
+	Records []ListedRecord `json:"records"`
+	Next    string         `json:"next,omitempty"`
+}
+
+// IsRecordQuery reports if the listing query parameters are set,
+// the records are listed by pages then
+func IsRecordQuery(v url.Values) bool {
+	params := append([]string{queryType, queryLabel, queryModifiedSince,
+		querySort, queryLimit, queryCursor}, queryNameFilters...)
+	for _, p := range params {
+		if _, ok := v[p]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// SortKey returns the sort field and the order of the query,
+// the records are sorted by ID by default
+func (q RecordQuery) SortKey() (string, bool, error) {
+	key := strings.TrimPrefix(q.Sort, "-")
+	desc := key != q.Sort
+	switch key {
+	case "":
+		return SortByID, desc, nil
+	case SortByID, SortByType, SortByModified:
+		return key, desc, nil
+	}
+	return "", false, fmt.Errorf("%w: unknown sort %q", ErrBadQuery, q.Sort)
+}
+
+// Values returns the query parameters
+func (q RecordQuery) Values() url.Values {
+	v := url.Values{}
+	for _, t := range q.Types {
+		v.Add(queryType, string(t))
+	}
+	for _, l := range q.Labels {
+		v.Add(queryLabel, l)
+	}
+	if !q.ModifiedSince.IsZero() {
+		v.Set(queryModifiedSince, q.ModifiedSince.Format(time.RFC3339Nano))
+	}
+	if q.Sort != "" {
+		v.Set(querySort, q.Sort)
+	}
+	v.Set(queryLimit, strconv.Itoa(q.Limit))
+	if q.Cursor != "" {
+		v.Set(queryCursor, q.Cursor)
+	}
+	return v
+}
+
+// ParseRecordQuery returns the listing query of the parameters.
+// The types may be given by several parameters or comma separated.
+func ParseRecordQuery(v url.Values) (RecordQuery, error) {
+	q := RecordQuery{
+		Sort:   v.Get(querySort),
+		Cursor: v.Get(queryCursor),
+		Limit:  DefaultPageLimit,
+	}
+	for _, p := range queryNameFilters {
+		if _, ok := v[p]; ok {
+			return q, fmt.Errorf("%w: %s is not supported, the names are encrypted",
+				ErrBadQuery, p)
+		}
+	}
+	for _, types := range v[queryType] {
+		for _, t := range strings.Split(types, ",") {
+			if t != "" {
+				q.Types = append(q.Types, RecordType(t))
+			}
+		}
+	}
+	for _, l := range v[queryLabel] {
+		if l == "" || strings.ContainsAny(l, " ") {
+			return q, fmt.Errorf("%w: bad label %q", ErrBadQuery, l)
+		}
+		q.Labels = append(q.Labels, l)
+	}
+	if since := v.Get(queryModifiedSince); since != "" {
+		var err error
+		q.ModifiedSince, err = time.Parse(time.RFC3339Nano, since)
+		if err != nil {
+			return q, fmt.Errorf("%w: %s: %v", ErrBadQuery, queryModifiedSince, err)
+		}
+	}
+	if limit := v.Get(queryLimit); limit != "" {
+		var err error
+		q.Limit, err = strconv.Atoi(limit)
+		if err != nil || q.Limit < 0 || q.Limit > MaxPageLimit {
+			return q, fmt.Errorf("%w: limit must be 0 to %d", ErrBadQuery, MaxPageLimit)
+		}
+		if q.Limit == 0 {
+			q.Limit = DefaultPageLimit
+		}
+	}
+	if _, _, err := q.SortKey(); err != nil {
+		return q, err
+	}
+	return q, nil
+}
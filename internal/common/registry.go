@@ -0,0 +1,200 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrUnknownType is to indicate the record type is not registered
+var ErrUnknownType = errors.New("unknown record type")
+
+// FieldInfo describes the field of the record data by its JSON name.
+// The secret fields are not shown or indexed unless requested.
+type FieldInfo struct {
+	Name   string
+	Secret bool
+}
+
+// TypeInfo describes the record type: the data struct, the data fields
+// and how the data is shown
+type TypeInfo struct {
+	Type RecordType
+	// Title names the records of the type, e.g. account
+	Title string
+	// Unpack returns the record data of the type packed into the opaque
+	Unpack func(opaque string) (Opaque, error)
+	// Mask returns the data with the secrets hidden, nil if the data
+	// is shown as is
+	Mask func(Opaque) Opaque
+	// Fields are the data fields of the type
+	Fields []FieldInfo
+	// Print returns the data shown with the record, the opaque is shown
+	// as is if not set and the data is not shown if it returns empty
+	Print func(opaque string) string
+	// Hooks are the client steps on the records of the type
+	Hooks Hooks
+}
+
+// Hooks are the client steps on the records of the type. They are set
+// by the client with SetHooks and run with the client state as env.
+type Hooks struct {
+	// Prepare checks and completes the data before it is stored,
+	// or before the stored data is changed if update is set
+	Prepare func(env interface{}, data Opaque, update bool) (Opaque, error)
+	// Got runs on the record got after it is shown
+	Got func(env interface{}, record Record) error
+}
+
+var (
+	typeRegistry = make(map[RecordType]TypeInfo)
+	typeOrder    []RecordType
+)
+
+// RegisterType makes the record type known to the clients and the server.
+// It panics if the type is registered twice or has no unpack function.
+func RegisterType(info TypeInfo) {
+	if info.Type == UnspecifiedRecord || info.Unpack == nil {
+		panic("common: RegisterType: type or unpack function is not set")
+	}
+	if _, dup := typeRegistry[info.Type]; dup {
+		panic("common: RegisterType called twice for type " + info.Type)
+	}
+	typeRegistry[info.Type] = info
+	typeOrder = append(typeOrder, info.Type)
+}
+
+// SetHooks sets the client hooks of the record type.
+// It panics if the type is not registered.
+func SetHooks(t RecordType, hooks Hooks) {
+	info, ok := typeRegistry[t]
+	if !ok {
+		panic("common: SetHooks called for unknown type " + t)
+	}
+	info.Hooks = hooks
+	typeRegistry[t] = info
+}
+
+// LookupType returns the registered record type
+func LookupType(t RecordType) (TypeInfo, bool) {
+	info, ok := typeRegistry[t]
+	return info, ok
+}
+
+// RecordTypes returns the registered record types in registration order
+func RecordTypes() []RecordType {
+	types := make([]RecordType, len(typeOrder))
+	copy(types, typeOrder)
+	return types
+}
+
+// Valid returns true if the record type is registered
+func (t RecordType) Valid() bool {
+	_, ok := typeRegistry[t]
+	return ok
+}
+
+// Unpack returns the record data of the type packed into the opaque
+func Unpack(t RecordType, opaque string) (Opaque, error) {
+	info, ok := LookupType(t)
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownType, t)
+	}
+	return info.Unpack(opaque)
+}
+
+// Mask returns the opaque of the record type with the secrets hidden
+func Mask(t RecordType, opaque string) (string, error) {
+	info, ok := LookupType(t)
+	if !ok {
+		return "", fmt.Errorf("%w %q", ErrUnknownType, t)
+	}
+	if info.Mask == nil {
+		return opaque, nil
+	}
+	data, err := info.Unpack(opaque)
+	if err != nil {
+		return "", err
+	}
+	return info.Mask(data).Pack()
+}
+
+// unpackJSON returns the unpack function of the JSON packed data
+func unpackJSON[T Opaque]() func(string) (Opaque, error) {
+	return func(opaque string) (Opaque, error) {
+		var data T
+		err := json.Unmarshal([]byte(opaque), &data)
+		return data, err
+	}
+}
+
+func init() {
+	RegisterType(TypeInfo{
+		Type:   AccountRecord,
+		Title:  "account",
+		Unpack: unpackJSON[Account](),
+		Fields: []FieldInfo{
+			{Name: "url"},
+			{Name: "user_name"},
+			{Name: "password", Secret: true},
+		},
+	})
+	RegisterType(TypeInfo{
+		Type:   NoteRecord,
+		Title:  "note",
+		Unpack: unpackJSON[Note](),
+		Fields: []FieldInfo{{Name: "text"}},
+	})
+	RegisterType(TypeInfo{
+		Type:   CardRecord,
+		Title:  "card",
+		Unpack: unpackJSON[Card](),
+		Mask:   func(o Opaque) Opaque { return o.(Card).Masked() },
+		Fields: []FieldInfo{
+			{Name: "holder"},
+			{Name: "number", Secret: true},
+			{Name: "cvc", Secret: true},
+		},
+	})
+	RegisterType(TypeInfo{
+		Type:  BinaryRecord,
+		Title: "binary record",
+		Unpack: func(opaque string) (Opaque, error) {
+			return Binary{Data: opaque}, nil
+		},
+		Print: func(string) string { return "" },
+	})
+	RegisterType(TypeInfo{
+		Type:   TOTPRecord,
+		Title:  "TOTP record",
+		Unpack: unpackJSON[TOTP](),
+		Fields: []FieldInfo{
+			{Name: "issuer"},
+			{Name: "user_name"},
+			{Name: "account"},
+			{Name: "secret", Secret: true},
+		},
+	})
+	RegisterType(TypeInfo{
+		Type:   SSHRecord,
+		Title:  "SSH key",
+		Unpack: unpackJSON[SSHKey](),
+		Mask:   func(o Opaque) Opaque { return o.(SSHKey).Masked() },
+		Fields: []FieldInfo{
+			{Name: "comment"},
+			{Name: "private_key", Secret: true},
+			{Name: "passphrase", Secret: true},
+		},
+	})
+	RegisterType(TypeInfo{
+		Type:   GenericRecord,
+		Title:  "record",
+		Unpack: unpackJSON[Generic](),
+		Mask:   func(o Opaque) Opaque { return o.(Generic).Masked() },
+	})
+	RegisterType(TypeInfo{
+		Type:   SchemaRecord,
+		Title:  "schema",
+		Unpack: unpackJSON[Schema](),
+	})
+}
@@ -0,0 +1,98 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordTypes(t *testing.T) {
+	assert.Equal(t, []RecordType{
+		AccountRecord,
+		NoteRecord,
+		CardRecord,
+		BinaryRecord,
+		TOTPRecord,
+		SSHRecord,
+		GenericRecord,
+		SchemaRecord,
+	}, RecordTypes())
+
+	assert.True(t, NoteRecord.Valid())
+	assert.False(t, UnspecifiedRecord.Valid())
+	assert.False(t, RecordType("blob").Valid())
+
+	assert.Panics(t, func() { RegisterType(TypeInfo{Type: NoteRecord, Unpack: unpackJSON[Note]()}) })
+	assert.Panics(t, func() { RegisterType(TypeInfo{Type: "blob"}) })
+}
+
+func TestUnpack(t *testing.T) {
+	for _, data := range []Opaque{
+		Account{URL: "https://example.com", UserName: "u", Password: "p"},
+		Note{Text: "text"},
+		Binary{Data: "AAEC"},
+		Generic{Fields: []Field{{"host", FieldText, "db1"}}},
+	} {
+		opaque, err := data.Pack()
+		require.NoError(t, err)
+		var got Opaque
+		switch data.(type) {
+		case Account:
+			got, err = Unpack(AccountRecord, opaque)
+		case Note:
+			got, err = Unpack(NoteRecord, opaque)
+		case Binary:
+			got, err = Unpack(BinaryRecord, opaque)
+		case Generic:
+			got, err = Unpack(GenericRecord, opaque)
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, data, got)
+	}
+
+	_, err := Unpack("blob", "{}")
+	assert.ErrorIs(t, err, ErrUnknownType)
+	_, err = Unpack(NoteRecord, "not json")
+	assert.Error(t, err)
+}
+
+func TestMask(t *testing.T) {
+	opaque, err := Card{Number: "4111111111111111", CVC: "123"}.Pack()
+	require.NoError(t, err)
+	masked, err := Mask(CardRecord, opaque)
+	assert.NoError(t, err)
+	assert.NotContains(t, masked, "4111111111111111")
+	assert.Contains(t, masked, "1111")
+
+	opaque, err = SSHKey{PrivateKey: "-----BEGIN KEY-----", PublicKey: "ssh-ed25519 AAAA"}.Pack()
+	require.NoError(t, err)
+	masked, err = Mask(SSHRecord, opaque)
+	assert.NoError(t, err)
+	assert.NotContains(t, masked, "BEGIN KEY")
+	assert.Contains(t, masked, "ssh-ed25519 AAAA")
+
+	masked, err = Mask(NoteRecord, `{"text":"as is"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"text":"as is"}`, masked)
+}
+
+func TestSetHooks(t *testing.T) {
+	defer SetHooks(NoteRecord, Hooks{})
+	SetHooks(NoteRecord, Hooks{
+		Got: func(env interface{}, record Record) error { return nil },
+	})
+	info, ok := LookupType(NoteRecord)
+	require.True(t, ok)
+	assert.NotNil(t, info.Hooks.Got)
+	assert.Nil(t, info.Hooks.Prepare)
+
+	assert.Panics(t, func() { SetHooks("blob", Hooks{}) })
+}
+
+func TestRecord_String(t *testing.T) {
+	note := Record{Name: "n", Type: NoteRecord, Opaque: `{"text":"shown"}`}
+	assert.Contains(t, note.String(), `Data: {"text":"shown"}`)
+	binary := Record{Name: "b", Type: BinaryRecord, Opaque: "AAEC"}
+	assert.NotContains(t, binary.String(), "Data:")
+}
@@ -1,16 +1,29 @@
 package common
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 func (r Record) String() string {
 	repr := ""
 	repr += fmt.Sprintf("\n  Type: %s", r.Type)
 	repr += fmt.Sprintf("\n  Name: %s", r.Name)
+	if r.Folder != "" {
+		repr += fmt.Sprintf("\n  Folder: %s", r.Folder)
+	}
+	if len(r.Tags) > 0 {
+		repr += fmt.Sprintf("\n  Tags: %s", strings.Join(r.Tags, ", "))
+	}
 	if r.Meta != "" {
 		repr += fmt.Sprintf("\n  Meta info: %s", r.Meta)
 	}
-	if r.Type != BinaryRecord && r.Opaque != "" {
-		repr += fmt.Sprintf("\n  Data: %s", r.Opaque)
+	data := r.Opaque
+	if info, ok := LookupType(r.Type); ok && info.Print != nil {
+		data = info.Print(r.Opaque)
+	}
+	if data != "" {
+		repr += fmt.Sprintf("\n  Data: %s", data)
 	}
 
 	return repr
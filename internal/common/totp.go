@@ -0,0 +1,45 @@
+package common
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// TOTP defaults of RFC 6238 used by the most of the services
+const (
+	DefaultTOTPAlgorithm = "SHA1"
+	DefaultTOTPDigits    = 6
+	DefaultTOTPPeriod    = 30
+)
+
+// totpEncoding is the encoding of the TOTP secrets
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Key returns the TOTP secret decoded. The secret is base32 encoded,
+// the spaces and the padding are ignored, the letter case is not significant.
+func (t TOTP) Key() ([]byte, error) {
+	secret := strings.ToUpper(strings.ReplaceAll(t.Secret, " ", ""))
+	key, err := totpEncoding.DecodeString(strings.TrimRight(secret, "="))
+	if err != nil || len(key) == 0 {
+		return nil, fmt.Errorf("TOTP secret is not base32 encoded")
+	}
+	return key, nil
+}
+
+// Hash returns the hash function of the TOTP algorithm
+func (t TOTP) Hash() (func() hash.Hash, error) {
+	switch strings.ToUpper(t.Algorithm) {
+	case "SHA1":
+		return sha1.New, nil
+	case "SHA256":
+		return sha256.New, nil
+	case "SHA512":
+		return sha512.New, nil
+	}
+	return nil, fmt.Errorf("unknown TOTP algorithm %q", t.Algorithm)
+}
@@ -1,20 +1,26 @@
 package common
 
+import "time"
+
 // Key is the AES key type used
 type Key [32]byte
 
 // User is the client of the secret store service
 type User struct {
-	Name     string `json:"name"`
-	FullName string `json:"full_name"`
-	Password string `json:"password"`
+	Name      string `json:"name"`
+	FullName  string `json:"full_name"`
+	Password  string `json:"password"`
+	PublicKey string `json:"public_key,omitempty"`
 }
 
-// Account holds account data for some resource
+// Account holds account data for some resource.
+// PasswordChanged is the time the password was set,
+// it is nil for the accounts stored by the previous versions.
 type Account struct {
-	URL      string `json:"url"`
-	UserName string `json:"user_name"`
-	Password string `json:"password"`
+	URL             string     `json:"url"`
+	UserName        string     `json:"user_name"`
+	Password        string     `json:"password"`
+	PasswordChanged *time.Time `json:"password_changed,omitempty"`
 }
 
 // Note holds text data
@@ -31,17 +37,83 @@ type Card struct {
 	CVC      string `json:"cvc"`
 }
 
+// TOTP holds the time-based one-time password generator secret.
+// Account is the name of the account record the secret is used for.
+type TOTP struct {
+	Secret    string `json:"secret"`
+	Algorithm string `json:"algorithm"`
+	Digits    int    `json:"digits"`
+	Period    int    `json:"period"`
+	Issuer    string `json:"issuer,omitempty"`
+	UserName  string `json:"user_name,omitempty"`
+	Account   string `json:"account,omitempty"`
+}
+
+// SSHKey holds the SSH key pair: PEM encoded private key, possibly
+// encrypted with the passphrase, and the public key in the authorized
+// keys format
+type SSHKey struct {
+	PrivateKey string `json:"private_key"`
+	PublicKey  string `json:"public_key"`
+	Comment    string `json:"comment,omitempty"`
+	Passphrase string `json:"passphrase,omitempty"`
+}
+
 // Binary holds base64-encoded binary data
 type Binary struct {
 	Data string `json:"data"`
 }
 
-// Record can hold any record that could be stored
+// FieldType is the type of the custom field value
+type FieldType string
+
+// Field is the custom field of the generic record
+type Field struct {
+	Name  string    `json:"name"`
+	Type  FieldType `json:"type"`
+	Value string    `json:"value"`
+}
+
+// Generic holds the custom fields. Schema is the name of the user
+// schema the fields are checked against, it is empty for the records
+// with arbitrary fields.
+type Generic struct {
+	Schema string  `json:"schema,omitempty"`
+	Fields []Field `json:"fields"`
+}
+
+// FieldSpec describes the field of the schema
+type FieldSpec struct {
+	Name     string    `json:"name"`
+	Type     FieldType `json:"type"`
+	Required bool      `json:"required,omitempty"`
+}
+
+// Schema is the user-defined template of the generic records,
+// it is stored as the record named by the schema name
+type Schema struct {
+	Fields []FieldSpec `json:"fields"`
+}
+
+// Record can hold any record that could be stored.
+// DataKey is the per-record data key wrapped by the master key,
+// it is empty for the records encrypted with the master key directly.
+// NameIndex is the blind index of the encrypted name to look
+// the record up by, it is empty for the records with clear text name.
+// Folder is the slash separated folder path of the record and Tags are
+// its tags, they are encrypted as the name. LabelIndex holds the blind
+// indexes of the folder, its parent folders and the tags to filter
+// the records by.
 type Record struct {
-	Name   string     `json:"name"`
-	Type   RecordType `json:"record_type"`
-	Opaque string     `json:"opaque"`
-	Meta   string     `json:"meta"`
+	Name       string     `json:"name"`
+	Type       RecordType `json:"record_type"`
+	Opaque     string     `json:"opaque"`
+	Meta       string     `json:"meta"`
+	DataKey    string     `json:"data_key,omitempty"`
+	NameIndex  string     `json:"name_index,omitempty"`
+	Folder     string     `json:"folder,omitempty"`
+	Tags       []string   `json:"tags,omitempty"`
+	LabelIndex []string   `json:"label_index,omitempty"`
 }
 
 // Records can hold the map of any record that could be stored
@@ -59,6 +131,14 @@ const (
 	CardRecord RecordType = "card"
 	// BinaryRecord is the Binary record type
 	BinaryRecord RecordType = "bin"
+	// TOTPRecord is the TOTP record type
+	TOTPRecord RecordType = "totp"
+	// SSHRecord is the SSH key record type
+	SSHRecord RecordType = "ssh"
+	// GenericRecord is the record type with custom fields
+	GenericRecord RecordType = "generic"
+	// SchemaRecord is the user schema of the generic records
+	SchemaRecord RecordType = "schema"
 	// UnspecifiedRecord is the unspecified record type
 	UnspecifiedRecord RecordType = ""
 )
@@ -76,3 +156,60 @@ type StoreRecordResponse struct {
 	Status string `json:"status"`
 	ID     int64  `json:"id"`
 }
+
+// VaultRole is the role of the vault member
+type VaultRole string
+
+const (
+	// VaultOwner can manage the vault, its members and records
+	VaultOwner VaultRole = "owner"
+	// VaultAdmin can manage the vault members and records
+	VaultAdmin VaultRole = "admin"
+	// VaultWriter can read and change the vault records
+	VaultWriter VaultRole = "writer"
+	// VaultReader can only read the vault records
+	VaultReader VaultRole = "reader"
+)
+
+var vaultRoleRank = map[VaultRole]int{
+	VaultReader: 1,
+	VaultWriter: 2,
+	VaultAdmin:  3,
+	VaultOwner:  4,
+}
+
+// Valid returns true if the role is known
+func (r VaultRole) Valid() bool {
+	_, ok := vaultRoleRank[r]
+	return ok
+}
+
+// Allows returns true if the role grants at least the permissions
+// of the required role
+func (r VaultRole) Allows(required VaultRole) bool {
+	return r.Valid() && vaultRoleRank[r] >= vaultRoleRank[required]
+}
+
+// Vault is the named record storage shared between users.
+// Key is the vault key wrapped with the owner public key,
+// it is set on vault creation only.
+type Vault struct {
+	Name string    `json:"name"`
+	Role VaultRole `json:"role,omitempty"`
+	Key  string    `json:"key,omitempty"`
+}
+
+// VaultMember is the user having access to the vault.
+// Key is the vault key wrapped with the member public key.
+type VaultMember struct {
+	Name string    `json:"name"`
+	Role VaultRole `json:"role"`
+	Key  string    `json:"key,omitempty"`
+}
+
+// VaultKeyRotation holds the new vault key wrapped for every member
+// and all the vault records encrypted with the new key
+type VaultKeyRotation struct {
+	Keys    map[string]string `json:"keys"`
+	Records Records           `json:"records"`
+}
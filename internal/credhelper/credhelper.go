@@ -0,0 +1,121 @@
+// Package credhelper implements the credential helper protocols,
+// the credentials are mapped to the account records by URL
+package credhelper
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/alexey-mavrin/graduate-2/internal/urlmatch"
+)
+
+// Credential is the credential requested or approved by the tool
+type Credential struct {
+	Protocol string
+	Host     string
+	Path     string
+	Username string
+	Password string
+}
+
+// cleanPath returns the path without the leading and trailing slashes
+// and the .git suffix, so the repository paths with and without
+// the suffix are the same
+func cleanPath(path string) string {
+	path = strings.Trim(path, "/")
+	return strings.TrimSuffix(path, ".git")
+}
+
+// parseURL splits the URL into the protocol, the host and the path.
+// The URL may have no scheme, e.g. github.com/org, the protocol
+// is empty then.
+func parseURL(raw string) (Credential, error) {
+	var c Credential
+	hasScheme := strings.Contains(raw, "://")
+	if !hasScheme {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return c, err
+	}
+	if hasScheme {
+		c.Protocol = u.Scheme
+	}
+	c.Host = strings.ToLower(u.Host)
+	c.Path = cleanPath(u.Path)
+	if u.User != nil {
+		c.Username = u.User.Username()
+	}
+	return c, nil
+}
+
+// URL returns the credential URL to store in the account record
+func (c Credential) URL() string {
+	u := c.Host
+	if c.Protocol != "" {
+		u = c.Protocol + "://" + u
+	}
+	if path := cleanPath(c.Path); path != "" {
+		u += "/" + path
+	}
+	return u
+}
+
+// Name returns the account record name of the credential:
+// user@host/path
+func (c Credential) Name() string {
+	name := c.Host
+	if path := cleanPath(c.Path); path != "" {
+		name += "/" + path
+	}
+	if c.Username != "" {
+		name = c.Username + "@" + name
+	}
+	return name
+}
+
+// sameHost reports if the hosts normalized are the same. The hosts of
+// the registrable domain are not the same, the credential is never
+// sent to the other host.
+func sameHost(a, b string) bool {
+	ua, err := urlmatch.Normalize(a)
+	if err != nil {
+		return false
+	}
+	ub, err := urlmatch.Normalize(b)
+	if err != nil {
+		return false
+	}
+	return ua.Host == ub.Host
+}
+
+// Match reports if the account URL matches the credential and how
+// specific the match is: the length of the account path matched.
+// The hosts must be equal and the account protocol must be equal
+// if it is set. The account path, if set, must be the credential path
+// or its parent. As in git, the credential without the path matches
+// any account path of the host.
+func (c Credential) Match(accountURL string) (int, bool) {
+	if accountURL == "" {
+		return 0, false
+	}
+	a, err := parseURL(accountURL)
+	if err != nil {
+		return 0, false
+	}
+	if !sameHost(a.Host, c.Host) {
+		return 0, false
+	}
+	if a.Protocol != "" && c.Protocol != "" && a.Protocol != c.Protocol {
+		return 0, false
+	}
+	path := cleanPath(c.Path)
+	if path == "" {
+		return 0, true
+	}
+	if a.Path != "" && path != a.Path && !strings.HasPrefix(path, a.Path+"/") {
+		return 0, false
+	}
+	return len(a.Path), true
+}
@@ -0,0 +1,91 @@
+package credhelper
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadGit(t *testing.T) {
+	c, err := ReadGit(strings.NewReader("protocol=https\nhost=github.com\n" +
+		"path=org/repo.git\nusername=bob\npassword=token\nwwwauth[]=Basic\n\n" +
+		"ignored=after blank line\n"))
+	require.NoError(t, err)
+	assert.Equal(t, Credential{"https", "github.com", "org/repo.git", "bob", "token"}, c)
+	assert.Equal(t, "https://github.com/org/repo", c.URL())
+	assert.Equal(t, "bob@github.com/org/repo", c.Name())
+
+	c, err = ReadGit(strings.NewReader("url=https://alice@Git.Example.com:8443/team/\n"))
+	require.NoError(t, err)
+	assert.Equal(t, Credential{"https", "git.example.com:8443", "team", "alice", ""}, c)
+
+	_, err = ReadGit(strings.NewReader("no equal sign\n"))
+	assert.Error(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, Credential{Username: "bob", Password: "token"}.WriteGit(&buf))
+	assert.Equal(t, "username=bob\npassword=token\n", buf.String())
+}
+
+func TestMatch(t *testing.T) {
+	c := Credential{Protocol: "https", Host: "github.com", Path: "org/repo.git"}
+	tests := []struct {
+		url   string
+		score int
+		ok    bool
+	}{
+		{"github.com", 0, true},
+		{"https://GitHub.com/", 0, true},
+		{"https://www.github.com:443", 0, true},
+		{"api.github.com", 0, false},
+		{"https://github.com/org", 3, true},
+		{"github.com/org/repo", 8, true},
+		{"https://github.com/org/repo.git", 8, true},
+		{"http://github.com", 0, false},
+		{"github.com/other", 0, false},
+		{"github.com/org/repo2", 0, false},
+		{"gitlab.com", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		score, ok := c.Match(tt.url)
+		assert.Equal(t, tt.ok, ok, tt.url)
+		assert.Equal(t, tt.score, score, tt.url)
+	}
+
+	noPath := Credential{Protocol: "https", Host: "github.com"}
+	score, ok := noPath.Match("github.com/org")
+	assert.True(t, ok, "any path matches the credential without path")
+	assert.Equal(t, 0, score)
+}
+
+func TestDocker(t *testing.T) {
+	d, err := ReadDocker(strings.NewReader(
+		`{"ServerURL":"https://index.docker.io/v1/","Username":"bob","Secret":"token"}`))
+	require.NoError(t, err)
+	assert.Equal(t, DockerCredential{"https://index.docker.io/v1/", "bob", "token"}, d)
+
+	_, err = ReadDocker(strings.NewReader(`{"Username":"bob"}`))
+	assert.Error(t, err)
+
+	serverURL, err := ReadServerURL(strings.NewReader("ghcr.io\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "ghcr.io", serverURL)
+
+	c, err := DockerURL("https://index.docker.io/v1/")
+	require.NoError(t, err)
+	_, ok := c.Match("https://index.docker.io/v1/")
+	assert.True(t, ok)
+	_, ok = c.Match("index.docker.io")
+	assert.True(t, ok)
+
+	c, err = DockerURL("ghcr.io")
+	require.NoError(t, err)
+	_, ok = c.Match("https://ghcr.io")
+	assert.True(t, ok)
+	_, ok = c.Match("https://registry.example.com")
+	assert.False(t, ok)
+}
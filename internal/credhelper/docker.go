@@ -0,0 +1,57 @@
+package credhelper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrNotFound is returned when no credential is found, Docker
+// recognizes the missing credentials by this message
+var ErrNotFound = errors.New("credentials not found in native keychain")
+
+// DockerCredential is the registry credential of the Docker
+// credential helper protocol
+type DockerCredential struct {
+	ServerURL string `json:"ServerURL"`
+	Username  string `json:"Username"`
+	Secret    string `json:"Secret"`
+}
+
+// ReadDocker reads the registry credential JSON to store
+func ReadDocker(r io.Reader) (DockerCredential, error) {
+	var d DockerCredential
+	err := json.NewDecoder(r).Decode(&d)
+	if err != nil {
+		return d, fmt.Errorf("read credential: %w", err)
+	}
+	if d.ServerURL == "" {
+		return d, errors.New("registry server URL is not set")
+	}
+	return d, nil
+}
+
+// ReadServerURL reads the registry server URL passed as plain text
+func ReadServerURL(r io.Reader) (string, error) {
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	serverURL := strings.TrimSpace(string(buf))
+	if serverURL == "" {
+		return "", errors.New("registry server URL is not set")
+	}
+	return serverURL, nil
+}
+
+// DockerURL returns the credential of the registry server URL
+func DockerURL(serverURL string) (Credential, error) {
+	c, err := parseURL(serverURL)
+	if err != nil {
+		return c, fmt.Errorf("bad registry server URL: %w", err)
+	}
+	c.Username = ""
+	return c, nil
+}
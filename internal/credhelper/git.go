@@ -0,0 +1,56 @@
+package credhelper
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ReadGit reads the credential in the git credential helper format:
+// key=value lines up to the blank line or EOF. The url attribute
+// is split into the protocol, the host and the path, the unknown
+// attributes are ignored.
+func ReadGit(r io.Reader) (Credential, error) {
+	var c Credential
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			break
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return c, fmt.Errorf("bad credential attribute %q", line)
+		}
+		switch key {
+		case "protocol":
+			c.Protocol = value
+		case "host":
+			c.Host = value
+		case "path":
+			c.Path = value
+		case "username":
+			c.Username = value
+		case "password":
+			c.Password = value
+		case "url":
+			u, err := parseURL(value)
+			if err != nil {
+				return c, fmt.Errorf("bad credential url: %w", err)
+			}
+			c.Protocol, c.Host, c.Path = u.Protocol, u.Host, u.Path
+			if u.Username != "" {
+				c.Username = u.Username
+			}
+		}
+	}
+	return c, scanner.Err()
+}
+
+// WriteGit writes the username and the password of the credential
+// in the git credential helper format
+func (c Credential) WriteGit(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "username=%s\npassword=%s\n", c.Username, c.Password)
+	return err
+}
@@ -3,6 +3,7 @@ package crypt
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
@@ -18,6 +19,20 @@ func MakeKey(s string) common.Key {
 	return common.Key(sha256.Sum256([]byte(s)))
 }
 
+// deriveKey derives the key for the given purpose from the master key
+func deriveKey(key common.Key, purpose string) common.Key {
+	var derived common.Key
+	mac := hmac.New(sha256.New, key[:])
+	mac.Write([]byte(purpose))
+	copy(derived[:], mac.Sum(nil))
+	return derived
+}
+
+// CacheKey returns the key to encrypt the local cache with
+func CacheKey(key common.Key) common.Key {
+	return deriveKey(key, "cache")
+}
+
 // EncryptString is the same as Encrypt but for strings
 func EncryptString(key common.Key, clearText string) (string, error) {
 	buf, err := Encrypt(key, []byte(clearText))
@@ -36,6 +51,12 @@ func DecryptString(key common.Key, cypherText string) (string, error) {
 
 // Encrypt encrypts the cleartext with the key given
 func Encrypt(key common.Key, clearText []byte) ([]byte, error) {
+	return EncryptAD(key, clearText, nil)
+}
+
+// EncryptAD encrypts the cleartext with the key given and authenticates
+// the additional data. The same additional data is required to decrypt.
+func EncryptAD(key common.Key, clearText, additionalData []byte) ([]byte, error) {
 	c, err := aes.NewCipher(key[:])
 	if err != nil {
 		return nil, err
@@ -52,12 +73,18 @@ func Encrypt(key common.Key, clearText []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	cipherText := gcm.Seal(nonce, nonce, clearText, nil)
+	cipherText := gcm.Seal(nonce, nonce, clearText, additionalData)
 	return cipherText, nil
 }
 
 // Decrypt decrypts the ciphertext with the key given
 func Decrypt(key common.Key, cipherText []byte) ([]byte, error) {
+	return DecryptAD(key, cipherText, nil)
+}
+
+// DecryptAD decrypts the ciphertext with the key given
+// and checks the additional data
+func DecryptAD(key common.Key, cipherText, additionalData []byte) ([]byte, error) {
 	c, err := aes.NewCipher(key[:])
 	if err != nil {
 		return nil, err
@@ -75,7 +102,7 @@ func Decrypt(key common.Key, cipherText []byte) ([]byte, error) {
 
 	nonce, text := cipherText[:nonceSize], cipherText[nonceSize:]
 
-	plainText, err := gcm.Open(nil, nonce, text, nil)
+	plainText, err := gcm.Open(nil, nonce, text, additionalData)
 	if err != nil {
 		return nil, err
 	}
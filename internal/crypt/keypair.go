@@ -0,0 +1,92 @@
+package crypt
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/nacl/box"
+)
+
+// NewKey returns new random key
+func NewKey() (common.Key, error) {
+	var key common.Key
+	_, err := io.ReadFull(rand.Reader, key[:])
+	return key, err
+}
+
+// KeyPair derives the user X25519 key pair from the master key.
+// The public key is published on the server to let other users
+// share vault keys with the user.
+func KeyPair(key common.Key) (public, private common.Key, err error) {
+	mac := hmac.New(sha256.New, key[:])
+	mac.Write([]byte("x25519 key pair"))
+	copy(private[:], mac.Sum(nil))
+
+	pub, err := curve25519.X25519(private[:], curve25519.Basepoint)
+	if err != nil {
+		return public, private, err
+	}
+	copy(public[:], pub)
+	return public, private, nil
+}
+
+// ParsePublicKey decodes hex-encoded public key
+func ParsePublicKey(s string) (common.Key, error) {
+	var key common.Key
+	buf, err := hex.DecodeString(s)
+	if err != nil {
+		return key, err
+	}
+	if len(buf) != len(key) {
+		return key, errors.New("wrong public key length")
+	}
+	copy(key[:], buf)
+	return key, nil
+}
+
+// Fingerprint returns the fingerprint of the public key to compare
+// out of band: the SHA-256 hash prefix in the groups of hex digits
+func Fingerprint(publicKey common.Key) string {
+	sum := sha256.Sum256(publicKey[:])
+	s := hex.EncodeToString(sum[:16])
+	groups := make([]string, 0, len(s)/4)
+	for i := 0; i < len(s); i += 4 {
+		groups = append(groups, s[i:i+4])
+	}
+	return strings.Join(groups, "-")
+}
+
+// WrapKey encrypts the key so that only the owner of
+// the public key given can decrypt it
+func WrapKey(publicKey common.Key, key common.Key) (string, error) {
+	pub := [32]byte(publicKey)
+	buf, err := box.SealAnonymous(nil, key[:], &pub, rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// UnwrapKey decrypts the key wrapped by WrapKey
+func UnwrapKey(publicKey, privateKey common.Key, wrapped string) (common.Key, error) {
+	var key common.Key
+	buf, err := hex.DecodeString(wrapped)
+	if err != nil {
+		return key, err
+	}
+	pub := [32]byte(publicKey)
+	priv := [32]byte(privateKey)
+	clear, ok := box.OpenAnonymous(nil, buf, &pub, &priv)
+	if !ok || len(clear) != len(key) {
+		return key, errors.New("cannot unwrap key")
+	}
+	copy(key[:], clear)
+	return key, nil
+}
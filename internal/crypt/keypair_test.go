@@ -0,0 +1,41 @@
+package crypt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_WrapUnwrapKey(t *testing.T) {
+	pub1, priv1, err := KeyPair(MakeKey("first user key phrase"))
+	require.NoError(t, err)
+	pub2, priv2, err := KeyPair(MakeKey("second user key phrase"))
+	require.NoError(t, err)
+	assert.NotEqual(t, pub1, pub2)
+
+	vaultKey, err := NewKey()
+	require.NoError(t, err)
+
+	wrapped, err := WrapKey(pub1, vaultKey)
+	assert.NoError(t, err)
+
+	key, err := UnwrapKey(pub1, priv1, wrapped)
+	assert.NoError(t, err)
+	assert.Equal(t, vaultKey, key)
+
+	// other user cannot unwrap the key
+	_, err = UnwrapKey(pub2, priv2, wrapped)
+	assert.Error(t, err)
+}
+
+func TestFingerprint(t *testing.T) {
+	pub1, _, err := KeyPair(MakeKey("first user key phrase"))
+	require.NoError(t, err)
+	pub2, _, err := KeyPair(MakeKey("second user key phrase"))
+	require.NoError(t, err)
+
+	assert.Equal(t, Fingerprint(pub1), Fingerprint(pub1))
+	assert.NotEqual(t, Fingerprint(pub1), Fingerprint(pub2))
+	assert.Regexp(t, `^[0-9a-f]{4}(-[0-9a-f]{4}){7}$`, Fingerprint(pub1))
+}
@@ -0,0 +1,86 @@
+package crypt
+
+import (
+	"errors"
+	"fmt"
+)
+
+// PaddingPolicy defines how the record fields are padded
+// before encryption to hide their length
+type PaddingPolicy string
+
+const (
+	// PaddingNone disables the padding
+	PaddingNone PaddingPolicy = "none"
+	// PaddingPow2 pads the field to the next power of two
+	PaddingPow2 PaddingPolicy = "pow2"
+	// PaddingBlock pads the field to the multiple of the block size
+	PaddingBlock PaddingPolicy = "block"
+)
+
+const (
+	// padMinSize is the minimal length of the field padded
+	// to the power of two
+	padMinSize = 32
+	// padBlockSize is the block size for PaddingBlock
+	padBlockSize = 64
+	// padMarker separates the cleartext from the zero padding
+	padMarker = 0x80
+)
+
+// RecordPadding is the padding policy applied to Opaque and Meta
+// fields of the records encrypted
+var RecordPadding = PaddingPow2
+
+var errBadPadding = errors.New("bad padding")
+
+// ParsePadding returns the padding policy by its name,
+// the empty name stands for the default policy
+func ParsePadding(s string) (PaddingPolicy, error) {
+	switch p := PaddingPolicy(s); p {
+	case "":
+		return PaddingPow2, nil
+	case PaddingNone, PaddingPow2, PaddingBlock:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown padding %q, use none, pow2 or block", s)
+}
+
+// paddedSize returns the length of the padded field
+// to hold n bytes of the cleartext and the marker
+func paddedSize(n int, policy PaddingPolicy) int {
+	n++
+	switch policy {
+	case PaddingPow2:
+		size := padMinSize
+		for size < n {
+			size *= 2
+		}
+		return size
+	case PaddingBlock:
+		return (n + padBlockSize - 1) / padBlockSize * padBlockSize
+	}
+	return n
+}
+
+// pad appends the marker byte and the zero bytes up to the padded size
+func pad(clearText []byte, policy PaddingPolicy) []byte {
+	buf := make([]byte, paddedSize(len(clearText), policy))
+	copy(buf, clearText)
+	buf[len(clearText)] = padMarker
+	return buf
+}
+
+// unpad strips the zero bytes and the marker byte
+func unpad(buf []byte) ([]byte, error) {
+	for i := len(buf) - 1; i >= 0; i-- {
+		switch buf[i] {
+		case 0:
+			continue
+		case padMarker:
+			return buf[:i], nil
+		}
+		break
+	}
+	return nil, errBadPadding
+}
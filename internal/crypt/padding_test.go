@@ -0,0 +1,101 @@
+package crypt
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_pad(t *testing.T) {
+	tests := []struct {
+		policy PaddingPolicy
+		len    int
+		want   int
+	}{
+		{PaddingPow2, 0, 32},
+		{PaddingPow2, 31, 32},
+		{PaddingPow2, 32, 64},
+		{PaddingPow2, 100, 128},
+		{PaddingBlock, 0, 64},
+		{PaddingBlock, 63, 64},
+		{PaddingBlock, 64, 128},
+		{PaddingBlock, 130, 192},
+	}
+	for _, tt := range tests {
+		clearText := bytes.Repeat([]byte{0}, tt.len)
+		padded := pad(clearText, tt.policy)
+		assert.Len(t, padded, tt.want, "%s %d", tt.policy, tt.len)
+		unpadded, err := unpad(padded)
+		assert.NoError(t, err)
+		assert.Equal(t, clearText, unpadded)
+	}
+
+	_, err := unpad([]byte("no marker\x00\x00"))
+	assert.Error(t, err)
+	_, err = unpad(nil)
+	assert.Error(t, err)
+}
+
+func Test_parsePadding(t *testing.T) {
+	p, err := ParsePadding("")
+	assert.NoError(t, err)
+	assert.Equal(t, PaddingPow2, p)
+	p, err = ParsePadding("block")
+	assert.NoError(t, err)
+	assert.Equal(t, PaddingBlock, p)
+	_, err = ParsePadding("random")
+	assert.Error(t, err)
+}
+
+func Test_cryptRecordPadding(t *testing.T) {
+	defer func(p PaddingPolicy) { RecordPadding = p }(RecordPadding)
+
+	key := MakeKey("qwerty")
+	short := common.Record{Name: "n1", Type: common.NoteRecord, Opaque: "1"}
+	long := common.Record{Name: "n2", Type: common.NoteRecord, Opaque: "1234567890"}
+
+	RecordPadding = PaddingPow2
+	eShort, err := EncryptRecord(key, testBinding, short)
+	require.NoError(t, err)
+	eLong, err := EncryptRecord(key, testBinding, long)
+	require.NoError(t, err)
+	// the lengths are hidden
+	assert.Equal(t, len(eShort.Opaque), len(eLong.Opaque))
+	assert.Equal(t, formatV3, format(eShort.Opaque))
+
+	// the records padded are decrypted with any padding policy set
+	RecordPadding = PaddingNone
+	decr, err := DecryptRecord(key, testBinding, eLong)
+	assert.NoError(t, err)
+	assert.Equal(t, long, decr)
+
+	eShortNone, err := EncryptRecord(key, testBinding, short)
+	require.NoError(t, err)
+	assert.Equal(t, formatV2, format(eShortNone.Opaque))
+	assert.Less(t, len(eShortNone.Opaque), len(eShort.Opaque))
+	decr, err = DecryptRecord(key, testBinding, eShortNone)
+	assert.NoError(t, err)
+	assert.Equal(t, short, decr)
+
+	// the padded field cannot be passed off as the unpadded one
+	eLong.Opaque = "02" + eLong.Opaque[2:]
+	_, err = DecryptRecord(key, testBinding, eLong)
+	assert.Error(t, err)
+}
+
+func TestSealedSize(t *testing.T) {
+	defer func(p PaddingPolicy) { RecordPadding = p }(RecordPadding)
+
+	key := MakeKey("qwerty")
+	for _, policy := range []PaddingPolicy{PaddingNone, PaddingPow2, PaddingBlock} {
+		RecordPadding = policy
+		for _, n := range []int{0, 31, 100} {
+			sealed, err := sealPadded(key, nil, bytes.Repeat([]byte{1}, n))
+			require.NoError(t, err)
+			assert.Equal(t, len(sealed), SealedSize(n, policy), "%s %d", policy, n)
+		}
+	}
+}
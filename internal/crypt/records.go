@@ -1,30 +1,490 @@
 package crypt
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
 	"github.com/alexey-mavrin/graduate-2/internal/common"
 )
 
-// EncryptRecord encrypts sensitive fields in record
-func EncryptRecord(key common.Key, a common.Record) (common.Record, error) {
+const (
+	// formatV1 is the envelope encryption format: the record fields are
+	// encrypted with the per-record data key, and the data key is wrapped
+	// by the master key. The format version byte precedes the nonce.
+	formatV1 byte = 1
+	// formatV2 is formatV1 with the record binding authenticated
+	// as additional data of every field
+	formatV2 byte = 2
+	// formatV3 is formatV2 with the cleartext padded to hide its length
+	formatV3 byte = 3
+	// formatV4 is formatV2 of the data key of the record with the name
+	// encrypted. The names of the records with the data keys of the
+	// previous formats are in clear text.
+	formatV4 byte = 4
+	// formatV5 is formatV4 of the data key of the record bound to its
+	// name rather than to the ID, the record is encrypted before
+	// the server assigns the ID
+	formatV5 byte = 5
+)
+
+// the record fields names authenticated as additional data
+const (
+	fieldName    = "name"
+	fieldOpaque  = "opaque"
+	fieldMeta    = "meta"
+	fieldDataKey = "data_key"
+	fieldFolder  = "folder"
+	fieldTag     = "tag"
+)
+
+// the kinds of the labels indexed
+const (
+	labelFolder = "folder"
+	labelTag    = "tag"
+)
+
+// the sizes of the AES-GCM nonce and authentication tag
+// every ciphertext holds
+const (
+	nonceSize = 12
+	tagSize   = 16
+)
+
+// ErrUnknownFormat is returned when the ciphertext format is not supported
+var ErrUnknownFormat = errors.New("unknown ciphertext format")
+
+// ErrMalformed is returned when the ciphertext is not a sealed envelope
+var ErrMalformed = errors.New("malformed ciphertext")
+
+// ErrUnbound is returned for the record not bound to its owner,
+// i.e. with no data key or in formatV1, when RejectUnbound is set
+var ErrUnbound = errors.New("record is not bound, upgrade it")
+
+// RejectUnbound makes the records of the formats with no binding
+// rejected: the server can replay them in place of any other record.
+// It is set once all the records are upgraded.
+var RejectUnbound = false
+
+// Binding identifies the record the ciphertext belongs to.
+// The ciphertext moved to another record, type, field or owner
+// fails to decrypt. The records are bound to the owner, the type
+// and the name, the ID binds the records of the previous formats.
+type Binding struct {
+	Owner string
+	ID    int64
+}
+
+// RecordOwner returns the owner of the record to bind the record to:
+// the vault for the vault records or the user for the personal ones
+func RecordOwner(user, vault string) string {
+	if vault != "" {
+		return "vault:" + vault
+	}
+	return "user:" + user
+}
+
+// encodeItems prefixes every item with its length
+// to keep the encoding unambiguous
+func encodeItems(buf []byte, items ...string) []byte {
+	for _, item := range items {
+		var l [4]byte
+		binary.BigEndian.PutUint32(l[:], uint32(len(item)))
+		buf = append(buf, l[:]...)
+		buf = append(buf, item...)
+	}
+	return buf
+}
+
+// additionalData encodes the binding, the record type and the field name
+func (b Binding) additionalData(t common.RecordType, field string) []byte {
+	return encodeItems(nil,
+		b.Owner,
+		strconv.FormatInt(b.ID, 10),
+		string(t),
+		field,
+	)
+}
+
+// fieldData returns the additional data of the field of the record with
+// the data key of the given format. Since formatV5 the fields are bound
+// to the record name instead of the ID, the name and the data key
+// themselves are bound to the owner and the type only.
+func (b Binding) fieldData(version byte,
+	t common.RecordType,
+	name string,
+	field string,
+) []byte {
+	if version != formatV5 {
+		return b.additionalData(t, field)
+	}
+	if field == fieldName || field == fieldDataKey {
+		name = ""
+	}
+	return encodeItems(nil, b.Owner, string(t), name, field, "by name")
+}
+
+// NameIndex returns the blind index of the record name: the keyed hash
+// to look the record up by without revealing the name to the server.
+// The index key is derived from the master key.
+func NameIndex(key common.Key, t common.RecordType, name string) string {
+	indexKey := deriveKey(key, "name index")
+	mac := hmac.New(sha256.New, indexKey[:])
+	mac.Write(encodeItems(nil, string(t), name))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// labelIndex returns the blind index of the label of the kind given
+func labelIndex(key common.Key, kind, label string) string {
+	indexKey := deriveKey(key, "label index")
+	mac := hmac.New(sha256.New, indexKey[:])
+	mac.Write(encodeItems(nil, kind, label))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// FolderIndex returns the blind index of the folder to filter
+// the records in the folder and its subfolders by
+func FolderIndex(key common.Key, folder string) string {
+	return labelIndex(key, labelFolder, common.CleanFolder(folder))
+}
+
+// TagIndex returns the blind index of the tag to filter the records by
+func TagIndex(key common.Key, tag string) string {
+	return labelIndex(key, labelTag, strings.TrimSpace(tag))
+}
+
+// LabelIndex returns the blind indexes of the folder, its parent
+// folders and the tags of the record
+func LabelIndex(key common.Key, folder string, tags []string) []string {
+	var labels []string
+	for _, prefix := range common.FolderPrefixes(folder) {
+		labels = append(labels, labelIndex(key, labelFolder, prefix))
+	}
+	for _, tag := range common.CleanTags(tags) {
+		labels = append(labels, labelIndex(key, labelTag, tag))
+	}
+	return labels
+}
+
+// seal encrypts the cleartext in the given format and prepends
+// the format version byte. The format version is authenticated
+// along with the additional data.
+func seal(key common.Key, version byte, ad, clearText []byte) (string, error) {
+	buf, err := EncryptAD(key, clearText, append([]byte{version}, ad...))
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(append([]byte{version}, buf...)), nil
+}
+
+// sealPadded pads the cleartext according to RecordPadding and encrypts it
+func sealPadded(key common.Key, ad, clearText []byte) (string, error) {
+	if RecordPadding == PaddingNone {
+		return seal(key, formatV2, ad, clearText)
+	}
+	return seal(key, formatV3, ad, pad(clearText, RecordPadding))
+}
+
+// open checks the format version byte and decrypts the ciphertext.
+// The additional data is checked since formatV2,
+// the padding is removed for formatV3.
+func open(key common.Key, ad []byte, cipherText string) ([]byte, error) {
+	buf, err := hex.DecodeString(cipherText)
+	if err != nil {
+		return nil, err
+	}
+	version := format(cipherText)
+	switch version {
+	case formatV1:
+		if RejectUnbound {
+			return nil, ErrUnbound
+		}
+		return Decrypt(key, buf[1:])
+	case formatV2, formatV4, formatV5:
+		return DecryptAD(key, buf[1:], append([]byte{version}, ad...))
+	case formatV3:
+		clearText, err := DecryptAD(key, buf[1:], append([]byte{version}, ad...))
+		if err != nil {
+			return nil, err
+		}
+		return unpad(clearText)
+	}
+	return nil, ErrUnknownFormat
+}
+
+// format returns the format version of the hex-encoded ciphertext
+func format(cipherText string) byte {
+	if len(cipherText) < 2 {
+		return 0
+	}
+	buf, err := hex.DecodeString(cipherText[:2])
+	if err != nil {
+		return 0
+	}
+	return buf[0]
+}
+
+// CheckEnvelope checks the ciphertext is the hex-encoded envelope
+// of the known format long enough to hold the nonce and the tag.
+// The ciphertext is not decrypted, so the server can check
+// the records it cannot read.
+func CheckEnvelope(cipherText string) error {
+	buf, err := hex.DecodeString(cipherText)
+	if err != nil {
+		return fmt.Errorf("%w: not hex encoded", ErrMalformed)
+	}
+	if len(buf) < 1+nonceSize+tagSize {
+		return fmt.Errorf("%w: %d bytes is too short", ErrMalformed, len(buf))
+	}
+	switch buf[0] {
+	case formatV1, formatV2, formatV3, formatV4, formatV5:
+		return nil
+	}
+	return ErrUnknownFormat
+}
+
+// SealedSize returns the size of the record field envelope sealed from
+// n bytes of the cleartext padded by the policy, so the server can limit
+// the envelopes by the size of the cleartext
+func SealedSize(n int, policy PaddingPolicy) int {
+	if policy != PaddingNone {
+		n = paddedSize(n, policy)
+	}
+	return hex.EncodedLen(1 + nonceSize + n + tagSize)
+}
+
+func wrapDataKey(key common.Key, ad []byte, dataKey common.Key) (string, error) {
+	return seal(key, formatV5, ad, dataKey[:])
+}
+
+func unwrapDataKey(key common.Key, ad []byte, wrapped string) (common.Key, error) {
+	var dataKey common.Key
+	buf, err := open(key, ad, wrapped)
+	if err != nil {
+		return dataKey, err
+	}
+	if len(buf) != len(dataKey) {
+		return dataKey, errors.New("wrong data key length")
+	}
+	copy(dataKey[:], buf)
+	return dataKey, nil
+}
+
+// EncryptRecord encrypts the name and sensitive fields in record with
+// the new random data key and wraps the data key with the master key.
+// The ciphertext is bound to the record named a.Name of the owner of b,
+// so the new record is encrypted before it is stored.
+func EncryptRecord(key common.Key, b Binding, a common.Record) (common.Record, error) {
 	e := common.Record{
-		Name: a.Name,
 		Type: a.Type,
 	}
-	eOpaque, err := EncryptString(key, a.Opaque)
+	dataKey, err := NewKey()
 	if err != nil {
 		return e, err
 	}
-	eMeta, err := EncryptString(key, a.Meta)
+	ad := func(field string) []byte {
+		return b.fieldData(formatV5, a.Type, a.Name, field)
+	}
+	eName, err := seal(dataKey, formatV2, ad(fieldName), []byte(a.Name))
 	if err != nil {
 		return e, err
 	}
+	eOpaque, err := sealPadded(dataKey, ad(fieldOpaque), []byte(a.Opaque))
+	if err != nil {
+		return e, err
+	}
+	eMeta, err := sealPadded(dataKey, ad(fieldMeta), []byte(a.Meta))
+	if err != nil {
+		return e, err
+	}
+	e.Folder, e.Tags, err = encryptLabels(dataKey, ad, a)
+	if err != nil {
+		return e, err
+	}
+	eDataKey, err := wrapDataKey(key, ad(fieldDataKey), dataKey)
+	if err != nil {
+		return e, err
+	}
+	e.LabelIndex = LabelIndex(key, a.Folder, a.Tags)
+	e.Name = eName
+	e.NameIndex = NameIndex(key, a.Type, a.Name)
 	e.Opaque = eOpaque
 	e.Meta = eMeta
+	e.DataKey = eDataKey
 	return e, nil
 }
 
-// DecryptRecord decrypts sensitive fields in record
-func DecryptRecord(key common.Key, e common.Record) (common.Record, error) {
+// encryptLabels encrypts the folder and the tags of the record,
+// the empty folder is kept empty
+func encryptLabels(dataKey common.Key,
+	ad func(field string) []byte,
+	a common.Record,
+) (string, []string, error) {
+	var folder string
+	var tags []string
+	var err error
+	if f := common.CleanFolder(a.Folder); f != "" {
+		folder, err = seal(dataKey, formatV2, ad(fieldFolder), []byte(f))
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	for _, tag := range common.CleanTags(a.Tags) {
+		eTag, err := seal(dataKey, formatV2, ad(fieldTag), []byte(tag))
+		if err != nil {
+			return "", nil, err
+		}
+		tags = append(tags, eTag)
+	}
+	return folder, tags, nil
+}
+
+// decryptLabels decrypts the folder and the tags of the record
+func decryptLabels(dataKey common.Key,
+	ad func(field string) []byte,
+	e common.Record,
+) (string, []string, error) {
+	var folder string
+	var tags []string
+	if e.Folder != "" {
+		buf, err := open(dataKey, ad(fieldFolder), e.Folder)
+		if err != nil {
+			return "", nil, err
+		}
+		folder = string(buf)
+	}
+	for _, eTag := range e.Tags {
+		buf, err := open(dataKey, ad(fieldTag), eTag)
+		if err != nil {
+			return "", nil, err
+		}
+		tags = append(tags, string(buf))
+	}
+	return folder, tags, nil
+}
+
+// openRecord unwraps the data key of the record bound to b and decrypts
+// the record name if it is encrypted, i.e. the data key is wrapped
+// in formatV4 or later. The format version is authenticated with
+// the data key, so the server cannot pass a clear text name off
+// as the name of the record. It returns the additional data
+// of the other record fields.
+func openRecord(key common.Key,
+	b Binding,
+	e common.Record,
+) (common.Key, string, func(field string) []byte, error) {
+	version := format(e.DataKey)
+	dataKey, err := unwrapDataKey(key,
+		b.fieldData(version, e.Type, "", fieldDataKey),
+		e.DataKey,
+	)
+	if err != nil {
+		return dataKey, "", nil, err
+	}
+	name := e.Name
+	if version == formatV4 || version == formatV5 {
+		buf, err := open(dataKey,
+			b.fieldData(version, e.Type, "", fieldName),
+			e.Name,
+		)
+		if err != nil {
+			return dataKey, "", nil, err
+		}
+		name = string(buf)
+	}
+	ad := func(field string) []byte {
+		return b.fieldData(version, e.Type, name, field)
+	}
+	return dataKey, name, ad, nil
+}
+
+// LegacyFormat reports if the record is stored in the format of the previous
+// versions: with no data key, with the clear text name or bound to the ID
+func LegacyFormat(e common.Record) bool {
+	return format(e.DataKey) != formatV5
+}
+
+// DecryptRecordName decrypts the name of the record bound to b.
+// Only name, type and data key fields of the record are required,
+// as the records are listed by the server.
+func DecryptRecordName(key common.Key, b Binding, e common.Record) (string, error) {
+	if e.DataKey == "" {
+		return e.Name, unboundError()
+	}
+	_, name, _, err := openRecord(key, b, e)
+	return name, err
+}
+
+// DecryptRecordLabels decrypts the name, the folder and the tags
+// of the record bound to b, as the records are listed by the server
+func DecryptRecordLabels(key common.Key, b Binding, e common.Record) (common.Record, error) {
+	a := common.Record{
+		Name: e.Name,
+		Type: e.Type,
+	}
+	if e.DataKey == "" {
+		return a, unboundError()
+	}
+	dataKey, name, ad, err := openRecord(key, b, e)
+	if err != nil {
+		return a, err
+	}
+	a.Name = name
+	a.Folder, a.Tags, err = decryptLabels(dataKey, ad, e)
+	return a, err
+}
+
+// DecryptRecord decrypts the name and sensitive fields in record bound to b.
+// The records without data key are decrypted with the master key.
+func DecryptRecord(key common.Key, b Binding, e common.Record) (common.Record, error) {
+	if e.DataKey == "" {
+		return decryptRecordV0(key, e)
+	}
+	a := common.Record{
+		Type: e.Type,
+	}
+	dataKey, name, ad, err := openRecord(key, b, e)
+	if err != nil {
+		return e, err
+	}
+	a.Name = name
+	Opaque, err := open(dataKey, ad(fieldOpaque), e.Opaque)
+	if err != nil {
+		return e, err
+	}
+	Meta, err := open(dataKey, ad(fieldMeta), e.Meta)
+	if err != nil {
+		return e, err
+	}
+	a.Folder, a.Tags, err = decryptLabels(dataKey, ad, e)
+	if err != nil {
+		return e, err
+	}
+	a.Opaque = string(Opaque)
+	a.Meta = string(Meta)
+	return a, nil
+}
+
+// unboundError returns ErrUnbound if the records with no binding
+// are rejected
+func unboundError() error {
+	if RejectUnbound {
+		return ErrUnbound
+	}
+	return nil
+}
+
+// decryptRecordV0 decrypts the record encrypted
+// with the master key directly
+func decryptRecordV0(key common.Key, e common.Record) (common.Record, error) {
+	if err := unboundError(); err != nil {
+		return e, err
+	}
 	a := common.Record{
 		Name: e.Name,
 		Type: e.Type,
@@ -41,3 +501,35 @@ func DecryptRecord(key common.Key, e common.Record) (common.Record, error) {
 	a.Meta = Meta
 	return a, nil
 }
+
+// RewrapRecord re-encrypts the record bound to b for the new master key.
+// Only the data key is re-encrypted and the blind indexes are recalculated,
+// the records in the older formats are converted to the current one.
+func RewrapRecord(oldKey, newKey common.Key,
+	b Binding,
+	e common.Record,
+) (common.Record, error) {
+	if LegacyFormat(e) {
+		a, err := DecryptRecord(oldKey, b, e)
+		if err != nil {
+			return e, err
+		}
+		return EncryptRecord(newKey, b, a)
+	}
+	dataKey, name, ad, err := openRecord(oldKey, b, e)
+	if err != nil {
+		return e, err
+	}
+	folder, tags, err := decryptLabels(dataKey, ad, e)
+	if err != nil {
+		return e, err
+	}
+	r := e
+	r.DataKey, err = wrapDataKey(newKey, ad(fieldDataKey), dataKey)
+	if err != nil {
+		return e, err
+	}
+	r.NameIndex = NameIndex(newKey, e.Type, name)
+	r.LabelIndex = LabelIndex(newKey, folder, tags)
+	return r, nil
+}
@@ -1,12 +1,16 @@
 package crypt
 
 import (
+	"encoding/hex"
 	"testing"
 
 	"github.com/alexey-mavrin/graduate-2/internal/common"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+var testBinding = Binding{Owner: RecordOwner("user1", ""), ID: 1}
+
 func Test_cryptRecord(t *testing.T) {
 	key := MakeKey("qwerty")
 	record := common.Record{
@@ -15,10 +19,498 @@ func Test_cryptRecord(t *testing.T) {
 		Opaque: "1111",
 		Meta:   "yo-ho-ho",
 	}
-	eRecord, err := EncryptRecord(key, record)
+	eRecord, err := EncryptRecord(key, testBinding, record)
 	assert.NoError(t, err)
 	assert.NotEqual(t, eRecord, record)
-	decr, err := DecryptRecord(key, eRecord)
+	assert.NotContains(t, eRecord.Name, record.Name)
+	assert.Equal(t, NameIndex(key, record.Type, record.Name), eRecord.NameIndex)
+	decr, err := DecryptRecord(key, testBinding, eRecord)
+	assert.NoError(t, err)
+	assert.Equal(t, record, decr)
+
+	// the records are listed with name, type and data key only
+	name, err := DecryptRecordName(key, testBinding, common.Record{
+		Name:      eRecord.Name,
+		Type:      eRecord.Type,
+		DataKey:   eRecord.DataKey,
+		NameIndex: eRecord.NameIndex,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, record.Name, name)
+}
+
+func Test_nameIndex(t *testing.T) {
+	key := MakeKey("qwerty")
+	index := NameIndex(key, common.NoteRecord, "name")
+	assert.Equal(t, index, NameIndex(key, common.NoteRecord, "name"))
+	assert.NotEqual(t, index, NameIndex(key, common.NoteRecord, "name2"))
+	assert.NotEqual(t, index, NameIndex(key, common.AccountRecord, "name"))
+	assert.NotEqual(t, index, NameIndex(MakeKey("asdfgh"), common.NoteRecord, "name"))
+	// the items are not concatenated ambiguously
+	assert.NotEqual(t,
+		NameIndex(key, common.RecordType("ab"), "c"),
+		NameIndex(key, common.RecordType("a"), "bc"),
+	)
+}
+
+func Test_cryptRecordLegacy(t *testing.T) {
+	key := MakeKey("qwerty")
+	record := common.Record{
+		Name:   "name",
+		Type:   common.NoteRecord,
+		Opaque: "1111",
+		Meta:   "yo-ho-ho",
+	}
+	// the record encrypted with the master key directly
+	eRecord := record
+	var err error
+	eRecord.Opaque, err = EncryptString(key, record.Opaque)
+	assert.NoError(t, err)
+	eRecord.Meta, err = EncryptString(key, record.Meta)
+	assert.NoError(t, err)
+
+	decr, err := DecryptRecord(key, testBinding, eRecord)
+	assert.NoError(t, err)
+	assert.Equal(t, record, decr)
+}
+
+// sealV1 encrypts the cleartext in formatV1, without additional data
+func sealV1(t *testing.T, key common.Key, clearText []byte) string {
+	buf, err := Encrypt(key, clearText)
+	require.NoError(t, err)
+	return hex.EncodeToString(append([]byte{formatV1}, buf...))
+}
+
+func Test_cryptRecordV1(t *testing.T) {
+	key := MakeKey("qwerty")
+	dataKey, err := NewKey()
+	require.NoError(t, err)
+	record := common.Record{
+		Name:   "name",
+		Type:   common.NoteRecord,
+		Opaque: "1111",
+		Meta:   "yo-ho-ho",
+	}
+	eRecord := common.Record{
+		Name:    record.Name,
+		Type:    record.Type,
+		Opaque:  sealV1(t, dataKey, []byte(record.Opaque)),
+		Meta:    sealV1(t, dataKey, []byte(record.Meta)),
+		DataKey: sealV1(t, key, dataKey[:]),
+	}
+
+	decr, err := DecryptRecord(key, testBinding, eRecord)
+	assert.NoError(t, err)
+	assert.Equal(t, record, decr)
+
+	// rewrap converts the record to the current format
+	rewrapped, err := RewrapRecord(key, key, testBinding, eRecord)
+	assert.NoError(t, err)
+	assert.Equal(t, formatV5, format(rewrapped.DataKey))
+	assert.Equal(t, formatV3, format(rewrapped.Opaque))
+	decr, err = DecryptRecord(key, testBinding, rewrapped)
+	assert.NoError(t, err)
+	assert.Equal(t, record, decr)
+}
+
+func Test_cryptRecordUnbound(t *testing.T) {
+	defer func(reject bool) { RejectUnbound = reject }(RejectUnbound)
+	key := MakeKey("qwerty")
+	dataKey, err := NewKey()
+	require.NoError(t, err)
+	record := common.Record{
+		Name:   "name",
+		Type:   common.NoteRecord,
+		Opaque: "1111",
+		Meta:   "yo-ho-ho",
+	}
+	v1Record := common.Record{
+		Name:    record.Name,
+		Type:    record.Type,
+		Opaque:  sealV1(t, dataKey, []byte(record.Opaque)),
+		Meta:    sealV1(t, dataKey, []byte(record.Meta)),
+		DataKey: sealV1(t, key, dataKey[:]),
+	}
+	v0Record := record
+	v0Record.Opaque, err = EncryptString(key, record.Opaque)
+	require.NoError(t, err)
+	v0Record.Meta, err = EncryptString(key, record.Meta)
+	require.NoError(t, err)
+	// the legacy records replayed by the server in place of another record
+	otherBinding := Binding{Owner: RecordOwner("user2", ""), ID: 2}
+
+	RejectUnbound = false
+	for _, e := range []common.Record{v1Record, v0Record} {
+		decr, err := DecryptRecord(key, otherBinding, e)
+		assert.NoError(t, err)
+		assert.Equal(t, record, decr)
+	}
+
+	RejectUnbound = true
+	for _, e := range []common.Record{v1Record, v0Record} {
+		_, err = DecryptRecord(key, otherBinding, e)
+		assert.ErrorIs(t, err, ErrUnbound)
+		_, err = DecryptRecordLabels(key, otherBinding, e)
+		assert.ErrorIs(t, err, ErrUnbound)
+	}
+	_, err = DecryptRecordName(key, otherBinding, v0Record)
+	assert.ErrorIs(t, err, ErrUnbound)
+
+	// the records bound are decrypted
+	eRecord, err := EncryptRecord(key, testBinding, record)
+	require.NoError(t, err)
+	decr, err := DecryptRecord(key, testBinding, eRecord)
+	assert.NoError(t, err)
+	assert.Equal(t, record, decr)
+}
+
+func Test_cryptRecordClearName(t *testing.T) {
+	key := MakeKey("qwerty")
+	dataKey, err := NewKey()
+	require.NoError(t, err)
+	record := common.Record{
+		Name:   "name",
+		Type:   common.NoteRecord,
+		Opaque: "1111",
+		Meta:   "yo-ho-ho",
+	}
+	sealV2 := func(key common.Key, field string, clearText []byte) string {
+		cipherText, err := seal(key,
+			formatV2,
+			testBinding.additionalData(record.Type, field),
+			clearText,
+		)
+		require.NoError(t, err)
+		return cipherText
+	}
+	// the record stored before the names are encrypted
+	eRecord := common.Record{
+		Name:    record.Name,
+		Type:    record.Type,
+		Opaque:  sealV2(dataKey, fieldOpaque, []byte(record.Opaque)),
+		Meta:    sealV2(dataKey, fieldMeta, []byte(record.Meta)),
+		DataKey: sealV2(key, fieldDataKey, dataKey[:]),
+	}
+
+	decr, err := DecryptRecord(key, testBinding, eRecord)
 	assert.NoError(t, err)
 	assert.Equal(t, record, decr)
+
+	// rewrap encrypts the name
+	assert.True(t, LegacyFormat(eRecord))
+	rewrapped, err := RewrapRecord(key, key, testBinding, eRecord)
+	assert.NoError(t, err)
+	assert.False(t, LegacyFormat(rewrapped))
+	assert.NotEqual(t, record.Name, rewrapped.Name)
+	assert.Equal(t, NameIndex(key, record.Type, record.Name), rewrapped.NameIndex)
+	decr, err = DecryptRecord(key, testBinding, rewrapped)
+	assert.NoError(t, err)
+	assert.Equal(t, record, decr)
+}
+
+func TestCheckEnvelope(t *testing.T) {
+	key := MakeKey("qwerty")
+	eRecord, err := EncryptRecord(key, testBinding, common.Record{
+		Type: common.NoteRecord,
+	})
+	require.NoError(t, err)
+	for _, cipherText := range []string{
+		eRecord.Name,
+		eRecord.Opaque,
+		eRecord.Meta,
+		eRecord.DataKey,
+		sealV1(t, key, nil),
+	} {
+		assert.NoError(t, CheckEnvelope(cipherText))
+	}
+
+	assert.ErrorIs(t, CheckEnvelope(""), ErrMalformed)
+	assert.ErrorIs(t, CheckEnvelope("name"), ErrMalformed)
+	assert.ErrorIs(t, CheckEnvelope(eRecord.Name[:40]), ErrMalformed)
+	legacy, err := EncryptString(key, "")
+	require.NoError(t, err)
+	assert.Error(t, CheckEnvelope(legacy))
+	assert.ErrorIs(t, CheckEnvelope("09"+eRecord.Name[2:]), ErrUnknownFormat)
+}
+
+func Test_cryptRecordSwap(t *testing.T) {
+	key := MakeKey("qwerty")
+	record1 := common.Record{
+		Name:   "name1",
+		Type:   common.NoteRecord,
+		Opaque: "1111",
+		Meta:   "yo-ho-ho",
+	}
+	record2 := common.Record{
+		Name:   "name2",
+		Type:   common.NoteRecord,
+		Opaque: "2222",
+		Meta:   "and a bottle of rum",
+	}
+	binding2 := Binding{Owner: testBinding.Owner, ID: 2}
+
+	eRecord1, err := EncryptRecord(key, testBinding, record1)
+	require.NoError(t, err)
+	eRecord2, err := EncryptRecord(key, binding2, record2)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		binding Binding
+		record  func() common.Record
+	}{
+		{
+			name:    "whole record moved to another owner",
+			binding: Binding{Owner: RecordOwner("user2", ""), ID: 1},
+			record:  func() common.Record { return eRecord1 },
+		},
+		{
+			name:    "whole record moved to the vault",
+			binding: Binding{Owner: RecordOwner("user1", "user1"), ID: 1},
+			record:  func() common.Record { return eRecord1 },
+		},
+		{
+			name:    "record type changed",
+			binding: testBinding,
+			record: func() common.Record {
+				r := eRecord1
+				r.Type = common.AccountRecord
+				return r
+			},
+		},
+		{
+			name:    "name taken from another record",
+			binding: testBinding,
+			record: func() common.Record {
+				r := eRecord1
+				r.Name = eRecord2.Name
+				return r
+			},
+		},
+		{
+			name:    "opaque and meta swapped",
+			binding: testBinding,
+			record: func() common.Record {
+				r := eRecord1
+				r.Opaque, r.Meta = r.Meta, r.Opaque
+				return r
+			},
+		},
+		{
+			name:    "name and data key taken from another record",
+			binding: testBinding,
+			record: func() common.Record {
+				r := eRecord1
+				r.Name = eRecord2.Name
+				r.DataKey = eRecord2.DataKey
+				return r
+			},
+		},
+		{
+			name:    "opaque and data key taken from another record",
+			binding: testBinding,
+			record: func() common.Record {
+				r := eRecord1
+				r.Opaque = eRecord2.Opaque
+				r.DataKey = eRecord2.DataKey
+				return r
+			},
+		},
+		{
+			name:    "format downgraded",
+			binding: testBinding,
+			record: func() common.Record {
+				r := eRecord1
+				r.Opaque = "01" + r.Opaque[2:]
+				return r
+			},
+		},
+		{
+			name:    "clear text name passed off",
+			binding: testBinding,
+			record: func() common.Record {
+				r := eRecord1
+				r.Name = "name2"
+				r.NameIndex = ""
+				return r
+			},
+		},
+		{
+			name:    "data key format downgraded",
+			binding: testBinding,
+			record: func() common.Record {
+				r := eRecord1
+				r.DataKey = "02" + r.DataKey[2:]
+				return r
+			},
+		},
+		{
+			name:    "unknown format",
+			binding: testBinding,
+			record: func() common.Record {
+				r := eRecord1
+				r.Meta = "ff" + r.Meta[2:]
+				return r
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecryptRecord(key, tt.binding, tt.record())
+			assert.Error(t, err)
+		})
+	}
+
+	// the untouched records are still decrypted
+	decr, err := DecryptRecord(key, binding2, eRecord2)
+	assert.NoError(t, err)
+	assert.Equal(t, record2, decr)
+
+	// the record is bound to its name, not to the ID assigned by the server:
+	// the record moved to another ID is read with its own name
+	decr, err = DecryptRecord(key, binding2, eRecord1)
+	assert.NoError(t, err)
+	assert.Equal(t, record1, decr)
+}
+
+func Test_cryptRecordIDBound(t *testing.T) {
+	key := MakeKey("qwerty")
+	dataKey, err := NewKey()
+	require.NoError(t, err)
+	record := common.Record{
+		Name:   "name",
+		Type:   common.NoteRecord,
+		Opaque: "1111",
+		Meta:   "yo-ho-ho",
+	}
+	sealAs := func(version byte, key common.Key, field string, clearText []byte) string {
+		cipherText, err := seal(key,
+			version,
+			testBinding.additionalData(record.Type, field),
+			clearText,
+		)
+		require.NoError(t, err)
+		return cipherText
+	}
+	// the record encrypted bound to the ID assigned by the server
+	eRecord := common.Record{
+		Name:      sealAs(formatV2, dataKey, fieldName, []byte(record.Name)),
+		Type:      record.Type,
+		Opaque:    sealAs(formatV2, dataKey, fieldOpaque, []byte(record.Opaque)),
+		Meta:      sealAs(formatV2, dataKey, fieldMeta, []byte(record.Meta)),
+		DataKey:   sealAs(formatV4, key, fieldDataKey, dataKey[:]),
+		NameIndex: NameIndex(key, record.Type, record.Name),
+	}
+
+	decr, err := DecryptRecord(key, testBinding, eRecord)
+	assert.NoError(t, err)
+	assert.Equal(t, record, decr)
+	_, err = DecryptRecord(key, Binding{Owner: testBinding.Owner, ID: 2}, eRecord)
+	assert.Error(t, err)
+
+	// rewrap binds the record to its name
+	assert.True(t, LegacyFormat(eRecord))
+	rewrapped, err := RewrapRecord(key, key, testBinding, eRecord)
+	assert.NoError(t, err)
+	assert.False(t, LegacyFormat(rewrapped))
+	decr, err = DecryptRecord(key, Binding{Owner: testBinding.Owner}, rewrapped)
+	assert.NoError(t, err)
+	assert.Equal(t, record, decr)
+}
+
+func Test_rewrapRecord(t *testing.T) {
+	oldKey := MakeKey("old key phrase")
+	newKey := MakeKey("new key phrase")
+	record := common.Record{
+		Name:   "name",
+		Type:   common.NoteRecord,
+		Opaque: "1111",
+		Meta:   "yo-ho-ho",
+	}
+	eRecord, err := EncryptRecord(oldKey, testBinding, record)
+	assert.NoError(t, err)
+
+	rewrapped, err := RewrapRecord(oldKey, newKey, testBinding, eRecord)
+	assert.NoError(t, err)
+	// the payload is not re-encrypted
+	assert.Equal(t, eRecord.Opaque, rewrapped.Opaque)
+	assert.Equal(t, eRecord.Meta, rewrapped.Meta)
+	assert.Equal(t, eRecord.Name, rewrapped.Name)
+	assert.NotEqual(t, eRecord.DataKey, rewrapped.DataKey)
+	// the name index is recalculated for the new key
+	assert.Equal(t, NameIndex(newKey, record.Type, record.Name), rewrapped.NameIndex)
+
+	decr, err := DecryptRecord(newKey, testBinding, rewrapped)
+	assert.NoError(t, err)
+	assert.Equal(t, record, decr)
+
+	_, err = DecryptRecord(oldKey, testBinding, rewrapped)
+	assert.Error(t, err)
+}
+
+func Test_cryptRecordLabels(t *testing.T) {
+	key := MakeKey("qwerty")
+	record := common.Record{
+		Name:   "name",
+		Type:   common.NoteRecord,
+		Opaque: "1111",
+		Meta:   "yo-ho-ho",
+		Folder: "work/vpn",
+		Tags:   []string{"prod", "office"},
+	}
+	eRecord, err := EncryptRecord(key, testBinding, record)
+	require.NoError(t, err)
+	assert.NotContains(t, eRecord.Folder, "work")
+	assert.Len(t, eRecord.Tags, 2)
+	for _, tag := range eRecord.Tags {
+		assert.NotContains(t, tag, "prod")
+	}
+	assert.ElementsMatch(t, []string{
+		FolderIndex(key, "work"),
+		FolderIndex(key, "/work/vpn/"),
+		TagIndex(key, "prod"),
+		TagIndex(key, "office"),
+	}, eRecord.LabelIndex)
+	assert.NotEqual(t, FolderIndex(key, "prod"), TagIndex(key, "prod"))
+
+	decr, err := DecryptRecord(key, testBinding, eRecord)
+	require.NoError(t, err)
+	expRecord := record
+	expRecord.Tags = []string{"office", "prod"}
+	assert.Equal(t, expRecord, decr)
+
+	// the records are listed without opaque and meta
+	listed, err := DecryptRecordLabels(key, testBinding, common.Record{
+		Name:      eRecord.Name,
+		Type:      eRecord.Type,
+		DataKey:   eRecord.DataKey,
+		NameIndex: eRecord.NameIndex,
+		Folder:    eRecord.Folder,
+		Tags:      eRecord.Tags,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, common.Record{
+		Name:   record.Name,
+		Type:   record.Type,
+		Folder: record.Folder,
+		Tags:   expRecord.Tags,
+	}, listed)
+
+	// the folder moved to another record fails to decrypt
+	binding2 := Binding{Owner: testBinding.Owner, ID: 2}
+	eRecord2, err := EncryptRecord(key, binding2, common.Record{
+		Name: "name2",
+		Type: common.NoteRecord,
+	})
+	require.NoError(t, err)
+	eRecord2.Folder = eRecord.Folder
+	_, err = DecryptRecord(key, binding2, eRecord2)
+	assert.Error(t, err)
+
+	// the label index is recalculated for the new key
+	newKey := MakeKey("new key phrase")
+	rewrapped, err := RewrapRecord(key, newKey, testBinding, eRecord)
+	require.NoError(t, err)
+	assert.Equal(t, LabelIndex(newKey, record.Folder, record.Tags),
+		rewrapped.LabelIndex)
 }
@@ -0,0 +1,191 @@
+package generator
+
+import (
+	"crypto/rand"
+	_ "embed" // the passphrase word list is embedded
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const (
+	// DefaultLength is the length of the password generated by default
+	DefaultLength = 20
+	// DefaultClasses are the character classes used by default
+	DefaultClasses = "luds"
+	// DefaultSeparator separates the words of the passphrase by default
+	DefaultSeparator = "-"
+	// MaxLength is the maximal length of the password
+	MaxLength = 256
+	// MaxWords is the maximal number of the passphrase words
+	MaxWords = 64
+)
+
+// character classes
+const (
+	lowerChars  = "abcdefghijklmnopqrstuvwxyz"
+	upperChars  = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	digitChars  = "0123456789"
+	symbolChars = "!#$%&*+-.:;=?@^_~()[]{}<>/,"
+	// ambiguousChars are easily confused with each other
+	ambiguousChars = "0Oo1lI|"
+)
+
+// ErrBadPolicy is returned when no password can be generated by the policy
+var ErrBadPolicy = errors.New("bad password policy")
+
+//go:embed wordlist.txt
+var wordList string
+
+// words of the passphrases
+var words = strings.Fields(wordList)
+
+// Policy defines the password generated. The zero values stand
+// for the defaults. If Words is set, the passphrase of Words words
+// is generated instead of the password.
+type Policy struct {
+	// Length is the password length
+	Length int `json:"length,omitempty"`
+	// Classes are the character classes of the password:
+	// l - lower case letters, u - upper case letters,
+	// d - digits, s - symbols. Every class is used at least once.
+	Classes string `json:"classes,omitempty"`
+	// NoAmbiguous excludes the characters like 0 and O, 1 and l
+	NoAmbiguous bool `json:"no_ambiguous,omitempty"`
+	// Exclude are the characters never used, e.g. not accepted by the site
+	Exclude string `json:"exclude,omitempty"`
+	// Words is the number of the passphrase words
+	Words int `json:"words,omitempty"`
+	// Separator separates the passphrase words
+	Separator string `json:"separator,omitempty"`
+}
+
+// Generate returns the password or the passphrase generated by the policy
+func Generate(p Policy) (string, error) {
+	if p.Words != 0 {
+		return passphrase(p)
+	}
+	return password(p)
+}
+
+// charSets returns the characters of every class of the policy
+// without the characters excluded
+func charSets(p Policy) ([]string, error) {
+	classes := p.Classes
+	if classes == "" {
+		classes = DefaultClasses
+	}
+	exclude := p.Exclude
+	if p.NoAmbiguous {
+		exclude += ambiguousChars
+	}
+
+	var sets []string
+	seen := make(map[rune]bool)
+	for _, c := range classes {
+		if seen[c] {
+			continue
+		}
+		seen[c] = true
+
+		var chars string
+		switch c {
+		case 'l':
+			chars = lowerChars
+		case 'u':
+			chars = upperChars
+		case 'd':
+			chars = digitChars
+		case 's':
+			chars = symbolChars
+		default:
+			return nil, fmt.Errorf("%w: unknown character class %q", ErrBadPolicy, c)
+		}
+		chars = strings.Map(func(r rune) rune {
+			if strings.ContainsRune(exclude, r) {
+				return -1
+			}
+			return r
+		}, chars)
+		if chars == "" {
+			return nil, fmt.Errorf("%w: all the characters of class %q are excluded",
+				ErrBadPolicy, c)
+		}
+		sets = append(sets, chars)
+	}
+	return sets, nil
+}
+
+// password generates the password containing the characters
+// of every class of the policy
+func password(p Policy) (string, error) {
+	length := p.Length
+	if length == 0 {
+		length = DefaultLength
+	}
+	sets, err := charSets(p)
+	if err != nil {
+		return "", err
+	}
+	if length < len(sets) || length > MaxLength {
+		return "", fmt.Errorf("%w: length should be from %d to %d",
+			ErrBadPolicy, len(sets), MaxLength)
+	}
+
+	all := strings.Join(sets, "")
+	buf := make([]byte, length)
+	for i := range buf {
+		chars := all
+		if i < len(sets) {
+			// one character of every class at least
+			chars = sets[i]
+		}
+		n, err := randInt(len(chars))
+		if err != nil {
+			return "", err
+		}
+		buf[i] = chars[n]
+	}
+
+	// shuffle the characters of the classes guaranteed
+	for i := len(buf) - 1; i > 0; i-- {
+		j, err := randInt(i + 1)
+		if err != nil {
+			return "", err
+		}
+		buf[i], buf[j] = buf[j], buf[i]
+	}
+	return string(buf), nil
+}
+
+// passphrase generates the passphrase of the words from the word list
+func passphrase(p Policy) (string, error) {
+	if p.Words < 1 || p.Words > MaxWords {
+		return "", fmt.Errorf("%w: number of words should be from 1 to %d",
+			ErrBadPolicy, MaxWords)
+	}
+	separator := p.Separator
+	if separator == "" {
+		separator = DefaultSeparator
+	}
+
+	phrase := make([]string, p.Words)
+	for i := range phrase {
+		n, err := randInt(len(words))
+		if err != nil {
+			return "", err
+		}
+		phrase[i] = words[n]
+	}
+	return strings.Join(phrase, separator), nil
+}
+
+// randInt returns the uniform random number in [0, n)
+func randInt(n int) (int, error) {
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(v.Int64()), nil
+}
@@ -0,0 +1,128 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  Policy
+		wantLen int
+		allowed string
+		wantErr bool
+	}{
+		{
+			name:    "default",
+			policy:  Policy{},
+			wantLen: DefaultLength,
+			allowed: lowerChars + upperChars + digitChars + symbolChars,
+		},
+		{
+			name:    "digits only",
+			policy:  Policy{Length: 6, Classes: "d"},
+			wantLen: 6,
+			allowed: digitChars,
+		},
+		{
+			name:    "no ambiguous",
+			policy:  Policy{Length: 64, Classes: "lud", NoAmbiguous: true},
+			wantLen: 64,
+			allowed: "abcdefghijkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789",
+		},
+		{
+			name:    "excluded",
+			policy:  Policy{Length: 32, Classes: "ds", Exclude: "0123456789"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown class",
+			policy:  Policy{Classes: "x"},
+			wantErr: true,
+		},
+		{
+			name:    "too short",
+			policy:  Policy{Length: 3, Classes: "luds"},
+			wantErr: true,
+		},
+		{
+			name:    "too long",
+			policy:  Policy{Length: MaxLength + 1},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Generate(tt.policy)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrBadPolicy)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, got, tt.wantLen)
+			for _, c := range got {
+				assert.Contains(t, tt.allowed, string(c))
+			}
+		})
+	}
+}
+
+func TestGenerate_allClasses(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		got, err := Generate(Policy{Length: 4, Classes: "luds"})
+		require.NoError(t, err)
+		assert.True(t, strings.ContainsAny(got, lowerChars), got)
+		assert.True(t, strings.ContainsAny(got, upperChars), got)
+		assert.True(t, strings.ContainsAny(got, digitChars), got)
+		assert.True(t, strings.ContainsAny(got, symbolChars), got)
+	}
+}
+
+func TestGenerate_passphrase(t *testing.T) {
+	got, err := Generate(Policy{Words: 5, Separator: " "})
+	require.NoError(t, err)
+	phrase := strings.Split(got, " ")
+	assert.Len(t, phrase, 5)
+	for _, w := range phrase {
+		assert.Contains(t, words, w)
+	}
+
+	_, err = Generate(Policy{Words: MaxWords + 1})
+	assert.ErrorIs(t, err, ErrBadPolicy)
+}
+
+func Test_wordList(t *testing.T) {
+	assert.GreaterOrEqual(t, len(words), 2000)
+	seen := make(map[string]bool)
+	for _, w := range words {
+		assert.False(t, seen[w], "duplicate word %s", w)
+		seen[w] = true
+	}
+}
+
+func TestRules_ForSite(t *testing.T) {
+	def := Policy{Length: 20}
+	rules := Rules{
+		"example.com":      {Length: 12, Classes: "lud"},
+		"bank.example.com": {Length: 8, Classes: "d"},
+	}
+	tests := []struct {
+		site string
+		want Policy
+	}{
+		{"https://example.com/login", rules["example.com"]},
+		{"https://WWW.Example.com:8443", rules["example.com"]},
+		{"bank.example.com", rules["bank.example.com"]},
+		{"https://my.bank.example.com/", rules["bank.example.com"]},
+		{"http://www.bank.example.com:80/", rules["bank.example.com"]},
+		{"https://example.org", def},
+		{"", def},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, rules.ForSite(tt.site, def), tt.site)
+	}
+}
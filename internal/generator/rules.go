@@ -0,0 +1,18 @@
+package generator
+
+import "github.com/alexey-mavrin/graduate-2/internal/urlmatch"
+
+// Rules are the password policies of the sites by their host names.
+// The rule of the domain applies to its subdomains as well.
+type Rules map[string]Policy
+
+// ForSite returns the policy of the site given by URL or by host name:
+// the rule of the host or of the closest parent domain, or the default
+// policy if there is no rule for the site. The sites are matched
+// as by the URL match rules.
+func (r Rules) ForSite(site string, def Policy) Policy {
+	if p, ok := urlmatch.SiteRule(r, site); ok {
+		return p
+	}
+	return def
+}
@@ -0,0 +1,104 @@
+package otp
+
+import (
+	"crypto/hmac"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+)
+
+// ErrBadURI is returned when the otpauth URI cannot be imported
+var ErrBadURI = errors.New("bad otpauth URI")
+
+// Code returns the TOTP code for the time given (RFC 6238)
+// and the time the code is valid for
+func Code(t common.TOTP, now time.Time) (string, time.Duration, error) {
+	err := t.Check()
+	if err != nil {
+		return "", 0, err
+	}
+	key, _ := t.Key()
+	hash, _ := t.Hash()
+
+	period := int64(t.Period)
+	counter := now.Unix() / period
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+
+	mac := hmac.New(hash, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// dynamic truncation of RFC 4226
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < t.Digits; i++ {
+		mod *= 10
+	}
+
+	expires := time.Unix((counter+1)*period, 0)
+	return fmt.Sprintf("%0*d", t.Digits, value%mod), expires.Sub(now), nil
+}
+
+// ParseURI imports the TOTP secret from the otpauth URI of the format
+// otpauth://totp/Issuer:user?secret=BASE32&issuer=Issuer&algorithm=SHA1&digits=6&period=30
+// The parameters omitted take the default values.
+func ParseURI(uri string) (common.TOTP, error) {
+	t := common.TOTP{
+		Algorithm: common.DefaultTOTPAlgorithm,
+		Digits:    common.DefaultTOTPDigits,
+		Period:    common.DefaultTOTPPeriod,
+	}
+
+	u, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil {
+		return t, fmt.Errorf("%w: %v", ErrBadURI, err)
+	}
+	if u.Scheme != "otpauth" {
+		return t, fmt.Errorf("%w: scheme is not otpauth", ErrBadURI)
+	}
+	if u.Host != "totp" {
+		return t, fmt.Errorf("%w: %s is not supported, totp only", ErrBadURI, u.Host)
+	}
+
+	label := strings.TrimPrefix(u.Path, "/")
+	if issuer, user, ok := strings.Cut(label, ":"); ok {
+		t.Issuer = strings.TrimSpace(issuer)
+		t.UserName = strings.TrimSpace(user)
+	} else {
+		t.UserName = label
+	}
+
+	q := u.Query()
+	t.Secret = q.Get("secret")
+	if issuer := q.Get("issuer"); issuer != "" {
+		t.Issuer = issuer
+	}
+	if alg := q.Get("algorithm"); alg != "" {
+		t.Algorithm = strings.ToUpper(alg)
+	}
+	for name, value := range map[string]*int{
+		"digits": &t.Digits,
+		"period": &t.Period,
+	} {
+		if s := q.Get(name); s != "" {
+			*value, err = strconv.Atoi(s)
+			if err != nil {
+				return t, fmt.Errorf("%w: %s: %v", ErrBadURI, name, err)
+			}
+		}
+	}
+
+	err = t.Check()
+	if err != nil {
+		return t, fmt.Errorf("%w: %v", ErrBadURI, err)
+	}
+	return t, nil
+}
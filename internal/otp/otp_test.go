@@ -0,0 +1,115 @@
+package otp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// secrets of the RFC 6238 test vectors
+var rfcSecrets = map[string]string{
+	"SHA1":   "12345678901234567890",
+	"SHA256": "12345678901234567890123456789012",
+	"SHA512": "1234567890123456789012345678901234567890123456789012345678901234",
+}
+
+func TestCode(t *testing.T) {
+	tests := []struct {
+		unix int64
+		alg  string
+		want string
+	}{
+		{59, "SHA1", "94287082"},
+		{59, "SHA256", "46119246"},
+		{59, "SHA512", "90693936"},
+		{1111111109, "SHA1", "07081804"},
+		{1111111109, "SHA256", "68084774"},
+		{1234567890, "SHA512", "93441116"},
+		{20000000000, "SHA1", "65353130"},
+	}
+	for _, tt := range tests {
+		totp := common.TOTP{
+			Secret:    base32.StdEncoding.EncodeToString([]byte(rfcSecrets[tt.alg])),
+			Algorithm: tt.alg,
+			Digits:    8,
+			Period:    30,
+		}
+		code, left, err := Code(totp, time.Unix(tt.unix, 0))
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, code, "%s at %d", tt.alg, tt.unix)
+		assert.Equal(t, time.Duration(30-tt.unix%30)*time.Second, left)
+	}
+
+	_, _, err := Code(common.TOTP{Secret: "!!!", Algorithm: "SHA1", Digits: 6, Period: 30},
+		time.Now())
+	assert.Error(t, err)
+}
+
+func TestParseURI(t *testing.T) {
+	tests := []struct {
+		name    string
+		uri     string
+		want    common.TOTP
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			uri:  "otpauth://totp/alice@example.com?secret=JBSWY3DPEHPK3PXP",
+			want: common.TOTP{
+				Secret:    "JBSWY3DPEHPK3PXP",
+				Algorithm: "SHA1",
+				Digits:    6,
+				Period:    30,
+				UserName:  "alice@example.com",
+			},
+		},
+		{
+			name: "all parameters",
+			uri: "otpauth://totp/Example%20Co:alice?secret=JBSWY3DPEHPK3PXP" +
+				"&issuer=Example&algorithm=sha256&digits=8&period=60",
+			want: common.TOTP{
+				Secret:    "JBSWY3DPEHPK3PXP",
+				Algorithm: "SHA256",
+				Digits:    8,
+				Period:    60,
+				Issuer:    "Example",
+				UserName:  "alice",
+			},
+		},
+		{
+			name:    "hotp",
+			uri:     "otpauth://hotp/alice?secret=JBSWY3DPEHPK3PXP&counter=1",
+			wantErr: true,
+		},
+		{
+			name:    "scheme",
+			uri:     "https://totp/alice?secret=JBSWY3DPEHPK3PXP",
+			wantErr: true,
+		},
+		{
+			name:    "no secret",
+			uri:     "otpauth://totp/alice",
+			wantErr: true,
+		},
+		{
+			name:    "digits",
+			uri:     "otpauth://totp/alice?secret=JBSWY3DPEHPK3PXP&digits=x",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseURI(tt.uri)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrBadURI)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,306 @@
+// Package search is the full-text index over the decrypted records.
+// The record data is encrypted on the server, so the index is built
+// and kept by the client only.
+package search
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+)
+
+// record fields indexed, the data fields are indexed by their JSON
+// names registered with the record type
+const (
+	FieldName   = "name"
+	FieldMeta   = "meta"
+	FieldFolder = "folder"
+	FieldTags   = "tags"
+	// FieldCustom prefixes the names of the generic record fields
+	FieldCustom = "field:"
+)
+
+// ErrPartial is returned by the loader for the document made of the clear
+// text fields only, e.g. of the record that cannot be decrypted yet.
+// The document is indexed with no sum, so it is loaded again
+// on the next update.
+var ErrPartial = errors.New("document is partial")
+
+// Document is the decrypted record text by field.
+// Sum identifies the encrypted record the document is made of.
+type Document struct {
+	ID     int64
+	Type   common.RecordType
+	Name   string
+	Sum    string
+	Fields map[string]string
+}
+
+// entry is the indexed document, Terms are the fields of each term
+type entry struct {
+	Type  common.RecordType   `json:"type"`
+	Name  string              `json:"name"`
+	Sum   string              `json:"sum"`
+	Terms map[string][]string `json:"terms"`
+}
+
+// Index is the inverted index of the record terms
+type Index struct {
+	Docs  map[int64]entry    `json:"docs"`
+	Terms map[string][]int64 `json:"terms"`
+}
+
+// Result is the record found, Fields are the fields matched
+type Result struct {
+	ID     int64             `json:"id"`
+	Type   common.RecordType `json:"type"`
+	Name   string            `json:"name"`
+	Fields []string          `json:"fields"`
+}
+
+// NewIndex returns the empty index
+func NewIndex() *Index {
+	return &Index{
+		Docs:  make(map[int64]entry),
+		Terms: make(map[string][]int64),
+	}
+}
+
+// Unmarshal returns the index stored by Marshal
+func Unmarshal(buf []byte) (*Index, error) {
+	ix := NewIndex()
+	if err := json.Unmarshal(buf, ix); err != nil {
+		return nil, err
+	}
+	if ix.Docs == nil {
+		ix.Docs = make(map[int64]entry)
+	}
+	if ix.Terms == nil {
+		ix.Terms = make(map[string][]int64)
+	}
+	return ix, nil
+}
+
+// Marshal returns the index to store
+func (ix *Index) Marshal() ([]byte, error) {
+	return json.Marshal(ix)
+}
+
+// Sum returns the checksum of the encrypted record to detect
+// the record changed since it is indexed
+func Sum(record common.Record) string {
+	buf, _ := json.Marshal(record)
+	sum := sha256.Sum256(buf)
+	return hex.EncodeToString(sum[:])
+}
+
+// Tokens splits the text to lower case words of letters and digits
+func Tokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Len returns the number of records indexed
+func (ix *Index) Len() int {
+	return len(ix.Docs)
+}
+
+// Add indexes the document, the document with the same ID
+// indexed before is replaced
+func (ix *Index) Add(doc Document) {
+	ix.Remove(doc.ID)
+	e := entry{
+		Type:  doc.Type,
+		Name:  doc.Name,
+		Sum:   doc.Sum,
+		Terms: make(map[string][]string),
+	}
+	fields := make([]string, 0, len(doc.Fields))
+	for field := range doc.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		for _, term := range Tokens(doc.Fields[field]) {
+			known := e.Terms[term]
+			if len(known) == 0 || known[len(known)-1] != field {
+				e.Terms[term] = append(known, field)
+			}
+		}
+	}
+	for term := range e.Terms {
+		ix.Terms[term] = append(ix.Terms[term], doc.ID)
+	}
+	ix.Docs[doc.ID] = e
+}
+
+// Remove drops the document from the index
+func (ix *Index) Remove(id int64) {
+	e, ok := ix.Docs[id]
+	if !ok {
+		return
+	}
+	for term := range e.Terms {
+		ids := ix.Terms[term]
+		for i, known := range ids {
+			if known == id {
+				ids = append(ids[:i], ids[i+1:]...)
+				break
+			}
+		}
+		if len(ids) == 0 {
+			delete(ix.Terms, term)
+			continue
+		}
+		ix.Terms[term] = ids
+	}
+	delete(ix.Docs, id)
+}
+
+// Update brings the index up to the records given by their sums:
+// the records gone are dropped, the records new or changed are loaded
+// and indexed again. It returns the number of the records dropped
+// or indexed, the index is not changed if it is zero.
+func (ix *Index) Update(sums map[int64]string,
+	load func(id int64) (Document, error),
+) (int, error) {
+	changed := 0
+	for id := range ix.Docs {
+		if _, ok := sums[id]; !ok {
+			ix.Remove(id)
+			changed++
+		}
+	}
+	for id, sum := range sums {
+		if e, ok := ix.Docs[id]; ok && e.Sum == sum {
+			continue
+		}
+		doc, err := load(id)
+		partial := errors.Is(err, ErrPartial)
+		if err != nil && !partial {
+			return changed, err
+		}
+		doc.ID = id
+		doc.Sum = sum
+		if partial {
+			doc.Sum = ""
+			if e, ok := ix.Docs[id]; ok && e.Sum == "" {
+				// the document is still partial
+				ix.Add(doc)
+				continue
+			}
+		}
+		ix.Add(doc)
+		changed++
+	}
+	return changed, nil
+}
+
+// Search returns the records having all the query words, each word
+// matches the terms it is prefix of. The records matched by more
+// fields go first.
+func (ix *Index) Search(query string) []Result {
+	words := Tokens(query)
+	if len(words) == 0 {
+		return []Result{}
+	}
+
+	var found map[int64]map[string]bool
+	for _, word := range words {
+		matched := make(map[int64]map[string]bool)
+		for term, ids := range ix.Terms {
+			if !strings.HasPrefix(term, word) {
+				continue
+			}
+			for _, id := range ids {
+				if found != nil && found[id] == nil {
+					continue
+				}
+				if matched[id] == nil {
+					matched[id] = make(map[string]bool)
+					for field := range found[id] {
+						matched[id][field] = true
+					}
+				}
+				for _, field := range ix.Docs[id].Terms[term] {
+					matched[id][field] = true
+				}
+			}
+		}
+		found = matched
+	}
+
+	results := make([]Result, 0, len(found))
+	for id, fields := range found {
+		e := ix.Docs[id]
+		r := Result{ID: id, Type: e.Type, Name: e.Name}
+		for field := range fields {
+			r.Fields = append(r.Fields, field)
+		}
+		sort.Strings(r.Fields)
+		results = append(results, r)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if len(a.Fields) != len(b.Fields) {
+			return len(a.Fields) > len(b.Fields)
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.ID < b.ID
+	})
+	return results
+}
+
+// RecordDocument returns the document of the decrypted record.
+// The secret fields are included if requested.
+func RecordDocument(id int64,
+	record common.Record,
+	secrets bool,
+) (Document, error) {
+	doc := Document{
+		ID:   id,
+		Type: record.Type,
+		Name: record.Name,
+		Fields: map[string]string{
+			FieldName:   record.Name,
+			FieldMeta:   record.Meta,
+			FieldFolder: record.Folder,
+			FieldTags:   strings.Join(record.Tags, " "),
+		},
+	}
+	add := func(field, text string, secret bool) {
+		if !secret || secrets {
+			doc.Fields[field] = text
+		}
+	}
+
+	if record.Type == common.GenericRecord {
+		var g common.Generic
+		err := json.Unmarshal([]byte(record.Opaque), &g)
+		for _, f := range g.Fields {
+			add(FieldCustom+f.Name, f.Value, f.Type.IsSecret())
+		}
+		return doc, err
+	}
+	info, ok := common.LookupType(record.Type)
+	if !ok || len(info.Fields) == 0 {
+		return doc, nil
+	}
+	var data map[string]interface{}
+	err := json.Unmarshal([]byte(record.Opaque), &data)
+	for _, f := range info.Fields {
+		if text, ok := data[f.Name].(string); ok {
+			add(f.Name, text, f.Secret)
+		}
+	}
+	return doc, err
+}
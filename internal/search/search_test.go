@@ -0,0 +1,195 @@
+package search
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resultIDs(results []Result) []int64 {
+	ids := []int64{}
+	for _, r := range results {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
+
+func testRecords(t *testing.T) common.Records {
+	account, err := common.Account{
+		URL:      "https://vpn.example.com/login",
+		UserName: "alice",
+		Password: "Hunter2-psk",
+	}.Pack()
+	require.NoError(t, err)
+	note, err := common.Note{Text: "Office VPN PSK is in the safe"}.Pack()
+	require.NoError(t, err)
+	card, err := common.Card{Holder: "Alice Smith", Number: "4111111111111111"}.Pack()
+	require.NoError(t, err)
+	return common.Records{
+		1: {Name: "office vpn", Type: common.AccountRecord, Opaque: account},
+		2: {Name: "wifi", Type: common.NoteRecord, Opaque: note, Meta: "home"},
+		3: {Name: "visa", Type: common.CardRecord, Opaque: card,
+			Folder: "finance/cards", Tags: []string{"travel"}},
+	}
+}
+
+func testIndex(t *testing.T, records common.Records, secrets bool) *Index {
+	ix := NewIndex()
+	for id, record := range records {
+		doc, err := RecordDocument(id, record, secrets)
+		require.NoError(t, err)
+		ix.Add(doc)
+	}
+	return ix
+}
+
+func TestTokens(t *testing.T) {
+	assert.Equal(t,
+		[]string{"https", "vpn", "example", "com", "login", "ключ"},
+		Tokens("https://VPN.example.com/login, Ключ"),
+	)
+	assert.Empty(t, Tokens(" -- "))
+}
+
+func TestIndex_Search(t *testing.T) {
+	ix := testIndex(t, testRecords(t), false)
+
+	tests := []struct {
+		query string
+		ids   []int64
+	}{
+		{"vpn psk", []int64{2}},
+		{"VPN", []int64{1, 2}},
+		{"exam", []int64{1}},
+		{"alice", []int64{1, 3}},
+		{"home", []int64{2}},
+		{"finance cards", []int64{3}},
+		{"travel", []int64{3}},
+		{"hunter2", []int64{}},
+		{"4111", []int64{}},
+		{"", []int64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			assert.Equal(t, tt.ids, resultIDs(ix.Search(tt.query)))
+		})
+	}
+
+	results := ix.Search("vpn")
+	assert.Equal(t, []string{FieldName, "url"}, results[0].Fields)
+	assert.Equal(t, "office vpn", results[0].Name)
+	assert.Equal(t, common.AccountRecord, results[0].Type)
+}
+
+func TestIndex_SearchSecrets(t *testing.T) {
+	ix := testIndex(t, testRecords(t), true)
+	assert.Equal(t, []int64{1}, resultIDs(ix.Search("hunter2")))
+	assert.Equal(t, []int64{3}, resultIDs(ix.Search("4111")))
+}
+
+func TestRecordDocument_Generic(t *testing.T) {
+	generic, err := common.Generic{Fields: []common.Field{
+		{Name: "endpoint", Type: common.FieldURL, Value: "https://api.example.org"},
+		{Name: "api_key", Type: common.FieldSecret, Value: "sk-live-42"},
+	}}.Pack()
+	require.NoError(t, err)
+	record := common.Record{Name: "billing", Type: common.GenericRecord, Opaque: generic}
+
+	ix := testIndex(t, common.Records{4: record}, false)
+	results := ix.Search("api")
+	require.Len(t, results, 1)
+	assert.Equal(t, []string{FieldCustom + "endpoint"}, results[0].Fields)
+	assert.Empty(t, ix.Search("live"))
+
+	ix = testIndex(t, common.Records{4: record}, true)
+	assert.Equal(t, []int64{4}, resultIDs(ix.Search("sk live")))
+}
+
+func TestIndex_Update(t *testing.T) {
+	records := testRecords(t)
+	sums := make(map[int64]string)
+	for id, record := range records {
+		sums[id] = Sum(record)
+	}
+	load := func(id int64) (Document, error) {
+		return RecordDocument(id, records[id], false)
+	}
+
+	ix := NewIndex()
+	indexed, err := ix.Update(sums, load)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, indexed)
+
+	indexed, err = ix.Update(sums, load)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, indexed)
+
+	note, err := common.Note{Text: "guest network"}.Pack()
+	require.NoError(t, err)
+	records[2] = common.Record{Name: "wifi", Type: common.NoteRecord, Opaque: note}
+	sums[2] = Sum(records[2])
+	delete(sums, 3)
+	indexed, err = ix.Update(sums, load)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, indexed)
+	assert.Equal(t, 2, ix.Len())
+	assert.Equal(t, []int64{1}, resultIDs(ix.Search("vpn")))
+	assert.Equal(t, []int64{2}, resultIDs(ix.Search("guest")))
+	assert.Equal(t, []int64{}, resultIDs(ix.Search("alice smith")))
+	assert.NotContains(t, ix.Terms, "psk")
+
+	delete(sums, 2)
+	indexed, err = ix.Update(sums, load)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, indexed)
+	assert.Equal(t, 1, ix.Len())
+	assert.Equal(t, []int64{}, resultIDs(ix.Search("guest")))
+
+	// the partial document is loaded again until it is complete
+	sums[5] = "locked"
+	loads := 0
+	partialLoad := func(int64) (Document, error) {
+		loads++
+		return Document{Type: common.NoteRecord}, ErrPartial
+	}
+	indexed, err = ix.Update(sums, partialLoad)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, indexed)
+	assert.Equal(t, 2, ix.Len())
+	indexed, err = ix.Update(sums, partialLoad)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, indexed)
+	assert.Equal(t, 2, loads)
+	records[5] = common.Record{Name: "unlocked", Type: common.NoteRecord, Opaque: note}
+	indexed, err = ix.Update(sums, load)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, indexed)
+	assert.Equal(t, []int64{5}, resultIDs(ix.Search("guest")))
+	indexed, err = ix.Update(sums, load)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, indexed)
+	delete(sums, 5)
+
+	errLoad := errors.New("cannot load")
+	sums[4] = "new"
+	_, err = ix.Update(sums, func(int64) (Document, error) {
+		return Document{}, errLoad
+	})
+	assert.ErrorIs(t, err, errLoad)
+}
+
+func TestIndex_Marshal(t *testing.T) {
+	ix := testIndex(t, testRecords(t), false)
+	buf, err := ix.Marshal()
+	require.NoError(t, err)
+
+	got, err := Unmarshal(buf)
+	require.NoError(t, err)
+	assert.Equal(t, ix.Search("vpn"), got.Search("vpn"))
+
+	got.Remove(1)
+	assert.Equal(t, []int64{2}, resultIDs(got.Search("vpn")))
+}
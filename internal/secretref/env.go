@@ -0,0 +1,58 @@
+package secretref
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Var is the environment variable, its value may be the secret reference
+type Var struct {
+	Name  string
+	Value string
+}
+
+// ParseVar parses NAME=VALUE
+func ParseVar(s string) (Var, error) {
+	name, value, ok := strings.Cut(s, "=")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" || strings.ContainsAny(name, " \t") {
+		return Var{}, fmt.Errorf("bad variable %q: want NAME=VALUE", s)
+	}
+	return Var{Name: name, Value: value}, nil
+}
+
+// ParseEnv reads the variables of the .env template: NAME=VALUE lines,
+// optionally prefixed with export. Empty lines and # comments are skipped,
+// the values in single or double quotes are unquoted.
+func ParseEnv(r io.Reader) ([]Var, error) {
+	var vars []Var
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		v, err := ParseVar(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		v.Value = unquote(strings.TrimSpace(v.Value))
+		vars = append(vars, v)
+	}
+	return vars, scanner.Err()
+}
+
+// unquote removes the matching quotes around the value
+func unquote(value string) string {
+	if len(value) < 2 {
+		return value
+	}
+	q := value[0]
+	if (q == '"' || q == '\'') && value[len(value)-1] == q {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
@@ -0,0 +1,89 @@
+package secretref
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"sync"
+)
+
+// Mask replaces the secret values in the output
+const Mask = "*****"
+
+// Masker writes the output with the secret values replaced by Mask.
+// The tail of the output that may start a secret is held back until
+// the next write or Close, so the secrets split between writes
+// are masked too.
+type Masker struct {
+	mu      sync.Mutex
+	w       io.Writer
+	secrets [][]byte
+	buf     []byte
+}
+
+// NewMasker returns the Masker of the secrets writing to w,
+// the empty secrets are ignored
+func NewMasker(w io.Writer, secrets []string) *Masker {
+	m := &Masker{w: w}
+	for _, s := range secrets {
+		if s != "" {
+			m.secrets = append(m.secrets, []byte(s))
+		}
+	}
+	// the longest secret is masked first if secrets overlap
+	sort.Slice(m.secrets, func(i, j int) bool {
+		return len(m.secrets[i]) > len(m.secrets[j])
+	})
+	return m
+}
+
+// Write writes p masked, the length of p is returned on success
+func (m *Masker) Write(p []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.buf = append(m.buf, p...)
+	out, rest := m.mask(m.buf, false)
+	m.buf = append(m.buf[:0], rest...)
+	if _, err := m.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close writes the output held back
+func (m *Masker) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out, _ := m.mask(m.buf, true)
+	m.buf = m.buf[:0]
+	_, err := m.w.Write(out)
+	return err
+}
+
+// mask returns the data masked and the tail held back,
+// nothing is held back on flush
+func (m *Masker) mask(data []byte, flush bool) ([]byte, []byte) {
+	out := make([]byte, 0, len(data))
+	i := 0
+scan:
+	for i < len(data) {
+		if !flush {
+			// a longer secret may still match with the next write
+			for _, s := range m.secrets {
+				if len(data)-i < len(s) && bytes.HasPrefix(s, data[i:]) {
+					break scan
+				}
+			}
+		}
+		for _, s := range m.secrets {
+			if bytes.HasPrefix(data[i:], s) {
+				out = append(out, Mask...)
+				i += len(s)
+				continue scan
+			}
+		}
+		out = append(out, data[i])
+		i++
+	}
+	return out, data[i:]
+}
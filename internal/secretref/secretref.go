@@ -0,0 +1,83 @@
+// Package secretref resolves the secret references like
+// secret://acc/prod-db/password in the environment variables
+package secretref
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+)
+
+// Scheme is the prefix of the secret references
+const Scheme = "secret://"
+
+// ErrBadRef is returned when the secret reference is malformed
+var ErrBadRef = errors.New("bad secret reference")
+
+// ErrNoField is returned when the record has no field referenced
+var ErrNoField = errors.New("record has no such field")
+
+// Ref references the field of the record by the record type and name.
+// The field is the JSON name of the record data field, e.g. password.
+type Ref struct {
+	Type  common.RecordType
+	Name  string
+	Field string
+}
+
+// IsRef reports if the value is the secret reference
+func IsRef(value string) bool {
+	return strings.HasPrefix(value, Scheme)
+}
+
+// Parse parses the reference secret://TYPE/NAME/FIELD,
+// the record name may contain slashes
+func Parse(value string) (Ref, error) {
+	if !IsRef(value) {
+		return Ref{}, fmt.Errorf("%w %q: no %s prefix", ErrBadRef, value, Scheme)
+	}
+	path := strings.TrimPrefix(value, Scheme)
+	first := strings.Index(path, "/")
+	last := strings.LastIndex(path, "/")
+	if first <= 0 || last == first || last == len(path)-1 {
+		return Ref{}, fmt.Errorf("%w %q: want %sTYPE/NAME/FIELD",
+			ErrBadRef, value, Scheme)
+	}
+	return Ref{
+		Type:  common.RecordType(path[:first]),
+		Name:  path[first+1 : last],
+		Field: path[last+1:],
+	}, nil
+}
+
+// String returns the reference in the secret:// form
+func (r Ref) String() string {
+	return Scheme + string(r.Type) + "/" + r.Name + "/" + r.Field
+}
+
+// Field returns the value of the field of the record data,
+// the custom fields of the generic records are referenced by name
+func Field(opaque string, field string) (string, error) {
+	var data map[string]interface{}
+	err := json.Unmarshal([]byte(opaque), &data)
+	if err != nil {
+		return "", err
+	}
+	value, ok := data[field]
+	if !ok || value == nil {
+		var g common.Generic
+		if json.Unmarshal([]byte(opaque), &g) == nil {
+			if f, ok := g.Field(field); ok {
+				return f.Value, nil
+			}
+		}
+		return "", fmt.Errorf("%w: %s", ErrNoField, field)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(value), nil
+}
@@ -0,0 +1,121 @@
+package secretref
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	ref, err := Parse("secret://acc/prod-db/password")
+	require.NoError(t, err)
+	assert.Equal(t, Ref{common.AccountRecord, "prod-db", "password"}, ref)
+	assert.Equal(t, "secret://acc/prod-db/password", ref.String())
+
+	ref, err = Parse("secret://note/team/keys/text")
+	require.NoError(t, err)
+	assert.Equal(t, "team/keys", ref.Name)
+
+	for _, bad := range []string{
+		"acc/prod-db/password",
+		"secret://acc/prod-db",
+		"secret://acc/prod-db/",
+		"secret:///prod-db/password",
+	} {
+		_, err = Parse(bad)
+		assert.ErrorIs(t, err, ErrBadRef, bad)
+	}
+}
+
+func TestField(t *testing.T) {
+	opaque := `{"holder":"bob","exp_month":12,"cvc":""}`
+	value, err := Field(opaque, "holder")
+	require.NoError(t, err)
+	assert.Equal(t, "bob", value)
+
+	value, err = Field(opaque, "exp_month")
+	require.NoError(t, err)
+	assert.Equal(t, "12", value)
+
+	value, err = Field(opaque, "cvc")
+	require.NoError(t, err)
+	assert.Equal(t, "", value)
+
+	_, err = Field(opaque, "number")
+	assert.ErrorIs(t, err, ErrNoField)
+
+	generic := `{"fields":[{"name":"api_key","type":"secret","value":"k1"}]}`
+	value, err = Field(generic, "api_key")
+	require.NoError(t, err)
+	assert.Equal(t, "k1", value)
+
+	_, err = Field(generic, "endpoint")
+	assert.ErrorIs(t, err, ErrNoField)
+}
+
+func TestParseEnv(t *testing.T) {
+	env := `# service config
+DB_HOST=localhost
+export DB_PASS=secret://acc/prod-db/password
+
+GREETING="hello world"
+QUOTED='a=b'
+`
+	vars, err := ParseEnv(strings.NewReader(env))
+	require.NoError(t, err)
+	assert.Equal(t, []Var{
+		{"DB_HOST", "localhost"},
+		{"DB_PASS", "secret://acc/prod-db/password"},
+		{"GREETING", "hello world"},
+		{"QUOTED", "a=b"},
+	}, vars)
+
+	_, err = ParseEnv(strings.NewReader("OK=1\nbad line\n"))
+	assert.ErrorContains(t, err, "line 2")
+}
+
+func TestMasker(t *testing.T) {
+	var buf bytes.Buffer
+	m := NewMasker(&buf, []string{"s3cret", "", "s3cret-long"})
+	for _, chunk := range []string{"pass=s3", "cret; long=s3cret-lo", "ng; s3c"} {
+		n, err := m.Write([]byte(chunk))
+		require.NoError(t, err)
+		assert.Equal(t, len(chunk), n)
+	}
+	assert.Equal(t, "pass=*****; long=*****; ", buf.String())
+	require.NoError(t, m.Close())
+	assert.Equal(t, "pass=*****; long=*****; s3c", buf.String())
+}
+
+func TestRender(t *testing.T) {
+	values := map[Ref]string{
+		{common.AccountRecord, "prod-db", "user_name"}: "dbuser",
+		{common.NoteRecord, "tls-key", "text"}:         "-----BEGIN KEY-----",
+	}
+	resolve := func(r Ref) (string, error) {
+		value, ok := values[r]
+		if !ok {
+			return "", errors.New("record not found")
+		}
+		return value, nil
+	}
+
+	out, err := Render([]byte("user: {{ acc/prod-db/user_name }}\n"+
+		"key: {{secret://note/tls-key/text}}\n"+
+		"again: {{ acc/prod-db/user_name }}\n"), resolve)
+	require.NoError(t, err)
+	assert.Equal(t, "user: dbuser\nkey: -----BEGIN KEY-----\nagain: dbuser\n",
+		string(out))
+
+	_, err = Render([]byte("{{ acc/none/password }} {{ .Values }} {{ acc/prod-db/user_name }}"),
+		resolve)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "2 placeholders are not resolved")
+	assert.Contains(t, err.Error(), "{{ acc/none/password }}: record not found")
+	assert.Contains(t, err.Error(), "{{ .Values }}: bad secret reference")
+}
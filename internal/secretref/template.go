@@ -0,0 +1,38 @@
+package secretref
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// placeholder matches {{ TYPE/NAME/FIELD }}, the reference
+// may have the secret:// prefix
+var placeholder = regexp.MustCompile(`\{\{\s*([^{}\s]+)\s*\}\}`)
+
+// Render returns the template with the placeholders {{ TYPE/NAME/FIELD }}
+// replaced by the values resolved. All the placeholders that cannot
+// be resolved are reported in the error.
+func Render(tpl []byte, resolve func(Ref) (string, error)) ([]byte, error) {
+	var failed []string
+	out := placeholder.ReplaceAllFunc(tpl, func(match []byte) []byte {
+		value := string(placeholder.FindSubmatch(match)[1])
+		if !IsRef(value) {
+			value = Scheme + value
+		}
+		ref, err := Parse(value)
+		if err == nil {
+			value, err = resolve(ref)
+		}
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", match, err))
+			return match
+		}
+		return []byte(value)
+	})
+	if len(failed) > 0 {
+		return nil, fmt.Errorf("%d placeholders are not resolved:\n%s",
+			len(failed), strings.Join(failed, "\n"))
+	}
+	return out, nil
+}
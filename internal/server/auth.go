@@ -1,12 +1,19 @@
 package server
 
 import (
+	"errors"
 	"log"
 	"net/http"
+
+	"github.com/alexey-mavrin/graduate-2/internal/store"
 )
 
+// verifyUser checks the user password, the unknown user is not verified
 func verifyUser(user string, pass string) (bool, error) {
 	ok, err := serverStore.CheckUserAuth(user, pass)
+	if errors.Is(err, store.ErrNotFound) {
+		return false, nil
+	}
 	if err != nil {
 		return false, err
 	}
@@ -21,16 +28,16 @@ func authUser(next http.Handler) http.Handler {
 			userOK, err := verifyUser(user, pass)
 			if err != nil {
 				log.Printf("verifyUser error: %v", err)
-				writeStatus(w,
-					http.StatusForbidden,
-					"Access Denied",
+				writeError(w,
+					http.StatusInternalServerError,
+					"Internal Server Error",
 				)
 				return
 			}
 			if !userOK {
 				log.Printf("verifyUser: password incorrect")
-				writeStatus(w,
-					http.StatusForbidden,
+				writeError(w,
+					http.StatusUnauthorized,
 					"Access Denied",
 				)
 				return
@@ -47,7 +54,7 @@ func authUser(next http.Handler) http.Handler {
 				return
 			}
 			w.Header().Set("WWW-Authenticate", `Basic realm="storeapi"`)
-			writeStatus(w,
+			writeError(w,
 				http.StatusUnauthorized,
 				"Unauthorized",
 			)
@@ -61,8 +68,8 @@ func pingHandler(w http.ResponseWriter, r *http.Request) {
 	log.Printf("ping")
 	user, _, ok := r.BasicAuth()
 	if !ok {
-		writeStatus(w, http.StatusBadRequest, "no basic auth")
+		writeError(w, http.StatusBadRequest, "no basic auth")
 		return
 	}
-	writeStatus(w, http.StatusOK, "OK. User "+user)
+	writeStatus(w, "OK. User "+user)
 }
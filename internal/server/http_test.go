@@ -1,12 +1,15 @@
 package server
 
 import (
+	"encoding/json"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -35,3 +38,19 @@ func testHTTPRequest(t *testing.T,
 
 	return resp, string(respBody)
 }
+
+func Test_writeError(t *testing.T) {
+	w := httptest.NewRecorder()
+	writeError(w, http.StatusNotFound, `Record "a" not found`)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	var e common.ErrorResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&e))
+	assert.Equal(t, common.ErrorResponse{
+		Status:  common.StatusError,
+		Code:    common.CodeNotFound,
+		Message: `Record "a" not found`,
+	}, e)
+}
@@ -0,0 +1,251 @@
+package server
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"github.com/alexey-mavrin/graduate-2/internal/crypt"
+)
+
+const (
+	// DefaultMaxBodySize is the request body size limit by default
+	DefaultMaxBodySize = 64 << 20
+	// DefaultMaxNameSize is the encrypted record name size limit by default
+	DefaultMaxNameSize = 1 << 10
+	// DefaultMaxPayloadSize is the encrypted record data and metainfo
+	// size limit by default
+	DefaultMaxPayloadSize = 1 << 20
+	// DefaultMaxFileSize is the file size the binary record payload
+	// is limited by default to accept. The limit is on the padded
+	// ciphertext, so the files somewhat larger may be accepted too.
+	DefaultMaxFileSize = 8 << 20
+)
+
+// defaultPayloadSizes are the payload size limits of the types
+// holding more data than the others. The binary record holds the file
+// base64 encoded and padded to the power of two at most, the metainfo
+// is limited as the other records payload.
+var defaultPayloadSizes = map[common.RecordType]int{
+	common.BinaryRecord: crypt.SealedSize(
+		base64.StdEncoding.EncodedLen(DefaultMaxFileSize),
+		crypt.PaddingPow2,
+	) + DefaultMaxPayloadSize,
+}
+
+// Limits are the limits of the records accepted by the server.
+// The sizes are the sizes of the hex-encoded ciphertexts, the zero
+// limits are set to the defaults.
+type Limits struct {
+	// RecordTypes are the record types accepted,
+	// all the registered types if empty
+	RecordTypes []common.RecordType
+	// MaxBodySize is the request body size limit
+	MaxBodySize int64
+	// MaxNameSize is the record name size limit
+	MaxNameSize int
+	// MaxPayloadSize is the record data and metainfo size limit by type
+	MaxPayloadSize map[common.RecordType]int
+}
+
+var limits = defaultLimits()
+
+func defaultLimits() Limits {
+	return Limits{
+		RecordTypes:    common.RecordTypes(),
+		MaxBodySize:    DefaultMaxBodySize,
+		MaxNameSize:    DefaultMaxNameSize,
+		MaxPayloadSize: defaultPayloadSizes,
+	}
+}
+
+// SetLimits sets the limits of the records accepted,
+// the record types are to be registered
+func SetLimits(l Limits) error {
+	d := defaultLimits()
+	for _, t := range l.RecordTypes {
+		if !t.Valid() {
+			return fmt.Errorf("%w: %s", common.ErrUnknownType, t)
+		}
+	}
+	if len(l.RecordTypes) == 0 {
+		l.RecordTypes = d.RecordTypes
+	}
+	if l.MaxBodySize == 0 {
+		l.MaxBodySize = d.MaxBodySize
+	}
+	if l.MaxNameSize == 0 {
+		l.MaxNameSize = d.MaxNameSize
+	}
+	payload := make(map[common.RecordType]int)
+	for t, size := range d.MaxPayloadSize {
+		payload[t] = size
+	}
+	for t, size := range l.MaxPayloadSize {
+		if !t.Valid() {
+			return fmt.Errorf("%w: %s", common.ErrUnknownType, t)
+		}
+		payload[t] = size
+	}
+	l.MaxPayloadSize = payload
+	limits = l
+	return nil
+}
+
+// accepted returns true if the records of the type are accepted
+func (l Limits) accepted(t common.RecordType) bool {
+	for _, known := range l.RecordTypes {
+		if known == t {
+			return true
+		}
+	}
+	return false
+}
+
+// payloadSize returns the payload size limit of the type
+func (l Limits) payloadSize(t common.RecordType) int {
+	if size, ok := l.MaxPayloadSize[t]; ok {
+		return size
+	}
+	return DefaultMaxPayloadSize
+}
+
+// limitBody limits the size of the request bodies
+func limitBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > limits.MaxBodySize {
+			writeErrorCode(w,
+				http.StatusRequestEntityTooLarge,
+				common.CodeTooLarge,
+				fmt.Sprintf("Request Body Too Large: %d bytes exceeds %d",
+					r.ContentLength,
+					limits.MaxBodySize,
+				),
+			)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limits.MaxBodySize)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// readBody reads the request body, the error status is written
+// if the body cannot be read or is too large
+func readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	body, err := io.ReadAll(r.Body)
+	if err == nil {
+		return body, true
+	}
+	log.Print(err)
+	if int64(len(body)) >= limits.MaxBodySize {
+		writeErrorCode(w,
+			http.StatusRequestEntityTooLarge,
+			common.CodeTooLarge,
+			fmt.Sprintf("Request Body Too Large: exceeds %d bytes",
+				limits.MaxBodySize,
+			),
+		)
+		return nil, false
+	}
+	writeError(w,
+		http.StatusInternalServerError,
+		"Internal Server Error",
+	)
+	return nil, false
+}
+
+// recordError is the HTTP status and the response of the record rejected
+type recordError struct {
+	status int
+	common.ErrorResponse
+}
+
+// validateRecord checks the record type is accepted, the ciphertexts
+// are well-formed envelopes and the sizes are within the limits
+func validateRecord(l Limits, record common.Record) *recordError {
+	reject := func(status int,
+		code common.ErrorCode,
+		field string,
+		message string,
+	) *recordError {
+		return &recordError{
+			status: status,
+			ErrorResponse: common.ErrorResponse{
+				Code:    code,
+				Message: message,
+				Field:   field,
+			},
+		}
+	}
+	invalid := func(field string, err error) *recordError {
+		return reject(http.StatusBadRequest,
+			common.CodeInvalidRecord,
+			field,
+			"Invalid Record: "+err.Error(),
+		)
+	}
+
+	if !l.accepted(record.Type) {
+		return reject(http.StatusBadRequest,
+			common.CodeUnknownType,
+			"record_type",
+			fmt.Sprintf("Unknown Record Type %s", record.Type),
+		)
+	}
+	if len(record.Name) > l.MaxNameSize {
+		return invalid("name",
+			fmt.Errorf("%d bytes exceeds %d", len(record.Name), l.MaxNameSize),
+		)
+	}
+	payload := len(record.Opaque) + len(record.Meta)
+	if payload > l.payloadSize(record.Type) {
+		return reject(http.StatusRequestEntityTooLarge,
+			common.CodeTooLarge,
+			"opaque",
+			fmt.Sprintf("Record Too Large: %d bytes exceeds %d for type %s",
+				payload,
+				l.payloadSize(record.Type),
+				record.Type,
+			),
+		)
+	}
+
+	for _, f := range []struct {
+		name       string
+		cipherText string
+	}{
+		{"name", record.Name},
+		{"opaque", record.Opaque},
+		{"meta", record.Meta},
+		{"data_key", record.DataKey},
+	} {
+		if err := crypt.CheckEnvelope(f.cipherText); err != nil {
+			return invalid(f.name, err)
+		}
+	}
+	if record.Folder != "" {
+		if err := crypt.CheckEnvelope(record.Folder); err != nil {
+			return invalid("folder", err)
+		}
+	}
+	for _, tag := range record.Tags {
+		if err := crypt.CheckEnvelope(tag); err != nil {
+			return invalid("tags", err)
+		}
+	}
+	return nil
+}
+
+// checkRecord writes the error response if the record is not valid
+func checkRecord(w http.ResponseWriter, record common.Record) bool {
+	e := validateRecord(limits, record)
+	if e == nil {
+		return true
+	}
+	log.Printf("record rejected: %s: %s", e.Field, e.Message)
+	writeErrorResponse(w, e.status, e.ErrorResponse)
+	return false
+}
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"github.com/alexey-mavrin/graduate-2/internal/crypt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func postTestRecord(t *testing.T,
+	router http.Handler,
+	body string,
+) (int, common.ErrorResponse) {
+	resp, respBody := testHTTPRequest(t,
+		router,
+		http.MethodPost,
+		"/records",
+		body,
+		testUser,
+		testPass,
+	)
+	defer resp.Body.Close()
+	var e common.ErrorResponse
+	if resp.StatusCode != http.StatusOK {
+		require.NoError(t, json.Unmarshal([]byte(respBody), &e))
+	}
+	return resp.StatusCode, e
+}
+
+func Test_validateRecord(t *testing.T) {
+	router := prepareTest(t)
+	valid := testRecord("rec1", "0000", common.NoteRecord)
+
+	tests := []struct {
+		name   string
+		change func(r *common.Record)
+		code   int
+		field  string
+	}{
+		{"valid", func(r *common.Record) {}, http.StatusOK, ""},
+		{"unspecified type", func(r *common.Record) {
+			r.Type = common.UnspecifiedRecord
+		}, http.StatusBadRequest, "record_type"},
+		{"cleartext name", func(r *common.Record) {
+			r.Name = "rec1"
+		}, http.StatusBadRequest, "name"},
+		{"name too long", func(r *common.Record) {
+			r.Name = testCipherText(strings.Repeat("n", DefaultMaxNameSize))
+		}, http.StatusBadRequest, "name"},
+		{"short opaque", func(r *common.Record) {
+			r.Opaque = "020000"
+		}, http.StatusBadRequest, "opaque"},
+		{"unknown format", func(r *common.Record) {
+			r.Meta = "09" + r.Meta[2:]
+		}, http.StatusBadRequest, "meta"},
+		{"no data key", func(r *common.Record) {
+			r.DataKey = ""
+		}, http.StatusBadRequest, "data_key"},
+		{"cleartext tag", func(r *common.Record) {
+			r.Tags = []string{testCipherText("work"), "home"}
+		}, http.StatusBadRequest, "tags"},
+		{"payload too large", func(r *common.Record) {
+			r.Opaque = testCipherText(strings.Repeat("0", DefaultMaxPayloadSize))
+		}, http.StatusRequestEntityTooLarge, "opaque"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := valid
+			tt.change(&record)
+			body, err := json.Marshal(record)
+			require.NoError(t, err)
+			code, e := postTestRecord(t, router, string(body))
+			assert.Equal(t, tt.code, code)
+			assert.Equal(t, tt.field, e.Field)
+		})
+	}
+}
+
+func TestSetLimits(t *testing.T) {
+	router := prepareTest(t)
+	t.Cleanup(func() { limits = defaultLimits() })
+
+	err := SetLimits(Limits{RecordTypes: []common.RecordType{"blob"}})
+	assert.ErrorIs(t, err, common.ErrUnknownType)
+
+	err = SetLimits(Limits{
+		RecordTypes:    []common.RecordType{common.NoteRecord},
+		MaxBodySize:    1 << 10,
+		MaxPayloadSize: map[common.RecordType]int{common.NoteRecord: 200},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultMaxNameSize, limits.MaxNameSize)
+	assert.Equal(t,
+		defaultPayloadSizes[common.BinaryRecord],
+		limits.payloadSize(common.BinaryRecord),
+	)
+
+	body, _ := json.Marshal(testRecord("rec1", "0000", common.NoteRecord))
+	code, _ := postTestRecord(t, router, string(body))
+	assert.Equal(t, http.StatusOK, code)
+
+	t.Run("Type not accepted", func(t *testing.T) {
+		body, _ := json.Marshal(testRecord("rec1", "0000", common.AccountRecord))
+		code, e := postTestRecord(t, router, string(body))
+		assert.Equal(t, http.StatusBadRequest, code)
+		assert.Equal(t, "record_type", e.Field)
+	})
+
+	t.Run("Payload of the type too large", func(t *testing.T) {
+		body, _ := json.Marshal(testRecord("rec1",
+			strings.Repeat("0", 100),
+			common.NoteRecord,
+		))
+		code, e := postTestRecord(t, router, string(body))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, code)
+		assert.Equal(t, "opaque", e.Field)
+	})
+
+	t.Run("Body too large", func(t *testing.T) {
+		body, _ := json.Marshal(testRecord(strings.Repeat("n", 1<<10),
+			"0000",
+			common.NoteRecord,
+		))
+		code, e := postTestRecord(t, router, string(body))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, code)
+		assert.Equal(t, common.CodeTooLarge, e.Code)
+	})
+}
+
+func TestDefaultMaxFileSize(t *testing.T) {
+	router := prepareTest(t)
+	key := crypt.MakeKey("qwerty")
+	binding := crypt.Binding{Owner: crypt.RecordOwner(testUser, "")}
+
+	// the file is encrypted as the client does: base64 encoded
+	// and padded to the power of two
+	postFile := func(size int) (int, common.ErrorResponse) {
+		record, err := crypt.EncryptRecord(key, binding, common.Record{
+			Name:   "file",
+			Type:   common.BinaryRecord,
+			Opaque: base64.StdEncoding.EncodeToString(make([]byte, size)),
+			Meta:   "file stored",
+		})
+		require.NoError(t, err)
+		body, err := json.Marshal(record)
+		require.NoError(t, err)
+		return postTestRecord(t, router, string(body))
+	}
+
+	code, _ := postFile(DefaultMaxFileSize - 1)
+	assert.Equal(t, http.StatusOK, code)
+
+	code, e := postFile(2 * DefaultMaxFileSize)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, code)
+	assert.Equal(t, common.CodeTooLarge, e.Code)
+}
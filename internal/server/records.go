@@ -2,8 +2,8 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net/http"
 	"strconv"
@@ -13,19 +13,60 @@ import (
 	"github.com/go-chi/chi/v5"
 )
 
+// queryRecords writes the page of the records listing if the listing
+// query parameters are set, it reports if the request is served.
+// The listing is limited to the type given if it is not empty.
+func queryRecords(w http.ResponseWriter,
+	r *http.Request,
+	t common.RecordType,
+	list func(common.RecordQuery) (common.RecordPage, error),
+) bool {
+	if !common.IsRecordQuery(r.URL.Query()) {
+		return false
+	}
+	q, err := common.ParseRecordQuery(r.URL.Query())
+	if err == nil {
+		if t != "" {
+			q.Types = []common.RecordType{t}
+		}
+		var page common.RecordPage
+		page, err = list(q)
+		if err == nil {
+			writeJSON(w, page)
+			return true
+		}
+	}
+	if errors.Is(err, common.ErrBadQuery) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return true
+	}
+	log.Print(err)
+	writeError(w,
+		http.StatusInternalServerError,
+		"Internal Server Error",
+	)
+	return true
+}
+
 func listRecords(w http.ResponseWriter, r *http.Request) {
 	log.Print("listRecords")
 
 	user, _, ok := r.BasicAuth()
 	if !ok {
-		writeStatus(w, http.StatusBadRequest, "no basic auth")
+		writeError(w, http.StatusBadRequest, "no basic auth")
+		return
+	}
+
+	if queryRecords(w, r, "", func(q common.RecordQuery) (common.RecordPage, error) {
+		return serverStore.QueryRecords(user, q)
+	}) {
 		return
 	}
 
 	records, err := serverStore.ListRecords(user)
 	if err != nil {
 		log.Print(err)
-		writeStatus(w,
+		writeError(w,
 			http.StatusInternalServerError,
 			"Internal Server Error",
 		)
@@ -34,7 +75,7 @@ func listRecords(w http.ResponseWriter, r *http.Request) {
 	err = json.NewEncoder(w).Encode(records)
 	if err != nil {
 		log.Print(err)
-		writeStatus(w,
+		writeError(w,
 			http.StatusInternalServerError,
 			"Internal Server Error",
 		)
@@ -48,14 +89,20 @@ func listRecordsByType(w http.ResponseWriter, r *http.Request) {
 
 	user, _, ok := r.BasicAuth()
 	if !ok {
-		writeStatus(w, http.StatusBadRequest, "no basic auth")
+		writeError(w, http.StatusBadRequest, "no basic auth")
+		return
+	}
+
+	if queryRecords(w, r, recordType, func(q common.RecordQuery) (common.RecordPage, error) {
+		return serverStore.QueryRecords(user, q)
+	}) {
 		return
 	}
 
 	records, err := serverStore.ListRecordsByType(user, recordType)
 	if err != nil {
 		log.Print(err)
-		writeStatus(w,
+		writeError(w,
 			http.StatusInternalServerError,
 			"Internal Server Error",
 		)
@@ -64,7 +111,7 @@ func listRecordsByType(w http.ResponseWriter, r *http.Request) {
 	err = json.NewEncoder(w).Encode(records)
 	if err != nil {
 		log.Print(err)
-		writeStatus(w,
+		writeError(w,
 			http.StatusInternalServerError,
 			"Internal Server Error",
 		)
@@ -77,13 +124,13 @@ func getRecordByID(w http.ResponseWriter, r *http.Request) {
 
 	user, _, ok := r.BasicAuth()
 	if !ok {
-		writeStatus(w, http.StatusBadRequest, "no basic auth")
+		writeError(w, http.StatusBadRequest, "no basic auth")
 		return
 	}
 
 	id, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
-		writeStatus(w, http.StatusBadRequest, "cannot parse 'id' param")
+		writeError(w, http.StatusBadRequest, "cannot parse 'id' param")
 		return
 	}
 
@@ -91,12 +138,12 @@ func getRecordByID(w http.ResponseWriter, r *http.Request) {
 	if err == store.ErrNotFound {
 		msg := fmt.Sprintf("Record id %d not found", id)
 		log.Print(msg)
-		writeStatus(w, http.StatusNotFound, msg)
+		writeError(w, http.StatusNotFound, msg)
 		return
 	}
 	if err != nil {
 		log.Print(err)
-		writeStatus(w,
+		writeError(w,
 			http.StatusInternalServerError,
 			"Internal Server Error",
 		)
@@ -106,7 +153,7 @@ func getRecordByID(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		log.Print(err)
-		writeStatus(w,
+		writeError(w,
 			http.StatusInternalServerError,
 			"Internal Server Error",
 		)
@@ -119,7 +166,7 @@ func getRecordID(w http.ResponseWriter, r *http.Request) {
 
 	user, _, ok := r.BasicAuth()
 	if !ok {
-		writeStatus(w, http.StatusBadRequest, "no basic auth")
+		writeError(w, http.StatusBadRequest, "no basic auth")
 		return
 	}
 
@@ -133,12 +180,12 @@ func getRecordID(w http.ResponseWriter, r *http.Request) {
 		msg := fmt.Sprintf("Record %s of type %s not found",
 			recordName, recordType)
 		log.Print(msg)
-		writeStatus(w, http.StatusNotFound, msg)
+		writeError(w, http.StatusNotFound, msg)
 		return
 	}
 	if err != nil {
 		log.Print(err)
-		writeStatus(w,
+		writeError(w,
 			http.StatusInternalServerError,
 			"Internal Server Error",
 		)
@@ -148,7 +195,7 @@ func getRecordID(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		log.Print(err)
-		writeStatus(w,
+		writeError(w,
 			http.StatusInternalServerError,
 			"Internal Server Error",
 		)
@@ -161,7 +208,7 @@ func getRecordByTypeName(w http.ResponseWriter, r *http.Request) {
 
 	user, _, ok := r.BasicAuth()
 	if !ok {
-		writeStatus(w, http.StatusBadRequest, "no basic auth")
+		writeError(w, http.StatusBadRequest, "no basic auth")
 		return
 	}
 
@@ -173,12 +220,12 @@ func getRecordByTypeName(w http.ResponseWriter, r *http.Request) {
 		msg := fmt.Sprintf("Record %s of type %s not found",
 			recordName, recordType)
 		log.Print(msg)
-		writeStatus(w, http.StatusNotFound, msg)
+		writeError(w, http.StatusNotFound, msg)
 		return
 	}
 	if err != nil {
 		log.Print(err)
-		writeStatus(w,
+		writeError(w,
 			http.StatusInternalServerError,
 			"Internal Server Error",
 		)
@@ -188,7 +235,7 @@ func getRecordByTypeName(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		log.Print(err)
-		writeStatus(w,
+		writeError(w,
 			http.StatusInternalServerError,
 			"Internal Server Error",
 		)
@@ -201,13 +248,13 @@ func deleteRecordByID(w http.ResponseWriter, r *http.Request) {
 
 	user, _, ok := r.BasicAuth()
 	if !ok {
-		writeStatus(w, http.StatusBadRequest, "no basic auth")
+		writeError(w, http.StatusBadRequest, "no basic auth")
 		return
 	}
 
 	id, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
-		writeStatus(w, http.StatusBadRequest, "cannot parse 'id' param")
+		writeError(w, http.StatusBadRequest, "cannot parse 'id' param")
 		return
 	}
 
@@ -215,19 +262,19 @@ func deleteRecordByID(w http.ResponseWriter, r *http.Request) {
 	if err == store.ErrNotFound {
 		msg := fmt.Sprintf("Record id %d not found", id)
 		log.Print(msg)
-		writeStatus(w, http.StatusNotFound, msg)
+		writeError(w, http.StatusNotFound, msg)
 		return
 	}
 	if err != nil {
 		log.Print(err)
-		writeStatus(w,
+		writeError(w,
 			http.StatusInternalServerError,
 			"Internal Server Error",
 		)
 		return
 	}
 
-	writeStatus(w, http.StatusOK, "OK")
+	writeStatus(w, "OK")
 }
 
 func deleteRecordByTypeName(w http.ResponseWriter, r *http.Request) {
@@ -235,7 +282,7 @@ func deleteRecordByTypeName(w http.ResponseWriter, r *http.Request) {
 
 	user, _, ok := r.BasicAuth()
 	if !ok {
-		writeStatus(w, http.StatusBadRequest, "no basic auth")
+		writeError(w, http.StatusBadRequest, "no basic auth")
 		return
 	}
 
@@ -247,19 +294,39 @@ func deleteRecordByTypeName(w http.ResponseWriter, r *http.Request) {
 		msg := fmt.Sprintf("Record %s of type %s not found",
 			recordName, recordType)
 		log.Print(msg)
-		writeStatus(w, http.StatusNotFound, msg)
+		writeError(w, http.StatusNotFound, msg)
 		return
 	}
 	if err != nil {
 		log.Print(err)
-		writeStatus(w,
+		writeError(w,
 			http.StatusInternalServerError,
 			"Internal Server Error",
 		)
 		return
 	}
 
-	writeStatus(w, http.StatusOK, "OK")
+	writeStatus(w, "OK")
+}
+
+// writeStoreError writes the error response of the record
+// not stored or updated
+func writeStoreError(w http.ResponseWriter, action string, err error) {
+	switch {
+	case errors.Is(err, store.ErrAlreadyExists):
+		writeErrorCode(w,
+			http.StatusConflict,
+			common.CodeAlreadyExists,
+			"Record Already Exists",
+		)
+	case errors.Is(err, store.ErrNotFound):
+		writeError(w, http.StatusNotFound, "Record Not Found")
+	default:
+		writeError(w,
+			http.StatusInternalServerError,
+			fmt.Sprintf("Cannot %s Record: %v", action, err),
+		)
+	}
 }
 
 func storeRecord(w http.ResponseWriter, r *http.Request) {
@@ -267,17 +334,12 @@ func storeRecord(w http.ResponseWriter, r *http.Request) {
 
 	user, _, ok := r.BasicAuth()
 	if !ok {
-		writeStatus(w, http.StatusBadRequest, "no basic auth")
+		writeError(w, http.StatusBadRequest, "no basic auth")
 		return
 	}
 
-	body, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		log.Print(err)
-		writeStatus(w,
-			http.StatusInternalServerError,
-			"Internal Server Error",
-		)
+	body, ok := readBody(w, r)
+	if !ok {
 		return
 	}
 
@@ -285,30 +347,29 @@ func storeRecord(w http.ResponseWriter, r *http.Request) {
 	resp.Status = "OK"
 
 	var record common.Record
-	err = json.Unmarshal(body, &record)
+	err := json.Unmarshal(body, &record)
 	if err != nil {
-		writeStatus(w,
+		writeError(w,
 			http.StatusBadRequest,
 			fmt.Sprintf("Cannot Parse Body: %v", err),
 		)
 		return
 	}
+	if !checkRecord(w, record) {
+		return
+	}
 	resp.Name = record.Name
 	resp.ID, err = serverStore.StoreRecord(user, record)
 
 	if err != nil {
 		log.Printf("storeRecord() error: %v", err)
-		resp.Status = "error"
-		writeStatus(w,
-			http.StatusInternalServerError,
-			fmt.Sprintf("Cannot Store Record: %v", err),
-		)
+		writeStoreError(w, "Store", err)
 		return
 	}
 
 	err = json.NewEncoder(w).Encode(resp)
 	if err != nil {
-		writeStatus(w,
+		writeError(w,
 			http.StatusInternalServerError,
 			"Internal Server Error",
 		)
@@ -321,23 +382,18 @@ func updateRecordByID(w http.ResponseWriter, r *http.Request) {
 
 	user, _, ok := r.BasicAuth()
 	if !ok {
-		writeStatus(w, http.StatusBadRequest, "no basic auth")
+		writeError(w, http.StatusBadRequest, "no basic auth")
 		return
 	}
 
 	id, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
-		writeStatus(w, http.StatusBadRequest, "cannot parse 'id' param")
+		writeError(w, http.StatusBadRequest, "cannot parse 'id' param")
 		return
 	}
 
-	body, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		log.Print(err)
-		writeStatus(w,
-			http.StatusInternalServerError,
-			"Internal Server Error",
-		)
+	body, ok := readBody(w, r)
+	if !ok {
 		return
 	}
 
@@ -346,28 +402,27 @@ func updateRecordByID(w http.ResponseWriter, r *http.Request) {
 	var record common.Record
 	err = json.Unmarshal(body, &record)
 	if err != nil {
-		writeStatus(w,
+		writeError(w,
 			http.StatusBadRequest,
 			fmt.Sprintf("Cannot Parse Body: %v", err),
 		)
 		return
 	}
+	if !checkRecord(w, record) {
+		return
+	}
 	resp.Name = record.Name
 	err = serverStore.UpdateRecordByID(user, int64(id), record)
 
 	if err != nil {
 		log.Printf("update record error: %v", err)
-		resp.Status = "error"
-		writeStatus(w,
-			http.StatusInternalServerError,
-			fmt.Sprintf("Cannot Update Record: %v", err),
-		)
+		writeStoreError(w, "Update", err)
 		return
 	}
 
 	err = json.NewEncoder(w).Encode(resp)
 	if err != nil {
-		writeStatus(w,
+		writeError(w,
 			http.StatusInternalServerError,
 			"Internal Server Error",
 		)
@@ -380,50 +435,44 @@ func updateRecordByTypeName(w http.ResponseWriter, r *http.Request) {
 
 	user, _, ok := r.BasicAuth()
 	if !ok {
-		writeStatus(w, http.StatusBadRequest, "no basic auth")
+		writeError(w, http.StatusBadRequest, "no basic auth")
 		return
 	}
 
 	recordType := common.RecordType(chi.URLParam(r, "record_type"))
 	recordName := chi.URLParam(r, "record_name")
 
-	body, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		log.Print(err)
-		writeStatus(w,
-			http.StatusInternalServerError,
-			"Internal Server Error",
-		)
+	body, ok := readBody(w, r)
+	if !ok {
 		return
 	}
 
 	var resp common.StoreRecordResponse
 	resp.Status = "OK"
 	var record common.Record
-	err = json.Unmarshal(body, &record)
+	err := json.Unmarshal(body, &record)
 	if err != nil {
-		writeStatus(w,
+		writeError(w,
 			http.StatusBadRequest,
 			fmt.Sprintf("Cannot Parse Body: %v", err),
 		)
 		return
 	}
+	if !checkRecord(w, record) {
+		return
+	}
 	resp.Name = record.Name
 	err = serverStore.UpdateRecordByTypeName(user, recordType, recordName, record)
 
 	if err != nil {
 		log.Printf("update record error: %v", err)
-		resp.Status = "error"
-		writeStatus(w,
-			http.StatusInternalServerError,
-			fmt.Sprintf("Cannot Update Record: %v", err),
-		)
+		writeStoreError(w, "Update", err)
 		return
 	}
 
 	err = json.NewEncoder(w).Encode(resp)
 	if err != nil {
-		writeStatus(w,
+		writeError(w,
 			http.StatusInternalServerError,
 			"Internal Server Error",
 		)
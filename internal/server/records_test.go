@@ -1,6 +1,7 @@
 package server
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -41,6 +42,25 @@ func prepareTest(t *testing.T) http.Handler {
 	return router
 }
 
+// testCipherText returns the well-formed envelope of the text,
+// the text is kept readable in the ciphertext
+func testCipherText(text string) string {
+	buf := append(make([]byte, 1+12), text...)
+	buf[0] = 2
+	return hex.EncodeToString(append(buf, make([]byte, 16)...))
+}
+
+// testRecord returns the record with the fields sealed as the client does
+func testRecord(name, opaque string, t common.RecordType) common.Record {
+	return common.Record{
+		Name:    testCipherText(name),
+		Opaque:  testCipherText(opaque),
+		Meta:    testCipherText(""),
+		DataKey: testCipherText("key"),
+		Type:    t,
+	}
+}
+
 func storeTestRecord(t *testing.T,
 	router http.Handler,
 	record common.Record,
@@ -66,28 +86,68 @@ func storeTestRecord(t *testing.T,
 func Test_Record(t *testing.T) {
 	t.Run("Store record", func(t *testing.T) {
 		router := prepareTest(t)
-		record := common.Record{
-			Name:   "rec1",
-			Opaque: "0000",
-			Type:   common.NoteRecord,
+		record := testRecord("rec1", "0000", common.NoteRecord)
+		_ = storeTestRecord(t, router, record)
+	})
+
+	t.Run("Store record of unknown type", func(t *testing.T) {
+		router := prepareTest(t)
+		id := storeTestRecord(t, router, testRecord("rec1", "0000", common.NoteRecord))
+		for _, recordType := range []common.RecordType{common.UnspecifiedRecord, "blob"} {
+			body, err := json.Marshal(testRecord("rec2", "0000", recordType))
+			assert.NoError(t, err)
+
+			resp, _ := testHTTPRequest(t,
+				router,
+				http.MethodPost,
+				"/records",
+				string(body),
+				testUser,
+				testPass,
+			)
+			resp.Body.Close()
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+			resp, _ = testHTTPRequest(t,
+				router,
+				http.MethodPut,
+				fmt.Sprintf("/records/%d", id),
+				string(body),
+				testUser,
+				testPass,
+			)
+			resp.Body.Close()
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
 		}
+	})
+
+	t.Run("Store record twice", func(t *testing.T) {
+		router := prepareTest(t)
+		record := testRecord("rec1", "0000", common.NoteRecord)
 		_ = storeTestRecord(t, router, record)
+
+		body, _ := json.Marshal(record)
+		resp, respBody := testHTTPRequest(t,
+			router,
+			http.MethodPost,
+			"/records",
+			string(body),
+			testUser,
+			testPass,
+		)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+		var e common.ErrorResponse
+		assert.NoError(t, json.Unmarshal([]byte(respBody), &e))
+		assert.Equal(t, common.CodeAlreadyExists, e.Code)
 	})
 
 	t.Run("Update record by ID", func(t *testing.T) {
 		router := prepareTest(t)
-		record := common.Record{
-			Name:   "rec1",
-			Opaque: "0000",
-			Type:   common.NoteRecord,
-		}
+		record := testRecord("rec1", "0000", common.NoteRecord)
 		id := storeTestRecord(t, router, record)
 
-		updateRecord := common.Record{
-			Name:   "rec1",
-			Opaque: "1111",
-			Type:   common.NoteRecord,
-		}
+		updateRecord := testRecord("rec1", "1111", common.NoteRecord)
 		updateRecordBody, err := json.Marshal(updateRecord)
 		assert.NoError(t, err)
 
@@ -121,11 +181,7 @@ func Test_Record(t *testing.T) {
 
 	t.Run("Get record by ID", func(t *testing.T) {
 		router := prepareTest(t)
-		record := common.Record{
-			Name:   "rec1",
-			Opaque: "0000",
-			Type:   common.NoteRecord,
-		}
+		record := testRecord("rec1", "0000", common.NoteRecord)
 		id := storeTestRecord(t, router, record)
 		getResp, getRespBody := testHTTPRequest(t,
 			router,
@@ -148,16 +204,12 @@ func Test_Record(t *testing.T) {
 		router := prepareTest(t)
 		recName := "rec1"
 		recType := common.NoteRecord
-		record := common.Record{
-			Name:   recName,
-			Opaque: "0000",
-			Type:   recType,
-		}
+		record := testRecord(recName, "0000", recType)
 		id := storeTestRecord(t, router, record)
 		getResp, getRespBody := testHTTPRequest(t,
 			router,
 			http.MethodGet,
-			fmt.Sprintf("/records/%s/%s", recType, recName),
+			fmt.Sprintf("/records/%s/%s", recType, record.Name),
 			"",
 			testUser,
 			testPass,
@@ -173,17 +225,9 @@ func Test_Record(t *testing.T) {
 
 	t.Run("List records", func(t *testing.T) {
 		router := prepareTest(t)
-		record1 := common.Record{
-			Name:   "rec1",
-			Opaque: "1111",
-			Type:   common.NoteRecord,
-		}
+		record1 := testRecord("rec1", "1111", common.NoteRecord)
 		id1 := storeTestRecord(t, router, record1)
-		record2 := common.Record{
-			Name:   "rec2",
-			Opaque: "2222",
-			Type:   common.NoteRecord,
-		}
+		record2 := testRecord("rec2", "2222", common.NoteRecord)
 		id2 := storeTestRecord(t, router, record2)
 
 		listResp, listRespBody := testHTTPRequest(t,
@@ -201,19 +245,17 @@ func Test_Record(t *testing.T) {
 
 		expected1 := record1
 		expected1.Opaque = ""
+		expected1.Meta = ""
 		expected2 := record2
 		expected2.Opaque = ""
+		expected2.Meta = ""
 		assert.Equal(t, expected1, listRecords[id1])
 		assert.Equal(t, expected2, listRecords[id2])
 	})
 
 	t.Run("Delete record by ID", func(t *testing.T) {
 		router := prepareTest(t)
-		record := common.Record{
-			Name:   "rec1",
-			Opaque: "0000",
-			Type:   common.NoteRecord,
-		}
+		record := testRecord("rec1", "0000", common.NoteRecord)
 		id := storeTestRecord(t, router, record)
 
 		delResp, _ := testHTTPRequest(t,
@@ -239,3 +281,82 @@ func Test_Record(t *testing.T) {
 		assert.Equal(t, http.StatusNotFound, delResp2.StatusCode)
 	})
 }
+
+func Test_queryRecords(t *testing.T) {
+	router := prepareTest(t)
+	for _, record := range []common.Record{
+		testRecord("b", "0000", common.NoteRecord),
+		testRecord("a", "1111", common.NoteRecord),
+		testRecord("c", "2222", common.AccountRecord),
+	} {
+		_ = storeTestRecord(t, router, record)
+	}
+
+	t.Run("List without query", func(t *testing.T) {
+		resp, body := testHTTPRequest(t,
+			router,
+			http.MethodGet,
+			"/records",
+			"",
+			testUser,
+			testPass,
+		)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		var records common.Records
+		assert.NoError(t, json.Unmarshal([]byte(body), &records))
+		assert.Len(t, records, 3)
+	})
+
+	t.Run("List pages", func(t *testing.T) {
+		resp, body := testHTTPRequest(t,
+			router,
+			http.MethodGet,
+			"/records/by_type/note?sort=-id&limit=1",
+			"",
+			testUser,
+			testPass,
+		)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		var page common.RecordPage
+		assert.NoError(t, json.Unmarshal([]byte(body), &page))
+		assert.Len(t, page.Records, 1)
+		assert.Equal(t, testCipherText("a"), page.Records[0].Name)
+		assert.Empty(t, page.Records[0].Opaque)
+		assert.NotEmpty(t, page.Next)
+
+		resp, body = testHTTPRequest(t,
+			router,
+			http.MethodGet,
+			"/records/by_type/note?sort=-id&limit=1&cursor="+page.Next,
+			"",
+			testUser,
+			testPass,
+		)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		page = common.RecordPage{}
+		assert.NoError(t, json.Unmarshal([]byte(body), &page))
+		assert.Len(t, page.Records, 1)
+		assert.Equal(t, testCipherText("b"), page.Records[0].Name)
+		assert.Empty(t, page.Next)
+	})
+
+	t.Run("Bad query", func(t *testing.T) {
+		for _, query := range []string{"sort=size", "limit=-1",
+			"modified_since=yesterday", "cursor=bad", "sort=name",
+			"name_prefix=a"} {
+			resp, _ := testHTTPRequest(t,
+				router,
+				http.MethodGet,
+				"/records?"+query,
+				"",
+				testUser,
+				testPass,
+			)
+			resp.Body.Close()
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, query)
+		}
+	})
+}
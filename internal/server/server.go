@@ -1,6 +1,7 @@
 package server
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
@@ -8,6 +9,7 @@ import (
 	"os/signal"
 	"syscall"
 
+	"github.com/alexey-mavrin/graduate-2/internal/common"
 	"github.com/alexey-mavrin/graduate-2/internal/store"
 	"github.com/go-chi/chi/v5"
 )
@@ -19,9 +21,53 @@ const (
 	registerPath = "/users"
 )
 
-func writeStatus(w http.ResponseWriter, code int, status string) {
-	w.WriteHeader(code)
-	w.Write([]byte(`{"Status":"` + status + `"}`))
+// writeStatus writes the status of the request succeeded
+func writeStatus(w http.ResponseWriter, status string) {
+	writeJSON(w, common.StatusResponse{Status: status})
+}
+
+// writeError writes the error response, the error code
+// is derived from the HTTP status
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeErrorCode(w, status, common.StatusErrorCode(status), message)
+}
+
+// writeErrorCode writes the error response with the error code given
+func writeErrorCode(w http.ResponseWriter,
+	status int,
+	code common.ErrorCode,
+	message string,
+) {
+	writeErrorResponse(w, status, common.ErrorResponse{
+		Code:    code,
+		Message: message,
+	})
+}
+
+func writeErrorResponse(w http.ResponseWriter,
+	status int,
+	e common.ErrorResponse,
+) {
+	e.Status = common.StatusError
+	buf, err := json.Marshal(e)
+	if err != nil {
+		log.Print(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(status)
+	w.Write(append(buf, '\n'))
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Print(err)
+		writeError(w,
+			http.StatusInternalServerError,
+			"Internal Server Error",
+		)
+	}
 }
 
 func checkSetContentType(next http.Handler) http.Handler {
@@ -31,7 +77,7 @@ func checkSetContentType(next http.Handler) http.Handler {
 		cType := r.Header.Get("Content-Type")
 		if cType != "application/json" {
 			log.Print("checkContentType: bad content type " + cType)
-			writeStatus(w, http.StatusBadRequest, "Bad Content Type")
+			writeError(w, http.StatusBadRequest, "Bad Content Type")
 			return
 		}
 		next.ServeHTTP(w, r)
@@ -123,10 +169,13 @@ func NewRouter() chi.Router {
 	r := chi.NewRouter()
 
 	r.Use(checkSetContentType)
+	r.Use(limitBody)
 	r.Use(authUser)
 
 	r.Post("/users", createUser)
 	r.Put("/password", changePassword)
+	r.Put("/public_key", setPublicKey)
+	r.Get("/users/{user}/public_key", getPublicKey)
 	r.Get("/ping", pingHandler)
 	r.Post("/records", storeRecord)
 	r.Get("/records", listRecords)
@@ -136,5 +185,22 @@ func NewRouter() chi.Router {
 	r.Put("/records/{id}", updateRecordByID)
 	r.Delete("/records/{id}", deleteRecordByID)
 
+	r.Post("/vaults", createVault)
+	r.Get("/vaults", listVaults)
+	r.Delete("/vaults/{vault}", deleteVault)
+	r.Get("/vaults/{vault}/members", listVaultMembers)
+	r.Post("/vaults/{vault}/members", addVaultMember)
+	r.Put("/vaults/{vault}/members/{member}", setVaultMemberKey)
+	r.Delete("/vaults/{vault}/members/{member}", removeVaultMember)
+	r.Get("/vaults/{vault}/key", getVaultKey)
+	r.Put("/vaults/{vault}/key", rotateVaultKey)
+	r.Post("/vaults/{vault}/records", storeVaultRecord)
+	r.Get("/vaults/{vault}/records", listVaultRecords)
+	r.Get("/vaults/{vault}/records/by_type/{record_type}", listVaultRecordsByType)
+	r.Get("/vaults/{vault}/records/{id}", getVaultRecordByID)
+	r.Get("/vaults/{vault}/records/{record_type}/{record_name}", getVaultRecordID)
+	r.Put("/vaults/{vault}/records/{id}", updateVaultRecordByID)
+	r.Delete("/vaults/{vault}/records/{id}", deleteVaultRecordByID)
+
 	return r
 }
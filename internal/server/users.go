@@ -4,31 +4,26 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net/http"
 
 	"github.com/alexey-mavrin/graduate-2/internal/common"
 	"github.com/alexey-mavrin/graduate-2/internal/store"
+	"github.com/go-chi/chi/v5"
 )
 
 func createUser(w http.ResponseWriter, r *http.Request) {
 	log.Print("createUser")
 
-	body, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		log.Print(err)
-		writeStatus(w,
-			http.StatusInternalServerError,
-			"Internal Server Error",
-		)
+	body, ok := readBody(w, r)
+	if !ok {
 		return
 	}
 
 	var user common.User
-	err = json.Unmarshal(body, &user)
+	err := json.Unmarshal(body, &user)
 	if err != nil {
-		writeStatus(w,
+		writeError(w,
 			http.StatusBadRequest,
 			fmt.Sprintf("Cannot Parse Body: %v", err),
 		)
@@ -42,15 +37,15 @@ func createUser(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		log.Printf("AddUser() error: %v", err)
 		if errors.Is(err, store.ErrAlreadyExists) {
-			resp.Status = "already exists"
-			writeStatus(w,
-				http.StatusBadRequest,
+			writeErrorCode(w,
+				http.StatusConflict,
+				common.CodeAlreadyExists,
 				"User Already Exists",
 			)
 			return
 		}
 		resp.Status = "error"
-		writeStatus(w,
+		writeError(w,
 			http.StatusBadRequest,
 			"Cannot Add User",
 		)
@@ -60,7 +55,7 @@ func createUser(w http.ResponseWriter, r *http.Request) {
 	err = json.NewEncoder(w).Encode(resp)
 	if err != nil {
 		log.Printf("cannot encode AddUserResponse: %v", err)
-		writeStatus(w,
+		writeError(w,
 			http.StatusInternalServerError,
 			"Internal Server Error",
 		)
@@ -73,22 +68,17 @@ func changePassword(w http.ResponseWriter, r *http.Request) {
 
 	user, _, ok := r.BasicAuth()
 	if !ok {
-		writeStatus(w, http.StatusBadRequest, "no basic auth")
+		writeError(w, http.StatusBadRequest, "no basic auth")
 		return
 	}
 
-	body, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		log.Print(err)
-		writeStatus(w,
-			http.StatusInternalServerError,
-			"Internal Server Error",
-		)
+	body, ok := readBody(w, r)
+	if !ok {
 		return
 	}
 
 	var userInfo common.User
-	err = json.Unmarshal(body, &userInfo)
+	err := json.Unmarshal(body, &userInfo)
 
 	var resp common.AddUserResponse
 	resp.Name = user
@@ -96,7 +86,7 @@ func changePassword(w http.ResponseWriter, r *http.Request) {
 	err = serverStore.ChangeUserPassword(user, userInfo.Password)
 	if err != nil {
 		log.Printf("cannot change user password: %v", err)
-		writeStatus(w,
+		writeError(w,
 			http.StatusInternalServerError,
 			"Internal Server Error",
 		)
@@ -105,10 +95,64 @@ func changePassword(w http.ResponseWriter, r *http.Request) {
 	err = json.NewEncoder(w).Encode(resp)
 	if err != nil {
 		log.Printf("cannot encode AddUserResponse: %v", err)
-		writeStatus(w,
+		writeError(w,
+			http.StatusInternalServerError,
+			"Internal Server Error",
+		)
+		return
+	}
+}
+
+func setPublicKey(w http.ResponseWriter, r *http.Request) {
+	log.Print("setPublicKey")
+	user, _, ok := r.BasicAuth()
+	if !ok {
+		writeError(w, http.StatusBadRequest, "no basic auth")
+		return
+	}
+	body, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+	var userInfo common.User
+	err := json.Unmarshal(body, &userInfo)
+	if err != nil || userInfo.PublicKey == "" {
+		writeError(w, http.StatusBadRequest, "Cannot Parse Public Key")
+		return
+	}
+	err = serverStore.SetUserPublicKey(user, userInfo.PublicKey)
+	if err != nil {
+		log.Printf("cannot set user public key: %v", err)
+		writeError(w,
+			http.StatusInternalServerError,
+			"Internal Server Error",
+		)
+		return
+	}
+	writeStatus(w, "OK")
+}
+
+func getPublicKey(w http.ResponseWriter, r *http.Request) {
+	log.Print("getPublicKey")
+	user := chi.URLParam(r, "user")
+	publicKey, err := serverStore.GetUserPublicKey(user)
+	if err == store.ErrNotFound {
+		writeError(w,
+			http.StatusNotFound,
+			fmt.Sprintf("Public key of %s not found", user),
+		)
+		return
+	}
+	if err != nil {
+		log.Print(err)
+		writeError(w,
 			http.StatusInternalServerError,
 			"Internal Server Error",
 		)
 		return
 	}
+	writeJSON(w, common.User{
+		Name:      user,
+		PublicKey: publicKey,
+	})
 }
@@ -42,10 +42,10 @@ func Test_createUser(t *testing.T) {
 			name:   "Create the same user twice",
 			method: http.MethodPost,
 			want: want{
-				code: http.StatusBadRequest,
+				code: http.StatusConflict,
 				respData: common.AddUserResponse{
 					Name:   "",
-					Status: "User Already Exists",
+					Status: common.StatusError,
 					ID:     0,
 				},
 			},
@@ -120,5 +120,5 @@ func Test_pingHandler(t *testing.T) {
 		"passX",
 	)
 	defer resp2.Body.Close()
-	assert.Equal(t, http.StatusForbidden, resp2.StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, resp2.StatusCode)
 }
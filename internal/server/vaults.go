@@ -0,0 +1,624 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"github.com/alexey-mavrin/graduate-2/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+// vaultAccess checks that the request user is the member of the vault
+// from the request path and has at least the required role.
+// It writes the error status and returns false otherwise.
+func vaultAccess(w http.ResponseWriter,
+	r *http.Request,
+	required common.VaultRole,
+) (string, string, common.VaultRole, bool) {
+	vault := chi.URLParam(r, "vault")
+	user, _, ok := r.BasicAuth()
+	if !ok {
+		writeError(w, http.StatusBadRequest, "no basic auth")
+		return user, vault, "", false
+	}
+	role, err := serverStore.GetVaultRole(user, vault)
+	if err == store.ErrNotFound {
+		// do not reveal the existence of the vault to non-members
+		msg := fmt.Sprintf("Vault %s not found", vault)
+		log.Print(msg)
+		writeError(w, http.StatusNotFound, msg)
+		return user, vault, role, false
+	}
+	if err != nil {
+		log.Print(err)
+		writeError(w,
+			http.StatusInternalServerError,
+			"Internal Server Error",
+		)
+		return user, vault, role, false
+	}
+	if !role.Allows(required) {
+		msg := fmt.Sprintf("Vault %s: %s role required", vault, required)
+		log.Print(msg)
+		writeError(w, http.StatusForbidden, msg)
+		return user, vault, role, false
+	}
+	return user, vault, role, true
+}
+
+func createVault(w http.ResponseWriter, r *http.Request) {
+	log.Print("createVault")
+	user, _, ok := r.BasicAuth()
+	if !ok {
+		writeError(w, http.StatusBadRequest, "no basic auth")
+		return
+	}
+	body, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+	var vault common.Vault
+	err := json.Unmarshal(body, &vault)
+	if err != nil {
+		writeError(w,
+			http.StatusBadRequest,
+			fmt.Sprintf("Cannot Parse Body: %v", err),
+		)
+		return
+	}
+	var resp common.StoreRecordResponse
+	resp.Name = vault.Name
+	resp.Status = "OK"
+	resp.ID, err = serverStore.CreateVault(user, vault.Name, vault.Key)
+	if errors.Is(err, store.ErrAlreadyExists) {
+		writeErrorCode(w,
+			http.StatusConflict,
+			common.CodeAlreadyExists,
+			"Vault Already Exists",
+		)
+		return
+	}
+	if err != nil {
+		log.Printf("createVault() error: %v", err)
+		writeError(w,
+			http.StatusInternalServerError,
+			fmt.Sprintf("Cannot Create Vault: %v", err),
+		)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+func listVaults(w http.ResponseWriter, r *http.Request) {
+	log.Print("listVaults")
+	user, _, ok := r.BasicAuth()
+	if !ok {
+		writeError(w, http.StatusBadRequest, "no basic auth")
+		return
+	}
+	vaults, err := serverStore.ListVaults(user)
+	if err != nil {
+		log.Print(err)
+		writeError(w,
+			http.StatusInternalServerError,
+			"Internal Server Error",
+		)
+		return
+	}
+	writeJSON(w, vaults)
+}
+
+func deleteVault(w http.ResponseWriter, r *http.Request) {
+	log.Print("deleteVault")
+	_, vault, _, ok := vaultAccess(w, r, common.VaultOwner)
+	if !ok {
+		return
+	}
+	err := serverStore.DeleteVault(vault)
+	if err != nil {
+		log.Print(err)
+		writeError(w,
+			http.StatusInternalServerError,
+			"Internal Server Error",
+		)
+		return
+	}
+	writeStatus(w, "OK")
+}
+
+func listVaultMembers(w http.ResponseWriter, r *http.Request) {
+	log.Print("listVaultMembers")
+	_, vault, _, ok := vaultAccess(w, r, common.VaultReader)
+	if !ok {
+		return
+	}
+	members, err := serverStore.ListVaultMembers(vault)
+	if err != nil {
+		log.Print(err)
+		writeError(w,
+			http.StatusInternalServerError,
+			"Internal Server Error",
+		)
+		return
+	}
+	writeJSON(w, members)
+}
+
+func addVaultMember(w http.ResponseWriter, r *http.Request) {
+	log.Print("addVaultMember")
+	_, vault, role, ok := vaultAccess(w, r, common.VaultAdmin)
+	if !ok {
+		return
+	}
+	body, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+	var member common.VaultMember
+	err := json.Unmarshal(body, &member)
+	if err != nil {
+		writeError(w,
+			http.StatusBadRequest,
+			fmt.Sprintf("Cannot Parse Body: %v", err),
+		)
+		return
+	}
+	if !member.Role.Valid() {
+		writeError(w,
+			http.StatusBadRequest,
+			fmt.Sprintf("Unknown Role %s", member.Role),
+		)
+		return
+	}
+	// nobody can grant a role higher than their own
+	if !role.Allows(member.Role) {
+		writeError(w,
+			http.StatusForbidden,
+			fmt.Sprintf("Cannot Grant Role %s", member.Role),
+		)
+		return
+	}
+	err = serverStore.AddVaultMember(vault, member)
+	if errors.Is(err, store.ErrAlreadyExists) {
+		writeErrorCode(w,
+			http.StatusConflict,
+			common.CodeAlreadyExists,
+			"Member Already Exists",
+		)
+		return
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w,
+			http.StatusNotFound,
+			fmt.Sprintf("User %s not found", member.Name),
+		)
+		return
+	}
+	if err != nil {
+		log.Print(err)
+		writeError(w,
+			http.StatusInternalServerError,
+			"Internal Server Error",
+		)
+		return
+	}
+	writeStatus(w, "OK")
+}
+
+func removeVaultMember(w http.ResponseWriter, r *http.Request) {
+	log.Print("removeVaultMember")
+	member := chi.URLParam(r, "member")
+	required := common.VaultAdmin
+	user, _, _ := r.BasicAuth()
+	if user == member {
+		// any member can leave the vault
+		required = common.VaultReader
+	}
+	_, vault, role, ok := vaultAccess(w, r, required)
+	if !ok {
+		return
+	}
+	memberRole, err := serverStore.GetVaultRole(member, vault)
+	if err == store.ErrNotFound {
+		writeError(w,
+			http.StatusNotFound,
+			fmt.Sprintf("Member %s not found", member),
+		)
+		return
+	}
+	if err != nil {
+		log.Print(err)
+		writeError(w,
+			http.StatusInternalServerError,
+			"Internal Server Error",
+		)
+		return
+	}
+	if !role.Allows(memberRole) {
+		writeError(w,
+			http.StatusForbidden,
+			fmt.Sprintf("Cannot Remove Member With Role %s", memberRole),
+		)
+		return
+	}
+	if memberRole == common.VaultOwner {
+		members, err := serverStore.ListVaultMembers(vault)
+		if err != nil {
+			log.Print(err)
+			writeError(w,
+				http.StatusInternalServerError,
+				"Internal Server Error",
+			)
+			return
+		}
+		owners := 0
+		for _, m := range members {
+			if m.Role == common.VaultOwner {
+				owners++
+			}
+		}
+		if owners < 2 {
+			writeError(w,
+				http.StatusBadRequest,
+				"Cannot Remove The Last Owner",
+			)
+			return
+		}
+	}
+	err = serverStore.RemoveVaultMember(vault, member)
+	if err != nil {
+		log.Print(err)
+		writeError(w,
+			http.StatusInternalServerError,
+			"Internal Server Error",
+		)
+		return
+	}
+	writeStatus(w, "OK")
+}
+
+func setVaultMemberKey(w http.ResponseWriter, r *http.Request) {
+	log.Print("setVaultMemberKey")
+	member := chi.URLParam(r, "member")
+	required := common.VaultAdmin
+	user, _, _ := r.BasicAuth()
+	if user == member {
+		// any member can rewrap their own key on master key change
+		required = common.VaultReader
+	}
+	_, vault, role, ok := vaultAccess(w, r, required)
+	if !ok {
+		return
+	}
+	body, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+	var memberInfo common.VaultMember
+	err := json.Unmarshal(body, &memberInfo)
+	if err != nil || memberInfo.Key == "" {
+		writeError(w, http.StatusBadRequest, "Cannot Parse Member Key")
+		return
+	}
+	memberRole, err := serverStore.GetVaultRole(member, vault)
+	if err == store.ErrNotFound {
+		writeError(w,
+			http.StatusNotFound,
+			fmt.Sprintf("Member %s not found", member),
+		)
+		return
+	}
+	if err != nil {
+		log.Print(err)
+		writeError(w,
+			http.StatusInternalServerError,
+			"Internal Server Error",
+		)
+		return
+	}
+	if !role.Allows(memberRole) {
+		writeError(w,
+			http.StatusForbidden,
+			fmt.Sprintf("Cannot Set Key Of Member With Role %s", memberRole),
+		)
+		return
+	}
+	err = serverStore.SetVaultMemberKey(vault, member, memberInfo.Key)
+	if err == store.ErrNotFound {
+		writeError(w,
+			http.StatusNotFound,
+			fmt.Sprintf("Member %s not found", member),
+		)
+		return
+	}
+	if err != nil {
+		log.Print(err)
+		writeError(w,
+			http.StatusInternalServerError,
+			"Internal Server Error",
+		)
+		return
+	}
+	writeStatus(w, "OK")
+}
+
+func getVaultKey(w http.ResponseWriter, r *http.Request) {
+	log.Print("getVaultKey")
+	user, vault, role, ok := vaultAccess(w, r, common.VaultReader)
+	if !ok {
+		return
+	}
+	key, err := serverStore.GetVaultKey(user, vault)
+	if err != nil {
+		log.Print(err)
+		writeError(w,
+			http.StatusInternalServerError,
+			"Internal Server Error",
+		)
+		return
+	}
+	writeJSON(w, common.VaultMember{
+		Name: user,
+		Role: role,
+		Key:  key,
+	})
+}
+
+func rotateVaultKey(w http.ResponseWriter, r *http.Request) {
+	log.Print("rotateVaultKey")
+	_, vault, _, ok := vaultAccess(w, r, common.VaultAdmin)
+	if !ok {
+		return
+	}
+	body, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+	var rotation common.VaultKeyRotation
+	err := json.Unmarshal(body, &rotation)
+	if err != nil {
+		writeError(w,
+			http.StatusBadRequest,
+			fmt.Sprintf("Cannot Parse Body: %v", err),
+		)
+		return
+	}
+	for _, record := range rotation.Records {
+		if !checkRecord(w, record) {
+			return
+		}
+	}
+	err = serverStore.RotateVaultKey(vault, rotation)
+	if errors.Is(err, store.ErrIncompleteRotation) {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		log.Print(err)
+		writeError(w,
+			http.StatusInternalServerError,
+			"Internal Server Error",
+		)
+		return
+	}
+	writeStatus(w, "OK")
+}
+
+func listVaultRecords(w http.ResponseWriter, r *http.Request) {
+	log.Print("listVaultRecords")
+	_, vault, _, ok := vaultAccess(w, r, common.VaultReader)
+	if !ok {
+		return
+	}
+	if queryRecords(w, r, "", func(q common.RecordQuery) (common.RecordPage, error) {
+		return serverStore.QueryVaultRecords(vault, q)
+	}) {
+		return
+	}
+	records, err := serverStore.ListVaultRecords(vault)
+	if err != nil {
+		log.Print(err)
+		writeError(w,
+			http.StatusInternalServerError,
+			"Internal Server Error",
+		)
+		return
+	}
+	writeJSON(w, records)
+}
+
+func listVaultRecordsByType(w http.ResponseWriter, r *http.Request) {
+	recordType := common.RecordType(chi.URLParam(r, "record_type"))
+	log.Print("listVaultRecordsByType " + recordType)
+	_, vault, _, ok := vaultAccess(w, r, common.VaultReader)
+	if !ok {
+		return
+	}
+	if queryRecords(w, r, recordType, func(q common.RecordQuery) (common.RecordPage, error) {
+		return serverStore.QueryVaultRecords(vault, q)
+	}) {
+		return
+	}
+	records, err := serverStore.ListVaultRecordsByType(vault, recordType)
+	if err != nil {
+		log.Print(err)
+		writeError(w,
+			http.StatusInternalServerError,
+			"Internal Server Error",
+		)
+		return
+	}
+	writeJSON(w, records)
+}
+
+func getVaultRecordByID(w http.ResponseWriter, r *http.Request) {
+	log.Print("getVaultRecordByID")
+	_, vault, _, ok := vaultAccess(w, r, common.VaultReader)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "cannot parse 'id' param")
+		return
+	}
+	record, err := serverStore.GetVaultRecordByID(vault, int64(id))
+	if err == store.ErrNotFound {
+		msg := fmt.Sprintf("Record id %d not found", id)
+		log.Print(msg)
+		writeError(w, http.StatusNotFound, msg)
+		return
+	}
+	if err != nil {
+		log.Print(err)
+		writeError(w,
+			http.StatusInternalServerError,
+			"Internal Server Error",
+		)
+		return
+	}
+	writeJSON(w, record)
+}
+
+func getVaultRecordID(w http.ResponseWriter, r *http.Request) {
+	log.Print("getVaultRecordID")
+	_, vault, _, ok := vaultAccess(w, r, common.VaultReader)
+	if !ok {
+		return
+	}
+	recordType := common.RecordType(chi.URLParam(r, "record_type"))
+	recordName := chi.URLParam(r, "record_name")
+	var resp common.StoreRecordResponse
+	var err error
+	resp.ID, err = serverStore.GetVaultRecordID(vault, recordType, recordName)
+	if err == store.ErrNotFound {
+		msg := fmt.Sprintf("Record %s of type %s not found",
+			recordName, recordType)
+		log.Print(msg)
+		writeError(w, http.StatusNotFound, msg)
+		return
+	}
+	if err != nil {
+		log.Print(err)
+		writeError(w,
+			http.StatusInternalServerError,
+			"Internal Server Error",
+		)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+func storeVaultRecord(w http.ResponseWriter, r *http.Request) {
+	log.Print("storeVaultRecord")
+	_, vault, _, ok := vaultAccess(w, r, common.VaultWriter)
+	if !ok {
+		return
+	}
+	body, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+	var resp common.StoreRecordResponse
+	resp.Status = "OK"
+	var record common.Record
+	err := json.Unmarshal(body, &record)
+	if err != nil {
+		writeError(w,
+			http.StatusBadRequest,
+			fmt.Sprintf("Cannot Parse Body: %v", err),
+		)
+		return
+	}
+	if !checkRecord(w, record) {
+		return
+	}
+	resp.Name = record.Name
+	resp.ID, err = serverStore.StoreVaultRecord(vault, record)
+	if err != nil {
+		log.Printf("storeVaultRecord() error: %v", err)
+		writeStoreError(w, "Store", err)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+func updateVaultRecordByID(w http.ResponseWriter, r *http.Request) {
+	log.Print("updateVaultRecordByID")
+	_, vault, _, ok := vaultAccess(w, r, common.VaultWriter)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "cannot parse 'id' param")
+		return
+	}
+	body, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+	var resp common.StoreRecordResponse
+	resp.Status = "OK"
+	var record common.Record
+	err = json.Unmarshal(body, &record)
+	if err != nil {
+		writeError(w,
+			http.StatusBadRequest,
+			fmt.Sprintf("Cannot Parse Body: %v", err),
+		)
+		return
+	}
+	if !checkRecord(w, record) {
+		return
+	}
+	resp.Name = record.Name
+	resp.ID = int64(id)
+	err = serverStore.UpdateVaultRecordByID(vault, int64(id), record)
+	if err == store.ErrNotFound {
+		msg := fmt.Sprintf("Record id %d not found", id)
+		log.Print(msg)
+		writeError(w, http.StatusNotFound, msg)
+		return
+	}
+	if err != nil {
+		log.Printf("update vault record error: %v", err)
+		writeStoreError(w, "Update", err)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+func deleteVaultRecordByID(w http.ResponseWriter, r *http.Request) {
+	log.Print("deleteVaultRecordByID")
+	_, vault, _, ok := vaultAccess(w, r, common.VaultWriter)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "cannot parse 'id' param")
+		return
+	}
+	err = serverStore.DeleteVaultRecordByID(vault, int64(id))
+	if err == store.ErrNotFound {
+		msg := fmt.Sprintf("Record id %d not found", id)
+		log.Print(msg)
+		writeError(w, http.StatusNotFound, msg)
+		return
+	}
+	if err != nil {
+		log.Print(err)
+		writeError(w,
+			http.StatusInternalServerError,
+			"Internal Server Error",
+		)
+		return
+	}
+	writeStatus(w, "OK")
+}
@@ -0,0 +1,224 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testVault      = "team"
+	testMember     = "user2"
+	testMemberPass = "pass2"
+)
+
+func prepareVaultTest(t *testing.T) http.Handler {
+	router := prepareTest(t)
+
+	user := common.User{
+		Name:     testMember,
+		Password: testMemberPass,
+	}
+	createUserBody, _ := json.Marshal(user)
+	resp, _ := testHTTPRequest(t,
+		router,
+		http.MethodPost,
+		"/users",
+		string(createUserBody),
+		"",
+		"",
+	)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	vaultBody, _ := json.Marshal(common.Vault{
+		Name: testVault,
+		Key:  "owner key",
+	})
+	vaultResp, _ := testHTTPRequest(t,
+		router,
+		http.MethodPost,
+		"/vaults",
+		string(vaultBody),
+		testUser,
+		testPass,
+	)
+	defer vaultResp.Body.Close()
+	require.Equal(t, http.StatusOK, vaultResp.StatusCode)
+
+	return router
+}
+
+func addTestMember(t *testing.T, router http.Handler, role common.VaultRole) {
+	memberBody, _ := json.Marshal(common.VaultMember{
+		Name: testMember,
+		Role: role,
+		Key:  "member key",
+	})
+	resp, _ := testHTTPRequest(t,
+		router,
+		http.MethodPost,
+		fmt.Sprintf("/vaults/%s/members", testVault),
+		string(memberBody),
+		testUser,
+		testPass,
+	)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func Test_Vault(t *testing.T) {
+	record := testRecord("rec1", "0000", common.NoteRecord)
+	recordBody, _ := json.Marshal(record)
+	recordsPath := fmt.Sprintf("/vaults/%s/records", testVault)
+
+	t.Run("Non-member has no access", func(t *testing.T) {
+		router := prepareVaultTest(t)
+
+		resp, _ := testHTTPRequest(t,
+			router,
+			http.MethodGet,
+			recordsPath,
+			"",
+			testMember,
+			testMemberPass,
+		)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("Reader cannot store records", func(t *testing.T) {
+		router := prepareVaultTest(t)
+		addTestMember(t, router, common.VaultReader)
+
+		resp, _ := testHTTPRequest(t,
+			router,
+			http.MethodPost,
+			recordsPath,
+			string(recordBody),
+			testMember,
+			testMemberPass,
+		)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+		listResp, _ := testHTTPRequest(t,
+			router,
+			http.MethodGet,
+			recordsPath,
+			"",
+			testMember,
+			testMemberPass,
+		)
+		defer listResp.Body.Close()
+		assert.Equal(t, http.StatusOK, listResp.StatusCode)
+	})
+
+	t.Run("Writer stores and owner reads records", func(t *testing.T) {
+		router := prepareVaultTest(t)
+		addTestMember(t, router, common.VaultWriter)
+
+		storeResp, storeRespBody := testHTTPRequest(t,
+			router,
+			http.MethodPost,
+			recordsPath,
+			string(recordBody),
+			testMember,
+			testMemberPass,
+		)
+		defer storeResp.Body.Close()
+		require.Equal(t, http.StatusOK, storeResp.StatusCode)
+		var stored common.StoreRecordResponse
+		err := json.Unmarshal([]byte(storeRespBody), &stored)
+		require.NoError(t, err)
+
+		getResp, getRespBody := testHTTPRequest(t,
+			router,
+			http.MethodGet,
+			fmt.Sprintf("%s/%d", recordsPath, stored.ID),
+			"",
+			testUser,
+			testPass,
+		)
+		defer getResp.Body.Close()
+		assert.Equal(t, http.StatusOK, getResp.StatusCode)
+		var gotRecord common.Record
+		err = json.Unmarshal([]byte(getRespBody), &gotRecord)
+		assert.NoError(t, err)
+		assert.Equal(t, record, gotRecord)
+
+		// the vault record is not the personal record of the owner
+		personalResp, _ := testHTTPRequest(t,
+			router,
+			http.MethodGet,
+			fmt.Sprintf("/records/%d", stored.ID),
+			"",
+			testUser,
+			testPass,
+		)
+		defer personalResp.Body.Close()
+		assert.Equal(t, http.StatusNotFound, personalResp.StatusCode)
+	})
+
+	t.Run("Writer cannot manage members", func(t *testing.T) {
+		router := prepareVaultTest(t)
+		addTestMember(t, router, common.VaultWriter)
+
+		resp, _ := testHTTPRequest(t,
+			router,
+			http.MethodDelete,
+			fmt.Sprintf("/vaults/%s/members/%s", testVault, testUser),
+			"",
+			testMember,
+			testMemberPass,
+		)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+		// but can leave the vault
+		leaveResp, _ := testHTTPRequest(t,
+			router,
+			http.MethodDelete,
+			fmt.Sprintf("/vaults/%s/members/%s", testVault, testMember),
+			"",
+			testMember,
+			testMemberPass,
+		)
+		defer leaveResp.Body.Close()
+		assert.Equal(t, http.StatusOK, leaveResp.StatusCode)
+	})
+
+	t.Run("Admin cannot replace owner key", func(t *testing.T) {
+		router := prepareVaultTest(t)
+		addTestMember(t, router, common.VaultAdmin)
+
+		keyBody, _ := json.Marshal(common.VaultMember{Key: "admin key"})
+		resp, _ := testHTTPRequest(t,
+			router,
+			http.MethodPut,
+			fmt.Sprintf("/vaults/%s/members/%s", testVault, testUser),
+			string(keyBody),
+			testMember,
+			testMemberPass,
+		)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+		// but can rewrap their own key
+		ownResp, _ := testHTTPRequest(t,
+			router,
+			http.MethodPut,
+			fmt.Sprintf("/vaults/%s/members/%s", testVault, testMember),
+			string(keyBody),
+			testMember,
+			testMemberPass,
+		)
+		defer ownResp.Body.Close()
+		assert.Equal(t, http.StatusOK, ownResp.StatusCode)
+	})
+}
@@ -0,0 +1,53 @@
+package sshkey
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// NewAgent returns the SSH agent holding the private keys decrypted
+// in memory only
+func NewAgent(keys []common.SSHKey) (agent.Agent, error) {
+	keyring := agent.NewKeyring()
+	for _, k := range keys {
+		raw, err := ParsePrivateKey(k)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", k.Comment, err)
+		}
+		err = keyring.Add(agent.AddedKey{
+			PrivateKey: raw,
+			Comment:    k.Comment,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", k.Comment, err)
+		}
+	}
+	return keyring, nil
+}
+
+// Serve serves the SSH agent protocol on the listener
+// until the listener is closed
+func Serve(l net.Listener, a agent.Agent) error {
+	for {
+		conn, err := l.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			err := agent.ServeAgent(a, conn)
+			if err != nil && !errors.Is(err, io.EOF) {
+				log.Printf("ssh agent: %v", err)
+			}
+		}()
+	}
+}
@@ -0,0 +1,153 @@
+package sshkey
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"golang.org/x/crypto/ssh"
+)
+
+// ErrPassphraseMissing is returned when the private key is encrypted
+// and the passphrase is not set
+var ErrPassphraseMissing = errors.New("private key is encrypted, passphrase is not set")
+
+// opensshMagic starts the OpenSSH private key format
+const opensshMagic = "openssh-key-v1\x00"
+
+// opensshBlockSize is the block size of the unencrypted private section
+const opensshBlockSize = 8
+
+// Generate returns new ed25519 key pair with the private key
+// in the OpenSSH format
+func Generate(comment string) (common.SSHKey, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return common.SSHKey{}, err
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return common.SSHKey{}, err
+	}
+	private, err := marshalED25519(priv, sshPub, comment)
+	if err != nil {
+		return common.SSHKey{}, err
+	}
+	return common.SSHKey{
+		PrivateKey: private,
+		PublicKey:  authorizedKey(sshPub, comment),
+		Comment:    comment,
+	}, nil
+}
+
+// marshalED25519 encodes the ed25519 private key in the unencrypted
+// OpenSSH private key format
+func marshalED25519(priv ed25519.PrivateKey,
+	pub ssh.PublicKey,
+	comment string,
+) (string, error) {
+	var check [4]byte
+	_, err := rand.Read(check[:])
+	if err != nil {
+		return "", err
+	}
+	checkInt := binary.BigEndian.Uint32(check[:])
+
+	section := ssh.Marshal(struct {
+		Check1  uint32
+		Check2  uint32
+		Keytype string
+		Pub     []byte
+		Priv    []byte
+		Comment string
+	}{
+		Check1:  checkInt,
+		Check2:  checkInt,
+		Keytype: ssh.KeyAlgoED25519,
+		Pub:     priv.Public().(ed25519.PublicKey),
+		Priv:    priv,
+		Comment: comment,
+	})
+	for i := 1; len(section)%opensshBlockSize != 0; i++ {
+		section = append(section, byte(i))
+	}
+
+	key := ssh.Marshal(struct {
+		CipherName   string
+		KdfName      string
+		KdfOpts      string
+		NumKeys      uint32
+		PubKey       []byte
+		PrivKeyBlock []byte
+	}{
+		CipherName:   "none",
+		KdfName:      "none",
+		NumKeys:      1,
+		PubKey:       pub.Marshal(),
+		PrivKeyBlock: section,
+	})
+	return string(pem.EncodeToMemory(&pem.Block{
+		Type:  "OPENSSH PRIVATE KEY",
+		Bytes: append([]byte(opensshMagic), key...),
+	})), nil
+}
+
+// authorizedKey returns the public key in the authorized keys format
+func authorizedKey(pub ssh.PublicKey, comment string) string {
+	line := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub)))
+	if comment != "" {
+		line += " " + comment
+	}
+	return line
+}
+
+// ParsePrivateKey returns the private key decrypted with the passphrase
+// if it is encrypted
+func ParsePrivateKey(k common.SSHKey) (interface{}, error) {
+	key, err := ssh.ParseRawPrivateKey([]byte(k.PrivateKey))
+	var missing *ssh.PassphraseMissingError
+	if !errors.As(err, &missing) {
+		return key, err
+	}
+	if k.Passphrase == "" {
+		return nil, ErrPassphraseMissing
+	}
+	return ssh.ParseRawPrivateKeyWithPassphrase(
+		[]byte(k.PrivateKey),
+		[]byte(k.Passphrase),
+	)
+}
+
+// Import returns the key pair of the PEM encoded private key,
+// the public key is derived from the private one
+func Import(private []byte, passphrase, comment string) (common.SSHKey, error) {
+	k := common.SSHKey{
+		PrivateKey: string(private),
+		Passphrase: passphrase,
+		Comment:    comment,
+	}
+	raw, err := ParsePrivateKey(k)
+	if err != nil {
+		return k, err
+	}
+	signer, err := ssh.NewSignerFromKey(raw)
+	if err != nil {
+		return k, err
+	}
+	k.PublicKey = authorizedKey(signer.PublicKey(), k.Comment)
+	return k, nil
+}
+
+// Fingerprint returns the SHA256 fingerprint of the public key
+func Fingerprint(k common.SSHKey) (string, error) {
+	pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(k.PublicKey))
+	if err != nil {
+		return "", fmt.Errorf("public key: %w", err)
+	}
+	return ssh.FingerprintSHA256(pub), nil
+}
@@ -0,0 +1,55 @@
+package store
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+)
+
+// labelMatch matches the record having the label index given,
+// the labels column holds the indexes surrounded by spaces
+const labelMatch = `instr(records.labels, ' ' || ? || ' ') > 0`
+
+// encodeTags returns the tags column value: the encrypted tags
+// as JSON array, the empty string for no tags
+func encodeTags(tags []string) (string, error) {
+	if len(tags) == 0 {
+		return "", nil
+	}
+	buf, err := json.Marshal(tags)
+	return string(buf), err
+}
+
+// encodeLabels returns the labels column value: the label indexes
+// separated and surrounded by spaces to match any of them by instr
+func encodeLabels(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	return " " + strings.Join(labels, " ") + " "
+}
+
+// labelColumns returns the folder, tags and labels columns values
+// of the record
+func labelColumns(record common.Record) (string, string, string, error) {
+	tags, err := encodeTags(record.Tags)
+	return record.Folder, tags, encodeLabels(record.LabelIndex), err
+}
+
+// setLabels sets the folder, tags and label indexes of the record
+// by the columns values
+func setLabels(record *common.Record, folder, tags, labels string) error {
+	record.Folder = folder
+	record.Tags = nil
+	if tags != "" {
+		if err := json.Unmarshal([]byte(tags), &record.Tags); err != nil {
+			return err
+		}
+	}
+	record.LabelIndex = strings.Fields(labels)
+	if len(record.LabelIndex) == 0 {
+		record.LabelIndex = nil
+	}
+	return nil
+}
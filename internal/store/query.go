@@ -0,0 +1,189 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+)
+
+// sortColumns are the records columns to sort the listing by
+var sortColumns = map[string]string{
+	common.SortByID:       "records.id",
+	common.SortByType:     "records.type",
+	common.SortByModified: "records.updated_at",
+}
+
+// cursor is the position of the last record of the page in the listing
+// ordered by the sort column and then by ID. Sort is the sort order
+// of the listing the cursor is for.
+type cursor struct {
+	Sort string `json:"s"`
+	Text string `json:"t,omitempty"`
+	Num  int64  `json:"n,omitempty"`
+	ID   int64  `json:"i"`
+}
+
+// encode returns the cursor as the next page token
+func (c cursor) encode() string {
+	buf, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+// decodeCursor parses the next page token of the sort order given
+func decodeCursor(token, sort string) (cursor, error) {
+	var c cursor
+	buf, err := base64.RawURLEncoding.DecodeString(token)
+	if err == nil {
+		err = json.Unmarshal(buf, &c)
+	}
+	if err != nil {
+		return c, fmt.Errorf("%w: bad cursor", common.ErrBadQuery)
+	}
+	if c.Sort != sort {
+		return c, fmt.Errorf("%w: cursor is for sort %q", common.ErrBadQuery, c.Sort)
+	}
+	return c, nil
+}
+
+// queryRecords returns the page of the records listing of the owner
+// matched by the owner clause
+func (s *Store) queryRecords(ownerJoin string,
+	owner string,
+	q common.RecordQuery,
+) (common.RecordPage, error) {
+	page := common.RecordPage{Records: []common.ListedRecord{}}
+	key, desc, err := q.SortKey()
+	if err != nil {
+		return page, err
+	}
+	column := sortColumns[key]
+	sort := key
+	op, order := ">", "ASC"
+	if desc {
+		sort = "-" + key
+		op, order = "<", "DESC"
+	}
+
+	where := []string{ownerJoin}
+	args := []interface{}{owner}
+	if len(q.Types) > 0 {
+		where = append(where, "records.type IN (?"+
+			strings.Repeat(", ?", len(q.Types)-1)+")")
+		for _, t := range q.Types {
+			args = append(args, t)
+		}
+	}
+	for _, label := range q.Labels {
+		where = append(where, labelMatch)
+		args = append(args, label)
+	}
+	if !q.ModifiedSince.IsZero() {
+		where = append(where, "records.updated_at >= ?")
+		args = append(args, q.ModifiedSince.UnixNano())
+	}
+	if q.Cursor != "" {
+		c, err := decodeCursor(q.Cursor, sort)
+		if err != nil {
+			return page, err
+		}
+		var value interface{} = c.Text
+		if key == common.SortByModified {
+			value = c.Num
+		}
+		if key == common.SortByID {
+			where = append(where, "records.id "+op+" ?")
+		} else {
+			where = append(where, "("+column+" "+op+" ? OR ("+
+				column+" = ? AND records.id "+op+" ?))")
+			args = append(args, value, value)
+		}
+		args = append(args, c.ID)
+	}
+	limit := q.Limit
+	if limit <= 0 || limit > common.MaxPageLimit {
+		limit = common.DefaultPageLimit
+	}
+	args = append(args, limit+1)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	rows, err := s.db.Query(
+		`SELECT records.id, records.type, records.name,
+				COALESCE(records.data_key, ''),
+				COALESCE(records.name_index, ''),
+				records.folder, records.tags, records.labels,
+				records.updated_at
+			FROM records `+strings.Join(where, " AND ")+`
+			ORDER BY `+column+` `+order+`, records.id `+order+`
+			LIMIT ?`,
+		args...,
+	)
+	if err != nil {
+		return page, err
+	}
+	defer rows.Close()
+
+	var updated []int64
+	for rows.Next() {
+		var r common.ListedRecord
+		var updatedAt int64
+		var folder, tags, labels string
+		err = rows.Scan(&r.ID,
+			&r.Type,
+			&r.Name,
+			&r.DataKey,
+			&r.NameIndex,
+			&folder,
+			&tags,
+			&labels,
+			&updatedAt,
+		)
+		if err == nil {
+			err = setLabels(&r.Record, folder, tags, labels)
+		}
+		if err != nil {
+			return page, err
+		}
+		r.UpdatedAt = time.Unix(0, updatedAt).UTC()
+		page.Records = append(page.Records, r)
+		updated = append(updated, updatedAt)
+	}
+	if err = rows.Err(); err != nil {
+		return page, err
+	}
+
+	if len(page.Records) > limit {
+		page.Records = page.Records[:limit]
+		last := page.Records[limit-1]
+		c := cursor{Sort: sort, ID: last.ID}
+		switch key {
+		case common.SortByType:
+			c.Text = string(last.Type)
+		case common.SortByModified:
+			c.Num = updated[limit-1]
+		}
+		page.Next = c.encode()
+	}
+	return page, nil
+}
+
+// QueryRecords returns the page of the user records listing
+func (s *Store) QueryRecords(user string,
+	q common.RecordQuery,
+) (common.RecordPage, error) {
+	return s.queryRecords(`JOIN users ON records.user_id = users.id
+			WHERE users.user = ?`, user, q)
+}
+
+// QueryVaultRecords returns the page of the vault records listing
+func (s *Store) QueryVaultRecords(vault string,
+	q common.RecordQuery,
+) (common.RecordPage, error) {
+	return s.queryRecords(`JOIN vaults ON records.vault_id = vaults.id
+			WHERE vaults.name = ?`, vault, q)
+}
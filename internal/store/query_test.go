@@ -0,0 +1,141 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func pageNames(page common.RecordPage) []string {
+	names := []string{}
+	for _, r := range page.Records {
+		names = append(names, r.Name)
+	}
+	return names
+}
+
+func TestStore_QueryRecords(t *testing.T) {
+	store := dropCreateStore(t)
+	user := "user1"
+	_, err := store.AddUser(common.User{Name: user})
+	assert.NoError(t, err)
+
+	for _, r := range []common.Record{
+		{Name: "beta", Type: common.NoteRecord},
+		{Name: "alpha", Type: common.AccountRecord},
+		{Name: "gamma", Type: common.NoteRecord},
+		{Name: "alphabet", Type: common.CardRecord},
+	} {
+		_, err = store.StoreRecord(user, r)
+		assert.NoError(t, err)
+	}
+	since := time.Now()
+	_, err = store.StoreRecord(user, common.Record{
+		Name: "delta",
+		Type: common.NoteRecord,
+	})
+	assert.NoError(t, err)
+
+	t.Run("Sort by ID by default", func(t *testing.T) {
+		page, err := store.QueryRecords(user, common.RecordQuery{})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"beta", "alpha", "gamma", "alphabet", "delta"},
+			pageNames(page))
+		assert.Empty(t, page.Next)
+	})
+
+	t.Run("Pages by modification time descending", func(t *testing.T) {
+		var names []string
+		q := common.RecordQuery{Sort: "-modified", Limit: 2}
+		for pages := 0; ; pages++ {
+			assert.Less(t, pages, 3)
+			page, err := store.QueryRecords(user, q)
+			assert.NoError(t, err)
+			names = append(names, pageNames(page)...)
+			if page.Next == "" {
+				break
+			}
+			q.Cursor = page.Next
+		}
+		assert.Equal(t, []string{"delta", "alphabet", "gamma", "alpha", "beta"},
+			names)
+	})
+
+	t.Run("Pages by type", func(t *testing.T) {
+		q := common.RecordQuery{Sort: "type", Limit: 3}
+		page, err := store.QueryRecords(user, q)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"alpha", "alphabet", "beta"}, pageNames(page))
+
+		q.Cursor = page.Next
+		page, err = store.QueryRecords(user, q)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"gamma", "delta"}, pageNames(page))
+		assert.Empty(t, page.Next)
+	})
+
+	t.Run("Filter", func(t *testing.T) {
+		page, err := store.QueryRecords(user, common.RecordQuery{
+			Types: []common.RecordType{common.NoteRecord},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"beta", "gamma", "delta"}, pageNames(page))
+
+		page, err = store.QueryRecords(user, common.RecordQuery{
+			Types: []common.RecordType{common.AccountRecord, common.CardRecord},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"alpha", "alphabet"}, pageNames(page))
+
+		page, err = store.QueryRecords(user, common.RecordQuery{
+			ModifiedSince: since,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"delta"}, pageNames(page))
+		assert.False(t, page.Records[0].UpdatedAt.Before(since))
+	})
+
+	t.Run("Filter by labels", func(t *testing.T) {
+		id, err := store.StoreRecord(user, common.Record{
+			Name:       "labeled",
+			Type:       common.NoteRecord,
+			LabelIndex: []string{"folder1", "tag1"},
+		})
+		assert.NoError(t, err)
+		defer store.DeleteRecordByID(user, id)
+
+		page, err := store.QueryRecords(user, common.RecordQuery{
+			Labels: []string{"tag1", "folder1"},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"labeled"}, pageNames(page))
+		assert.Equal(t, []string{"folder1", "tag1"}, page.Records[0].LabelIndex)
+
+		page, err = store.QueryRecords(user, common.RecordQuery{
+			Labels: []string{"tag1", "tag2"},
+		})
+		assert.NoError(t, err)
+		assert.Empty(t, page.Records)
+
+		// the label is matched as a whole
+		page, err = store.QueryRecords(user, common.RecordQuery{
+			Labels: []string{"tag"},
+		})
+		assert.NoError(t, err)
+		assert.Empty(t, page.Records)
+	})
+
+	t.Run("Bad cursor", func(t *testing.T) {
+		page, err := store.QueryRecords(user, common.RecordQuery{Limit: 1})
+		assert.NoError(t, err)
+		_, err = store.QueryRecords(user, common.RecordQuery{
+			Sort:   "type",
+			Cursor: page.Next,
+		})
+		assert.ErrorIs(t, err, common.ErrBadQuery)
+		_, err = store.QueryRecords(user, common.RecordQuery{Cursor: "!"})
+		assert.ErrorIs(t, err, common.ErrBadQuery)
+	})
+}
@@ -8,26 +8,44 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// nameMatch matches the record by the name blind index, the records
+// stored without the index are matched by the name itself
+const nameMatch = `(records.name_index = ?
+	OR (records.name_index IS NULL AND records.name = ?))`
+
 // StoreRecordWithID stores record with the ID specified
 func (s *Store) StoreRecordWithID(id int64,
 	user string,
 	record common.Record,
 ) error {
+	folder, tags, labels, err := labelColumns(record)
+	if err != nil {
+		return err
+	}
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	_, err := s.db.Exec(`INSERT INTO records
-		(id, user_id, name, type, opaque, meta)
-		VALUES(?, (SELECT id from users where user=?), ?, ?, ?, ?)`,
+	_, err = s.db.Exec(`INSERT INTO records
+		(id, user_id, name, type, opaque, meta, data_key, name_index,
+			updated_at, folder, tags, labels)
+		VALUES(?, (SELECT id from users where user=?), ?, ?, ?, ?, ?,
+			NULLIF(?, ''), ?, ?, ?, ?)`,
 		id,
 		user,
 		record.Name,
 		record.Type,
 		record.Opaque,
 		record.Meta,
+		record.DataKey,
+		record.NameIndex,
+		updatedAt(),
+		folder,
+		tags,
+		labels,
 	)
 	if err != nil {
-		return err
+		return uniqueViolation(err)
 	}
 
 	return nil
@@ -46,8 +64,8 @@ func (s *Store) GetRecordID(user string,
 			FROM records JOIN users ON records.user_id = users.id
 			WHERE users.user = ?
 			AND records.type = ?
-			AND records.name = ?`,
-		user, t, name,
+			AND `+nameMatch,
+		user, t, name, name,
 	)
 
 	var id int64
@@ -63,20 +81,33 @@ func (s *Store) GetRecordID(user string,
 
 // StoreRecord stores Record data for given user
 func (s *Store) StoreRecord(user string, record common.Record) (int64, error) {
+	folder, tags, labels, err := labelColumns(record)
+	if err != nil {
+		return 0, err
+	}
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
 	res, err := s.db.Exec(`INSERT INTO records
-		(user_id, name, type, opaque, meta)
-		VALUES((SELECT id from users where user=?), ?, ?, ?, ?)`,
+		(user_id, name, type, opaque, meta, data_key, name_index,
+			updated_at, folder, tags, labels)
+		VALUES((SELECT id from users where user=?), ?, ?, ?, ?, ?,
+			NULLIF(?, ''), ?, ?, ?, ?)`,
 		user,
 		record.Name,
 		record.Type,
 		record.Opaque,
 		record.Meta,
+		record.DataKey,
+		record.NameIndex,
+		updatedAt(),
+		folder,
+		tags,
+		labels,
 	)
 	if err != nil {
-		return 0, err
+		return 0, uniqueViolation(err)
 	}
 
 	id, err := res.LastInsertId()
@@ -91,11 +122,18 @@ func (s *Store) UpdateRecordByID(user string,
 	id int64,
 	record common.Record,
 ) error {
+	folder, tags, labels, err := labelColumns(record)
+	if err != nil {
+		return err
+	}
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
 	res, err := s.db.Exec(`UPDATE records
-		SET name = ?, type = ?, opaque = ?, meta = ?
+		SET name = ?, type = ?, opaque = ?, meta = ?, data_key = ?,
+			name_index = NULLIF(?, ''), updated_at = ?,
+			folder = ?, tags = ?, labels = ?
 		WHERE id in
 		( SELECT records.id FROM records
 			JOIN users ON records.user_id = users.id
@@ -104,11 +142,17 @@ func (s *Store) UpdateRecordByID(user string,
 		record.Type,
 		record.Opaque,
 		record.Meta,
+		record.DataKey,
+		record.NameIndex,
+		updatedAt(),
+		folder,
+		tags,
+		labels,
 		user,
 		id,
 	)
 	if err != nil {
-		return err
+		return uniqueViolation(err)
 	}
 
 	rows, err := res.RowsAffected()
@@ -128,27 +172,41 @@ func (s *Store) UpdateRecordByTypeName(user string,
 	name string,
 	record common.Record,
 ) error {
+	folder, tags, labels, err := labelColumns(record)
+	if err != nil {
+		return err
+	}
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
 	res, err := s.db.Exec(`UPDATE records
-		SET name = ?, type = ?, opaque = ?, meta = ?
+		SET name = ?, type = ?, opaque = ?, meta = ?, data_key = ?,
+			name_index = NULLIF(?, ''), updated_at = ?,
+			folder = ?, tags = ?, labels = ?
 		WHERE id in
 		( SELECT records.id FROM records
 			JOIN users ON records.user_id = users.id
 			WHERE users.user = ?
 			AND records.type = ?
-			and records.name = ?)`,
+			AND `+nameMatch+`)`,
 		record.Name,
 		record.Type,
 		record.Opaque,
 		record.Meta,
+		record.DataKey,
+		record.NameIndex,
+		updatedAt(),
+		folder,
+		tags,
+		labels,
 		user,
 		t,
 		name,
+		name,
 	)
 	if err != nil {
-		return err
+		return uniqueViolation(err)
 	}
 
 	rows, err := res.RowsAffected()
@@ -163,14 +221,17 @@ func (s *Store) UpdateRecordByTypeName(user string,
 }
 
 // ListRecords returns list of stored records for the given user
-// with name and type fields filled
+// with name, type, data key, name index, folder and tags fields filled
 func (s *Store) ListRecords(user string) (common.Records, error) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
 	records := make(common.Records)
 	rows, err := s.db.Query(
-		`SELECT records.id, records.type, records.name
+		`SELECT records.id, records.type, records.name,
+				COALESCE(records.data_key, ''),
+				COALESCE(records.name_index, ''),
+				records.folder, records.tags, records.labels
 			FROM records JOIN users ON records.user_id = users.id
 			WHERE users.user = ?`,
 		user,
@@ -182,7 +243,19 @@ func (s *Store) ListRecords(user string) (common.Records, error) {
 	for rows.Next() {
 		var id int64
 		var record common.Record
-		err = rows.Scan(&id, &record.Type, &record.Name)
+		var folder, tags, labels string
+		err = rows.Scan(&id,
+			&record.Type,
+			&record.Name,
+			&record.DataKey,
+			&record.NameIndex,
+			&folder,
+			&tags,
+			&labels,
+		)
+		if err == nil {
+			err = setLabels(&record, folder, tags, labels)
+		}
 		if err != nil {
 			return records, err
 		}
@@ -192,7 +265,8 @@ func (s *Store) ListRecords(user string) (common.Records, error) {
 }
 
 // ListRecordsByType returns list of stored records of the given type
-// for the given user with name and type fields filled
+// for the given user with name, type, data key, name index, folder
+// and tags fields filled
 func (s *Store) ListRecordsByType(user string,
 	t common.RecordType,
 ) (common.Records, error) {
@@ -201,7 +275,10 @@ func (s *Store) ListRecordsByType(user string,
 
 	records := make(common.Records)
 	rows, err := s.db.Query(
-		`SELECT records.id, records.name
+		`SELECT records.id, records.name,
+				COALESCE(records.data_key, ''),
+				COALESCE(records.name_index, ''),
+				records.folder, records.tags, records.labels
 			FROM records JOIN users ON records.user_id = users.id
 			WHERE users.user = ? AND records.type = ?`,
 		user, t,
@@ -213,8 +290,19 @@ func (s *Store) ListRecordsByType(user string,
 	for rows.Next() {
 		var id int64
 		var record common.Record
+		var folder, tags, labels string
 		record.Type = t
-		err = rows.Scan(&id, &record.Name)
+		err = rows.Scan(&id,
+			&record.Name,
+			&record.DataKey,
+			&record.NameIndex,
+			&folder,
+			&tags,
+			&labels,
+		)
+		if err == nil {
+			err = setLabels(&record, folder, tags, labels)
+		}
 		if err != nil {
 			return records, err
 		}
@@ -231,16 +319,25 @@ func (s *Store) GetRecordByID(user string, id int64) (common.Record, error) {
 	var record common.Record
 
 	row := s.db.QueryRow(
-		`SELECT records.name, records.type, records.opaque, records.meta
+		`SELECT records.name, records.type, records.opaque, records.meta,
+				COALESCE(records.data_key, ''),
+				COALESCE(records.name_index, ''),
+				records.folder, records.tags, records.labels
 			FROM records JOIN users ON records.user_id = users.id
 			WHERE users.user = ? AND records.id = ?`,
 		user, id,
 	)
 
+	var folder, tags, labels string
 	err := row.Scan(&record.Name,
 		&record.Type,
 		&record.Opaque,
 		&record.Meta,
+		&record.DataKey,
+		&record.NameIndex,
+		&folder,
+		&tags,
+		&labels,
 	)
 	if err == sql.ErrNoRows {
 		return record, ErrNotFound
@@ -248,7 +345,7 @@ func (s *Store) GetRecordByID(user string, id int64) (common.Record, error) {
 	if err != nil {
 		return record, err
 	}
-	return record, nil
+	return record, setLabels(&record, folder, tags, labels)
 }
 
 // GetRecordByTypeName returns stored record by type and name
@@ -262,18 +359,27 @@ func (s *Store) GetRecordByTypeName(user string,
 	var record common.Record
 
 	row := s.db.QueryRow(
-		`SELECT records.name, records.type, records.opaque, records.meta
+		`SELECT records.name, records.type, records.opaque, records.meta,
+				COALESCE(records.data_key, ''),
+				COALESCE(records.name_index, ''),
+				records.folder, records.tags, records.labels
 			FROM records JOIN users ON records.user_id = users.id
 			WHERE users.user = ?
 			AND records.type = ?
-			AND records.name = ?`,
-		user, t, name,
+			AND `+nameMatch,
+		user, t, name, name,
 	)
 
+	var folder, tags, labels string
 	err := row.Scan(&record.Name,
 		&record.Type,
 		&record.Opaque,
 		&record.Meta,
+		&record.DataKey,
+		&record.NameIndex,
+		&folder,
+		&tags,
+		&labels,
 	)
 	if err == sql.ErrNoRows {
 		return record, ErrNotFound
@@ -281,7 +387,7 @@ func (s *Store) GetRecordByTypeName(user string,
 	if err != nil {
 		return record, err
 	}
-	return record, nil
+	return record, setLabels(&record, folder, tags, labels)
 }
 
 // DeleteRecordByID deletes the specified record record by ID
@@ -326,9 +432,9 @@ func (s *Store) DeleteRecordByTypeName(user string,
 				JOIN users ON records.user_id = users.id
 				WHERE users.user = ?
 				AND records.type = ?
-				AND records.name = ?
+				AND `+nameMatch+`
 			)`,
-		user, t, name,
+		user, t, name, name,
 	)
 	if err != nil {
 		return err
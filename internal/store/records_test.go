@@ -42,6 +42,68 @@ func TestStore_GetRecord(t *testing.T) {
 	})
 }
 
+func TestStore_GetRecordByNameIndex(t *testing.T) {
+	store := dropCreateStore(t)
+	user := "user1"
+	recType := common.NoteRecord
+
+	_, err := store.AddUser(common.User{
+		Name: user,
+	})
+	assert.NoError(t, err)
+
+	record := common.Record{
+		Name:      "encrypted name",
+		Opaque:    "1111",
+		Type:      recType,
+		DataKey:   "data key",
+		NameIndex: "index1",
+	}
+	id, err := store.StoreRecord(user, record)
+	assert.NoError(t, err)
+
+	// the records with the name index are looked up by the index only
+	gotID, err := store.GetRecordID(user, recType, "index1")
+	assert.NoError(t, err)
+	assert.Equal(t, id, gotID)
+	_, err = store.GetRecordID(user, recType, record.Name)
+	assert.Equal(t, ErrNotFound, err)
+
+	recordRet, err := store.GetRecordByTypeName(user, recType, "index1")
+	assert.NoError(t, err)
+	assert.Equal(t, record, recordRet)
+
+	// the name index is unique
+	_, err = store.StoreRecord(user, common.Record{
+		Name:      "another encrypted name",
+		Type:      recType,
+		NameIndex: "index1",
+	})
+	assert.ErrorIs(t, err, ErrAlreadyExists)
+
+	// the records without the index are looked up by the name
+	clearID, err := store.StoreRecord(user, common.Record{
+		Name: "clear name",
+		Type: recType,
+	})
+	assert.NoError(t, err)
+	gotID, err = store.GetRecordID(user, recType, "clear name")
+	assert.NoError(t, err)
+	assert.Equal(t, clearID, gotID)
+
+	records, err := store.ListRecords(user)
+	assert.NoError(t, err)
+	assert.Equal(t, common.Records{
+		id: {
+			Name:      record.Name,
+			Type:      recType,
+			DataKey:   record.DataKey,
+			NameIndex: record.NameIndex,
+		},
+		clearID: {Name: "clear name", Type: recType},
+	}, records)
+}
+
 func TestStore_ListRecords(t *testing.T) {
 	store := dropCreateStore(t)
 	t.Run("Get multiple records", func(t *testing.T) {
@@ -181,3 +243,68 @@ func TestStore_UpdateRecord(t *testing.T) {
 			"Updated record number should change (again)")
 	})
 }
+
+func TestStore_RecordLabels(t *testing.T) {
+	store := dropCreateStore(t)
+	user := "user1"
+	_, err := store.AddUser(common.User{Name: user})
+	assert.NoError(t, err)
+
+	record := common.Record{
+		Name:       "rec1",
+		Type:       common.NoteRecord,
+		Opaque:     "1111",
+		Folder:     "encrypted folder",
+		Tags:       []string{"encrypted tag1", "encrypted tag2"},
+		LabelIndex: []string{"folder1", "tag1", "tag2"},
+	}
+	id, err := store.StoreRecord(user, record)
+	assert.NoError(t, err)
+
+	recordRet, err := store.GetRecordByID(user, id)
+	assert.NoError(t, err)
+	assert.Equal(t, record, recordRet)
+
+	records, err := store.ListRecordsByType(user, common.NoteRecord)
+	assert.NoError(t, err)
+	assert.Equal(t, record.Tags, records[id].Tags)
+	assert.Equal(t, record.LabelIndex, records[id].LabelIndex)
+
+	record.Folder = ""
+	record.Tags = nil
+	record.LabelIndex = []string{"tag3"}
+	err = store.UpdateRecordByID(user, id, record)
+	assert.NoError(t, err)
+	recordRet, err = store.GetRecordByID(user, id)
+	assert.NoError(t, err)
+	assert.Equal(t, record, recordRet)
+}
+
+func TestStore_AddColumns(t *testing.T) {
+	err := DropStore(defaultDBFile)
+	assert.NoError(t, err)
+
+	// the records table of the previous versions
+	store, err := NewStore(defaultDBFile)
+	assert.NoError(t, err)
+	for _, column := range []string{"updated_at", "folder", "tags", "labels"} {
+		_, err = store.db.Exec(`ALTER TABLE records DROP COLUMN ` + column)
+		assert.NoError(t, err)
+	}
+	_, err = store.AddUser(common.User{Name: "user1"})
+	assert.NoError(t, err)
+	_, err = store.db.Exec(`INSERT INTO records (user_id, name, type)
+		VALUES((SELECT id from users where user='user1'), 'rec1', 'note')`)
+	assert.NoError(t, err)
+	assert.NoError(t, store.CloseDB())
+
+	store, err = NewStore(defaultDBFile)
+	assert.NoError(t, err)
+	records, err := store.ListRecords("user1")
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	page, err := store.QueryRecords("user1", common.RecordQuery{})
+	assert.NoError(t, err)
+	assert.False(t, page.Records[0].UpdatedAt.IsZero())
+	assert.Empty(t, page.Records[0].Tags)
+}
@@ -7,14 +7,17 @@ import (
 	"errors"
 	"os"
 	"sync"
+	"time"
 
 	"github.com/alexey-mavrin/graduate-2/internal/common"
 	// sqlite sql package
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 )
 
 const (
 	defaultDBFile = "secret_storage.db"
+	// MemoryStore is the name of the store kept in memory only
+	MemoryStore = ":memory:"
 )
 
 // ErrNotFound is to indicate the absence of the record
@@ -23,6 +26,17 @@ var ErrNotFound = errors.New("Record not found")
 // ErrAlreadyExists is to indicate the record already exist
 var ErrAlreadyExists = errors.New("Entity already exists")
 
+// uniqueViolation returns ErrAlreadyExists if the statement failed
+// on the unique constraint, other errors are returned as is
+func uniqueViolation(err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) &&
+		sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+		return ErrAlreadyExists
+	}
+	return err
+}
+
 // Store is the secret storage
 type Store struct {
 	db     *sql.DB
@@ -60,6 +74,10 @@ func NewStore(storeName string) (*Store, error) {
 	if err != nil {
 		return secretStore, err
 	}
+	if storeName == MemoryStore {
+		// every connection opens its own in-memory database
+		secretStore.db.SetMaxOpenConns(1)
+	}
 	err = secretStore.db.Ping()
 	if err != nil {
 		return secretStore, err
@@ -69,22 +87,64 @@ func NewStore(storeName string) (*Store, error) {
 		id INTEGER PRIMARY KEY,
 		user TEXT NOT NULL UNIQUE CHECK (length(user) >= 3),
 		full_name TEXT,
-		password_hash TEXT
+		password_hash TEXT,
+		public_key TEXT
 	)`)
 	if err != nil {
 		return secretStore, err
 	}
 
-	_, err = secretStore.db.Exec(`CREATE TABLE IF NOT EXISTS records (
+	_, err = secretStore.db.Exec(`CREATE TABLE IF NOT EXISTS vaults (
 		id INTEGER PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE CHECK (length(name) >= 1)
+	)`)
+	if err != nil {
+		return secretStore, err
+	}
+
+	_, err = secretStore.db.Exec(`CREATE TABLE IF NOT EXISTS vault_members (
+		vault_id INTEGER NOT NULL,
 		user_id INTEGER NOT NULL,
+		role TEXT NOT NULL,
+		wrapped_key TEXT,
+		UNIQUE(vault_id,user_id),
+		FOREIGN KEY (vault_id)
+		  REFERENCES vaults (id)
+		    ON DELETE CASCADE
+		    ON UPDATE NO ACTION,
+		FOREIGN KEY (user_id)
+		  REFERENCES users (id)
+		    ON DELETE CASCADE
+		    ON UPDATE NO ACTION
+	)`)
+	if err != nil {
+		return secretStore, err
+	}
+
+	// the record belongs either to the user or to the vault
+	_, err = secretStore.db.Exec(`CREATE TABLE IF NOT EXISTS records (
+		id INTEGER PRIMARY KEY,
+		user_id INTEGER,
+		vault_id INTEGER,
 		name TEXT NOT NULL CHECK (length(name) >= 1),
 		type TEXT NOT NULL,
 		opaque TEXT,
 		meta TEXT,
+		data_key TEXT,
+		name_index TEXT,
+		updated_at INTEGER NOT NULL DEFAULT 0,
+		folder TEXT NOT NULL DEFAULT '',
+		tags TEXT NOT NULL DEFAULT '',
+		labels TEXT NOT NULL DEFAULT '',
+		CHECK ((user_id IS NULL) <> (vault_id IS NULL)),
 		UNIQUE(user_id,name,type),
+		UNIQUE(vault_id,name,type),
 		FOREIGN KEY (user_id)
 		  REFERENCES users (id)
+		    ON DELETE CASCADE
+		    ON UPDATE NO ACTION,
+		FOREIGN KEY (vault_id)
+		  REFERENCES vaults (id)
 		    ON DELETE CASCADE
 		    ON UPDATE NO ACTION
 	)`)
@@ -92,9 +152,158 @@ func NewStore(storeName string) (*Store, error) {
 		return secretStore, err
 	}
 
+	err = secretStore.migrate()
+	if err != nil {
+		return secretStore, err
+	}
+
 	return secretStore, nil
 }
 
+// migrate upgrades the store created by the previous versions
+func (s *Store) migrate() error {
+	_, err := s.addColumn("users", "public_key", "TEXT")
+	if err != nil {
+		return err
+	}
+	err = s.rebuildRecords()
+	if err != nil {
+		return err
+	}
+	return s.addColumns()
+}
+
+// rebuildRecords upgrades the records table created before the vaults:
+// the records get the vault_id column and the user_id becomes nullable.
+// SQLite cannot change the column constraints, so the table is recreated
+// and the records are copied.
+func (s *Store) rebuildRecords() error {
+	exists, err := s.hasColumn("records", "vault_id")
+	if err != nil || exists {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, statement := range []string{
+		`CREATE TABLE records_rebuilt (
+			id INTEGER PRIMARY KEY,
+			user_id INTEGER,
+			vault_id INTEGER,
+			name TEXT NOT NULL CHECK (length(name) >= 1),
+			type TEXT NOT NULL,
+			opaque TEXT,
+			meta TEXT,
+			CHECK ((user_id IS NULL) <> (vault_id IS NULL)),
+			UNIQUE(user_id,name,type),
+			UNIQUE(vault_id,name,type),
+			FOREIGN KEY (user_id)
+			  REFERENCES users (id)
+			    ON DELETE CASCADE
+			    ON UPDATE NO ACTION,
+			FOREIGN KEY (vault_id)
+			  REFERENCES vaults (id)
+			    ON DELETE CASCADE
+			    ON UPDATE NO ACTION
+		)`,
+		`INSERT INTO records_rebuilt (id, user_id, name, type, opaque, meta)
+			SELECT id, user_id, name, type, opaque, meta FROM records`,
+		`DROP TABLE records`,
+		`ALTER TABLE records_rebuilt RENAME TO records`,
+	} {
+		_, err = tx.Exec(statement)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// addColumns adds the records columns to the store created by
+// the previous versions. The records stored before have no data key
+// and name index, they are considered modified now, they have no folder
+// and tags. The name index is unique for the owner and the record type.
+func (s *Store) addColumns() error {
+	for _, column := range []string{"data_key", "name_index"} {
+		_, err := s.addColumn("records", column, "TEXT")
+		if err != nil {
+			return err
+		}
+	}
+	for _, statement := range []string{
+		`CREATE UNIQUE INDEX IF NOT EXISTS records_user_name_index
+			ON records (user_id, name_index, type)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS records_vault_name_index
+			ON records (vault_id, name_index, type)`,
+	} {
+		_, err := s.db.Exec(statement)
+		if err != nil {
+			return err
+		}
+	}
+	added, err := s.addColumn("records", "updated_at", "INTEGER NOT NULL DEFAULT 0")
+	if err != nil {
+		return err
+	}
+	if added {
+		_, err = s.db.Exec(`UPDATE records SET updated_at = ?`, updatedAt())
+		if err != nil {
+			return err
+		}
+	}
+	for _, column := range []string{"folder", "tags", "labels"} {
+		_, err = s.addColumn("records", column, "TEXT NOT NULL DEFAULT ''")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasColumn reports if the table has the column
+func (s *Store) hasColumn(table, column string) (bool, error) {
+	rows, err := s.db.Query(`PRAGMA table_info(` + table + `)`)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, colType    string
+			dflt             sql.NullString
+		)
+		err = rows.Scan(&cid, &name, &colType, &notNull, &dflt, &pk)
+		if err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// addColumn adds the column to the table if there is no such
+// column, it reports if the column is added
+func (s *Store) addColumn(table, column, definition string) (bool, error) {
+	exists, err := s.hasColumn(table, column)
+	if err != nil || exists {
+		return false, err
+	}
+	_, err = s.db.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + column + ` ` + definition)
+	return err == nil, err
+}
+
+// updatedAt returns the records modification time to store
+func updatedAt() int64 {
+	return time.Now().UnixNano()
+}
+
 func (s *Store) isUserExists(userName string) (bool, error) {
 	row := s.db.QueryRow(
 		`SELECT count(*) FROM users WHERE user = ?`,
@@ -156,11 +365,12 @@ func (s *Store) AddUser(user common.User) (int64, error) {
 	}
 
 	res, err := s.db.Exec(`INSERT INTO users
-		(user, full_name, password_hash)
-		VALUES(?, ?, ?)`,
+		(user, full_name, password_hash, public_key)
+		VALUES(?, ?, ?, ?)`,
 		user.Name,
 		user.FullName,
 		passwordHash,
+		user.PublicKey,
 	)
 	if err != nil {
 		return 0, err
@@ -191,3 +401,50 @@ func (s *Store) ChangeUserPassword(user, newPass string) error {
 	}
 	return nil
 }
+
+// SetUserPublicKey sets the public key of the user
+func (s *Store) SetUserPublicKey(user, publicKey string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	res, err := s.db.Exec(`UPDATE users
+		SET public_key = ?
+		where user = ?`,
+		publicKey, user,
+	)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows != 1 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetUserPublicKey returns the public key of the user
+func (s *Store) GetUserPublicKey(user string) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	row := s.db.QueryRow(
+		`SELECT public_key FROM users WHERE user = ?`,
+		user,
+	)
+
+	var publicKey sql.NullString
+	err := row.Scan(&publicKey)
+	if err == sql.ErrNoRows {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	if publicKey.String == "" {
+		return "", ErrNotFound
+	}
+	return publicKey.String, nil
+}
@@ -1,11 +1,13 @@
 package store
 
 import (
+	"database/sql"
 	"testing"
 
 	"github.com/alexey-mavrin/graduate-2/internal/common"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func dropCreateStore(t *testing.T) *Store {
@@ -60,3 +62,109 @@ func TestStore_CheckUserAuth(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+// baselineDBFile is the store created with the schema of the first version
+const baselineDBFile = "baseline_storage.db"
+
+// createBaselineStore creates the store with the first version schema
+// holding the user and the record
+func createBaselineStore(t *testing.T) {
+	require.NoError(t, DropStore(baselineDBFile))
+	t.Cleanup(func() { DropStore(baselineDBFile) })
+
+	db, err := sql.Open("sqlite3", baselineDBFile)
+	require.NoError(t, err)
+	defer db.Close()
+	for _, statement := range []string{
+		`CREATE TABLE users (
+			id INTEGER PRIMARY KEY,
+			user TEXT NOT NULL UNIQUE CHECK (length(user) >= 3),
+			full_name TEXT,
+			password_hash TEXT
+		)`,
+		`CREATE TABLE records (
+			id INTEGER PRIMARY KEY,
+			user_id INTEGER NOT NULL,
+			name TEXT NOT NULL CHECK (length(name) >= 1),
+			type TEXT NOT NULL,
+			opaque TEXT,
+			meta TEXT,
+			UNIQUE(user_id,name,type),
+			FOREIGN KEY (user_id)
+			  REFERENCES users (id)
+			    ON DELETE CASCADE
+			    ON UPDATE NO ACTION
+		)`,
+		`INSERT INTO users (user, full_name, password_hash)
+			VALUES ('user1', '', '')`,
+		`INSERT INTO records (user_id, name, type, opaque, meta)
+			VALUES (1, 'rec1', 'note', 'opaque1', 'meta1')`,
+	} {
+		_, err = db.Exec(statement)
+		require.NoError(t, err)
+	}
+}
+
+func TestStore_Migrate(t *testing.T) {
+	createBaselineStore(t)
+	store, err := NewStore(baselineDBFile)
+	require.NoError(t, err)
+
+	t.Run("Vaults", func(t *testing.T) {
+		err := store.SetUserPublicKey("user1", "public key")
+		assert.NoError(t, err)
+		key, err := store.GetUserPublicKey("user1")
+		assert.NoError(t, err)
+		assert.Equal(t, "public key", key)
+
+		exists, err := store.hasColumn("records", "vault_id")
+		assert.NoError(t, err)
+		assert.True(t, exists)
+
+		var count int
+		err = store.db.QueryRow(
+			`SELECT count(*) FROM records WHERE user_id = 1 AND name = 'rec1'`,
+		).Scan(&count)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("Data keys", func(t *testing.T) {
+		exists, err := store.hasColumn("records", "data_key")
+		assert.NoError(t, err)
+		assert.True(t, exists)
+	})
+
+	t.Run("Records", func(t *testing.T) {
+		records, err := store.ListRecords("user1")
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+		assert.Equal(t, "rec1", records[1].Name)
+		record, err := store.GetRecordByID("user1", 1)
+		require.NoError(t, err)
+		assert.Equal(t, "opaque1", record.Opaque)
+
+		record = common.Record{
+			Name:      "rec2",
+			Type:      common.NoteRecord,
+			NameIndex: "index2",
+		}
+		_, err = store.StoreRecord("user1", record)
+		assert.NoError(t, err)
+		record.Name = "rec3"
+		_, err = store.StoreRecord("user1", record)
+		assert.ErrorIs(t, err, ErrAlreadyExists)
+
+		_, err = store.CreateVault("user1", "team", "owner key")
+		require.NoError(t, err)
+		_, err = store.StoreVaultRecord("team", record)
+		assert.NoError(t, err)
+	})
+
+	// the store migrated is opened again as is
+	err = store.CloseDB()
+	require.NoError(t, err)
+	store, err = NewStore(baselineDBFile)
+	require.NoError(t, err)
+	assert.NoError(t, store.CloseDB())
+}
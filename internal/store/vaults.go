@@ -0,0 +1,691 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+	// sqlite sql package
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// ErrIncompleteRotation is to indicate that the vault key rotation
+// does not cover all the vault members or records
+var ErrIncompleteRotation = errors.New("key rotation must cover all vault members and records")
+
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func vaultID(q queryRower, vault string) (int64, error) {
+	row := q.QueryRow(`SELECT id FROM vaults WHERE name = ?`, vault)
+
+	var id int64
+	err := row.Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func userID(q queryRower, user string) (int64, error) {
+	row := q.QueryRow(`SELECT id FROM users WHERE user = ?`, user)
+
+	var id int64
+	err := row.Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// CreateVault creates the vault owned by the user.
+// wrappedKey is the vault key wrapped with the user public key.
+func (s *Store) CreateVault(user, vault, wrappedKey string) (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	_, err = vaultID(tx, vault)
+	if err == nil {
+		return 0, ErrAlreadyExists
+	}
+	if err != ErrNotFound {
+		return 0, err
+	}
+
+	uid, err := userID(tx, user)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := tx.Exec(`INSERT INTO vaults (name) VALUES(?)`, vault)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`INSERT INTO vault_members
+		(vault_id, user_id, role, wrapped_key)
+		VALUES(?, ?, ?, ?)`,
+		id, uid, common.VaultOwner, wrappedKey,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, tx.Commit()
+}
+
+// DeleteVault deletes the vault with all its records
+func (s *Store) DeleteVault(vault string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	id, err := vaultID(tx, vault)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM records WHERE vault_id = ?`, id)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`DELETE FROM vault_members WHERE vault_id = ?`, id)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`DELETE FROM vaults WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ListVaults returns the vaults the user is member of
+func (s *Store) ListVaults(user string) ([]common.Vault, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var vaults []common.Vault
+	rows, err := s.db.Query(
+		`SELECT vaults.name, vault_members.role
+			FROM vaults
+			JOIN vault_members ON vault_members.vault_id = vaults.id
+			JOIN users ON vault_members.user_id = users.id
+			WHERE users.user = ?
+			ORDER BY vaults.name`,
+		user,
+	)
+	if err != nil {
+		return vaults, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var vault common.Vault
+		err = rows.Scan(&vault.Name, &vault.Role)
+		if err != nil {
+			return vaults, err
+		}
+		vaults = append(vaults, vault)
+	}
+	return vaults, rows.Err()
+}
+
+// GetVaultRole returns the role of the user in the vault
+func (s *Store) GetVaultRole(user, vault string) (common.VaultRole, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	row := s.db.QueryRow(
+		`SELECT vault_members.role
+			FROM vault_members
+			JOIN vaults ON vault_members.vault_id = vaults.id
+			JOIN users ON vault_members.user_id = users.id
+			WHERE users.user = ? AND vaults.name = ?`,
+		user, vault,
+	)
+
+	var role common.VaultRole
+	err := row.Scan(&role)
+	if err == sql.ErrNoRows {
+		return role, ErrNotFound
+	}
+	if err != nil {
+		return role, err
+	}
+	return role, nil
+}
+
+// GetVaultKey returns the vault key wrapped for the user
+func (s *Store) GetVaultKey(user, vault string) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	row := s.db.QueryRow(
+		`SELECT vault_members.wrapped_key
+			FROM vault_members
+			JOIN vaults ON vault_members.vault_id = vaults.id
+			JOIN users ON vault_members.user_id = users.id
+			WHERE users.user = ? AND vaults.name = ?`,
+		user, vault,
+	)
+
+	var key string
+	err := row.Scan(&key)
+	if err == sql.ErrNoRows {
+		return key, ErrNotFound
+	}
+	if err != nil {
+		return key, err
+	}
+	return key, nil
+}
+
+// AddVaultMember adds the user to the vault
+func (s *Store) AddVaultMember(vault string, member common.VaultMember) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	vid, err := vaultID(tx, vault)
+	if err != nil {
+		return err
+	}
+	uid, err := userID(tx, member.Name)
+	if err != nil {
+		return err
+	}
+
+	var count int
+	err = tx.QueryRow(`SELECT count(*) FROM vault_members
+		WHERE vault_id = ? AND user_id = ?`,
+		vid, uid,
+	).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count != 0 {
+		return ErrAlreadyExists
+	}
+
+	_, err = tx.Exec(`INSERT INTO vault_members
+		(vault_id, user_id, role, wrapped_key)
+		VALUES(?, ?, ?, ?)`,
+		vid, uid, member.Role, member.Key,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RemoveVaultMember removes the user from the vault
+func (s *Store) RemoveVaultMember(vault, member string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	res, err := s.db.Exec(
+		`DELETE FROM vault_members
+			WHERE vault_id = (SELECT id FROM vaults WHERE name = ?)
+			AND user_id = (SELECT id FROM users WHERE user = ?)`,
+		vault, member,
+	)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows != 1 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetVaultMemberKey replaces the vault key wrapped for the member
+func (s *Store) SetVaultMemberKey(vault, member, wrappedKey string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	res, err := s.db.Exec(
+		`UPDATE vault_members
+			SET wrapped_key = ?
+			WHERE vault_id = (SELECT id FROM vaults WHERE name = ?)
+			AND user_id = (SELECT id FROM users WHERE user = ?)`,
+		wrappedKey, vault, member,
+	)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows != 1 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ListVaultMembers returns the members of the vault
+// with their roles
+func (s *Store) ListVaultMembers(vault string) ([]common.VaultMember, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var members []common.VaultMember
+	rows, err := s.db.Query(
+		`SELECT users.user, vault_members.role
+			FROM vault_members
+			JOIN vaults ON vault_members.vault_id = vaults.id
+			JOIN users ON vault_members.user_id = users.id
+			WHERE vaults.name = ?
+			ORDER BY users.user`,
+		vault,
+	)
+	if err != nil {
+		return members, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var member common.VaultMember
+		err = rows.Scan(&member.Name, &member.Role)
+		if err != nil {
+			return members, err
+		}
+		members = append(members, member)
+	}
+	return members, rows.Err()
+}
+
+// RotateVaultKey replaces the wrapped vault keys of all the members
+// and all the vault records at once. The rotation must cover every
+// member and every record of the vault.
+func (s *Store) RotateVaultKey(vault string,
+	rotation common.VaultKeyRotation,
+) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	vid, err := vaultID(tx, vault)
+	if err != nil {
+		return err
+	}
+
+	var members, records int
+	err = tx.QueryRow(`SELECT count(*) FROM vault_members WHERE vault_id = ?`,
+		vid,
+	).Scan(&members)
+	if err != nil {
+		return err
+	}
+	err = tx.QueryRow(`SELECT count(*) FROM records WHERE vault_id = ?`,
+		vid,
+	).Scan(&records)
+	if err != nil {
+		return err
+	}
+	if members != len(rotation.Keys) || records != len(rotation.Records) {
+		return ErrIncompleteRotation
+	}
+
+	for member, key := range rotation.Keys {
+		res, err := tx.Exec(`UPDATE vault_members
+			SET wrapped_key = ?
+			WHERE vault_id = ?
+			AND user_id = (SELECT id FROM users WHERE user = ?)`,
+			key, vid, member,
+		)
+		if err != nil {
+			return err
+		}
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows != 1 {
+			return ErrIncompleteRotation
+		}
+	}
+
+	for id, record := range rotation.Records {
+		folder, tags, labels, err := labelColumns(record)
+		if err != nil {
+			return err
+		}
+		res, err := tx.Exec(`UPDATE records
+			SET name = ?, type = ?, opaque = ?, meta = ?, data_key = ?,
+				name_index = NULLIF(?, ''), updated_at = ?,
+				folder = ?, tags = ?, labels = ?
+			WHERE id = ? AND vault_id = ?`,
+			record.Name,
+			record.Type,
+			record.Opaque,
+			record.Meta,
+			record.DataKey,
+			record.NameIndex,
+			updatedAt(),
+			folder,
+			tags,
+			labels,
+			id,
+			vid,
+		)
+		if err != nil {
+			return err
+		}
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows != 1 {
+			return ErrIncompleteRotation
+		}
+	}
+
+	return tx.Commit()
+}
+
+// StoreVaultRecord stores Record data in the vault
+func (s *Store) StoreVaultRecord(vault string, record common.Record) (int64, error) {
+	folder, tags, labels, err := labelColumns(record)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	res, err := s.db.Exec(`INSERT INTO records
+		(vault_id, name, type, opaque, meta, data_key, name_index,
+			updated_at, folder, tags, labels)
+		VALUES((SELECT id from vaults where name=?), ?, ?, ?, ?, ?,
+			NULLIF(?, ''), ?, ?, ?, ?)`,
+		vault,
+		record.Name,
+		record.Type,
+		record.Opaque,
+		record.Meta,
+		record.DataKey,
+		record.NameIndex,
+		updatedAt(),
+		folder,
+		tags,
+		labels,
+	)
+	if err != nil {
+		return 0, uniqueViolation(err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// GetVaultRecordID returns the ID of the vault record
+// with the given type and name
+func (s *Store) GetVaultRecordID(vault string,
+	t common.RecordType,
+	name string,
+) (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	row := s.db.QueryRow(
+		`SELECT records.id
+			FROM records JOIN vaults ON records.vault_id = vaults.id
+			WHERE vaults.name = ?
+			AND records.type = ?
+			AND `+nameMatch,
+		vault, t, name, name,
+	)
+
+	var id int64
+	err := row.Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// ListVaultRecords returns list of the vault records
+// with name, type, data key, name index, folder and tags fields filled
+func (s *Store) ListVaultRecords(vault string) (common.Records, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	records := make(common.Records)
+	rows, err := s.db.Query(
+		`SELECT records.id, records.type, records.name,
+				COALESCE(records.data_key, ''),
+				COALESCE(records.name_index, ''),
+				records.folder, records.tags, records.labels
+			FROM records JOIN vaults ON records.vault_id = vaults.id
+			WHERE vaults.name = ?`,
+		vault,
+	)
+	if err != nil {
+		return records, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var record common.Record
+		var folder, tags, labels string
+		err = rows.Scan(&id,
+			&record.Type,
+			&record.Name,
+			&record.DataKey,
+			&record.NameIndex,
+			&folder,
+			&tags,
+			&labels,
+		)
+		if err == nil {
+			err = setLabels(&record, folder, tags, labels)
+		}
+		if err != nil {
+			return records, err
+		}
+		records[id] = record
+	}
+	return records, rows.Err()
+}
+
+// ListVaultRecordsByType returns list of the vault records
+// of the given type with name, type, data key, name index, folder
+// and tags fields filled
+func (s *Store) ListVaultRecordsByType(vault string,
+	t common.RecordType,
+) (common.Records, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	records := make(common.Records)
+	rows, err := s.db.Query(
+		`SELECT records.id, records.name,
+				COALESCE(records.data_key, ''),
+				COALESCE(records.name_index, ''),
+				records.folder, records.tags, records.labels
+			FROM records JOIN vaults ON records.vault_id = vaults.id
+			WHERE vaults.name = ? AND records.type = ?`,
+		vault, t,
+	)
+	if err != nil {
+		return records, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var record common.Record
+		var folder, tags, labels string
+		record.Type = t
+		err = rows.Scan(&id,
+			&record.Name,
+			&record.DataKey,
+			&record.NameIndex,
+			&folder,
+			&tags,
+			&labels,
+		)
+		if err == nil {
+			err = setLabels(&record, folder, tags, labels)
+		}
+		if err != nil {
+			return records, err
+		}
+		records[id] = record
+	}
+	return records, rows.Err()
+}
+
+// GetVaultRecordByID returns the vault record by ID
+func (s *Store) GetVaultRecordByID(vault string, id int64) (common.Record, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var record common.Record
+
+	row := s.db.QueryRow(
+		`SELECT records.name, records.type, records.opaque, records.meta,
+				COALESCE(records.data_key, ''),
+				COALESCE(records.name_index, ''),
+				records.folder, records.tags, records.labels
+			FROM records JOIN vaults ON records.vault_id = vaults.id
+			WHERE vaults.name = ? AND records.id = ?`,
+		vault, id,
+	)
+
+	var folder, tags, labels string
+	err := row.Scan(&record.Name,
+		&record.Type,
+		&record.Opaque,
+		&record.Meta,
+		&record.DataKey,
+		&record.NameIndex,
+		&folder,
+		&tags,
+		&labels,
+	)
+	if err == sql.ErrNoRows {
+		return record, ErrNotFound
+	}
+	if err != nil {
+		return record, err
+	}
+	return record, setLabels(&record, folder, tags, labels)
+}
+
+// UpdateVaultRecordByID updates the vault record by ID
+func (s *Store) UpdateVaultRecordByID(vault string,
+	id int64,
+	record common.Record,
+) error {
+	folder, tags, labels, err := labelColumns(record)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	res, err := s.db.Exec(`UPDATE records
+		SET name = ?, type = ?, opaque = ?, meta = ?, data_key = ?,
+			name_index = NULLIF(?, ''), updated_at = ?,
+			folder = ?, tags = ?, labels = ?
+		WHERE id = ?
+		AND vault_id = (SELECT id FROM vaults WHERE name = ?)`,
+		record.Name,
+		record.Type,
+		record.Opaque,
+		record.Meta,
+		record.DataKey,
+		record.NameIndex,
+		updatedAt(),
+		folder,
+		tags,
+		labels,
+		id,
+		vault,
+	)
+	if err != nil {
+		return uniqueViolation(err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows != 1 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// DeleteVaultRecordByID deletes the vault record by ID
+func (s *Store) DeleteVaultRecordByID(vault string, id int64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	res, err := s.db.Exec(`DELETE FROM records
+		WHERE id = ?
+		AND vault_id = (SELECT id FROM vaults WHERE name = ?)`,
+		id, vault,
+	)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows != 1 {
+		return ErrNotFound
+	}
+	return nil
+}
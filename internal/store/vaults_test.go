@@ -0,0 +1,133 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/alexey-mavrin/graduate-2/internal/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_Vaults(t *testing.T) {
+	store := dropCreateStore(t)
+	t.Run("Create vault and manage members", func(t *testing.T) {
+		owner := "user1"
+		member := "user2"
+		vault := "team"
+
+		for _, user := range []string{owner, member} {
+			_, err := store.AddUser(common.User{Name: user})
+			require.NoError(t, err)
+		}
+
+		_, err := store.CreateVault(owner, vault, "owner key")
+		assert.NoError(t, err)
+
+		// vault names are unique
+		_, err = store.CreateVault(member, vault, "member key")
+		assert.ErrorIs(t, err, ErrAlreadyExists)
+
+		role, err := store.GetVaultRole(owner, vault)
+		assert.NoError(t, err)
+		assert.Equal(t, common.VaultOwner, role)
+
+		_, err = store.GetVaultRole(member, vault)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		err = store.AddVaultMember(vault, common.VaultMember{
+			Name: member,
+			Role: common.VaultReader,
+			Key:  "member key",
+		})
+		assert.NoError(t, err)
+
+		key, err := store.GetVaultKey(member, vault)
+		assert.NoError(t, err)
+		assert.Equal(t, "member key", key)
+
+		members, err := store.ListVaultMembers(vault)
+		assert.NoError(t, err)
+		assert.Equal(t, []common.VaultMember{
+			{Name: owner, Role: common.VaultOwner},
+			{Name: member, Role: common.VaultReader},
+		}, members)
+
+		vaults, err := store.ListVaults(member)
+		assert.NoError(t, err)
+		assert.Equal(t, []common.Vault{
+			{Name: vault, Role: common.VaultReader},
+		}, vaults)
+
+		err = store.RemoveVaultMember(vault, member)
+		assert.NoError(t, err)
+
+		_, err = store.GetVaultKey(member, vault)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestStore_VaultRecords(t *testing.T) {
+	store := dropCreateStore(t)
+	t.Run("Store and rotate vault records", func(t *testing.T) {
+		user := "user1"
+		vault := "team"
+		record := common.Record{
+			Name:   "rec1",
+			Type:   common.NoteRecord,
+			Opaque: "1111",
+		}
+
+		_, err := store.AddUser(common.User{Name: user})
+		require.NoError(t, err)
+		_, err = store.CreateVault(user, vault, "key1")
+		require.NoError(t, err)
+
+		id, err := store.StoreVaultRecord(vault, record)
+		assert.NoError(t, err)
+
+		// personal record with the same name does not conflict
+		_, err = store.StoreRecord(user, record)
+		assert.NoError(t, err)
+
+		gotID, err := store.GetVaultRecordID(vault, record.Type, record.Name)
+		assert.NoError(t, err)
+		assert.Equal(t, id, gotID)
+
+		// vault record is not accessible as personal one
+		_, err = store.GetRecordByID(user, id)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		records, err := store.ListVaultRecords(vault)
+		assert.NoError(t, err)
+		assert.Len(t, records, 1)
+
+		// rotation must cover all the records
+		err = store.RotateVaultKey(vault, common.VaultKeyRotation{
+			Keys:    map[string]string{user: "key2"},
+			Records: common.Records{},
+		})
+		assert.ErrorIs(t, err, ErrIncompleteRotation)
+
+		rotated := record
+		rotated.Opaque = "2222"
+		err = store.RotateVaultKey(vault, common.VaultKeyRotation{
+			Keys:    map[string]string{user: "key2"},
+			Records: common.Records{id: rotated},
+		})
+		assert.NoError(t, err)
+
+		key, err := store.GetVaultKey(user, vault)
+		assert.NoError(t, err)
+		assert.Equal(t, "key2", key)
+
+		gotRecord, err := store.GetVaultRecordByID(vault, id)
+		assert.NoError(t, err)
+		assert.Equal(t, rotated, gotRecord)
+
+		err = store.DeleteVault(vault)
+		assert.NoError(t, err)
+
+		_, err = store.GetVaultRecordByID(vault, id)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
@@ -0,0 +1,51 @@
+package urlmatch
+
+import (
+	"net"
+	"strings"
+)
+
+// Rules are the match levels required for the sites by their host
+// names. The rule of the domain applies to its subdomains as well.
+type Rules map[string]Level
+
+// SiteRule returns the rule of the site given by URL or by host name:
+// the rule of the normalized host or of its closest parent domain.
+// The rules of the other kinds, e.g. the password policies, match
+// the sites the same way.
+func SiteRule[T any](rules map[string]T, site string) (T, bool) {
+	var none T
+	u, err := Normalize(site)
+	if err != nil {
+		return none, false
+	}
+	host := u.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	for host != "" {
+		if rule, ok := rules[host]; ok {
+			return rule, true
+		}
+		i := strings.IndexByte(host, '.')
+		if i < 0 {
+			break
+		}
+		host = host[i+1:]
+	}
+	return none, false
+}
+
+// ForSite returns the match level of the site given by URL: the rule
+// of the host or of the closest parent domain, or the default level
+// if there is no rule for the site. LevelDomain is the default
+// if the default level is not set.
+func (r Rules) ForSite(site string, def Level) Level {
+	if def == LevelNone {
+		def = LevelDomain
+	}
+	if l, ok := SiteRule(r, site); ok {
+		return l
+	}
+	return def
+}
@@ -0,0 +1,181 @@
+// Package urlmatch matches the account URLs to the URL looked up by
+// exact URL, host or registrable domain
+package urlmatch
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Level is how close the URLs match, the higher the closer
+type Level int
+
+// match levels
+const (
+	// LevelNone is no match
+	LevelNone Level = iota
+	// LevelDomain is the same registrable domain, e.g. example.com
+	// for app.example.com and login.example.com
+	LevelDomain
+	// LevelHost is the same host
+	LevelHost
+	// LevelExact is the same scheme, host and path
+	LevelExact
+)
+
+var levelNames = map[Level]string{
+	LevelNone:   "none",
+	LevelDomain: "domain",
+	LevelHost:   "host",
+	LevelExact:  "exact",
+}
+
+// ErrBadURL is returned when the URL has no host
+var ErrBadURL = errors.New("bad URL")
+
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("Level(%d)", int(l))
+}
+
+// ParseLevel returns the level by name: domain, host or exact
+func ParseLevel(name string) (Level, error) {
+	for l, n := range levelNames {
+		if n == name && l != LevelNone {
+			return l, nil
+		}
+	}
+	return LevelNone, fmt.Errorf("unknown URL match %q: want domain, host or exact", name)
+}
+
+// MarshalText returns the level name
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText parses the level name
+func (l *Level) UnmarshalText(text []byte) error {
+	level, err := ParseLevel(string(text))
+	if err != nil {
+		return err
+	}
+	*l = level
+	return nil
+}
+
+// defaultPorts are removed from the URLs normalized
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// Normalize returns the URL with https scheme if none is set,
+// the host in lower case without www. prefix and the default port,
+// the path without trailing slash. The user info, query and fragment
+// are removed.
+func Normalize(raw string) (*url.URL, error) {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadURL, err)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("%w %q: no host", ErrBadURL, raw)
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	host = strings.TrimPrefix(host, "www.")
+	if port := u.Port(); port != "" && port != defaultPorts[u.Scheme] {
+		host = net.JoinHostPort(host, port)
+	}
+	return &url.URL{
+		Scheme: u.Scheme,
+		Host:   host,
+		Path:   strings.TrimRight(u.Path, "/"),
+	}, nil
+}
+
+// Domain returns the registrable domain of the host by the public
+// suffix list: example.co.uk for app.example.co.uk. The IP address
+// or the host not under a public suffix is returned as is.
+func Domain(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return domain
+}
+
+// Match returns the level the stored URL matches the URL looked up,
+// both URLs are normalized
+func Match(lookup, stored *url.URL) Level {
+	switch {
+	case lookup.Host == stored.Host && lookup.Scheme == stored.Scheme &&
+		lookup.Path == stored.Path:
+		return LevelExact
+	case lookup.Host == stored.Host:
+		return LevelHost
+	case Domain(lookup.Host) == Domain(stored.Host):
+		return LevelDomain
+	}
+	return LevelNone
+}
+
+// Candidate is the account matching the URL looked up
+type Candidate struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	UserName string `json:"user_name"`
+	URL      string `json:"url"`
+	Level    Level  `json:"match"`
+}
+
+// Rank returns the candidates matching the URL at the level
+// given at least, the closest match first. The candidates with
+// no URL or not parsed are skipped.
+func Rank(lookup string, candidates []Candidate, min Level) ([]Candidate, error) {
+	u, err := Normalize(lookup)
+	if err != nil {
+		return nil, err
+	}
+	var ranked []Candidate
+	for _, c := range candidates {
+		if c.URL == "" {
+			continue
+		}
+		stored, err := Normalize(c.URL)
+		if err != nil {
+			continue
+		}
+		c.Level = Match(u, stored)
+		if c.Level == LevelNone || c.Level < min {
+			continue
+		}
+		ranked = append(ranked, c)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Level != ranked[j].Level {
+			return ranked[i].Level > ranked[j].Level
+		}
+		return ranked[i].ID < ranked[j].ID
+	})
+	return ranked, nil
+}
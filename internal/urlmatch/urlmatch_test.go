@@ -0,0 +1,85 @@
+package urlmatch
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"example.com", "https://example.com"},
+		{"HTTPS://WWW.Example.COM:443/Login/?next=/#top", "https://example.com/Login"},
+		{"http://bob@example.com:80", "http://example.com"},
+		{"https://example.com:8443/", "https://example.com:8443"},
+		{"example.com.", "https://example.com"},
+	}
+	for _, tt := range tests {
+		u, err := Normalize(tt.raw)
+		require.NoError(t, err, tt.raw)
+		assert.Equal(t, tt.want, u.String(), tt.raw)
+	}
+
+	_, err := Normalize("https:///path")
+	assert.ErrorIs(t, err, ErrBadURL)
+}
+
+func TestDomain(t *testing.T) {
+	assert.Equal(t, "example.com", Domain("app.example.com"))
+	assert.Equal(t, "example.co.uk", Domain("login.example.co.uk:8443"))
+	assert.Equal(t, "bob.github.io", Domain("bob.github.io"))
+	assert.Equal(t, "10.0.0.1", Domain("10.0.0.1"))
+	assert.Equal(t, "localhost", Domain("localhost"))
+}
+
+func TestRank(t *testing.T) {
+	candidates := []Candidate{
+		{ID: 1, Name: "domain", URL: "login.example.com"},
+		{ID: 2, Name: "host", URL: "https://app.example.com/admin"},
+		{ID: 3, Name: "exact", URL: "https://app.example.com/login/"},
+		{ID: 4, Name: "other", URL: "https://example.org"},
+		{ID: 5, Name: "no url"},
+		{ID: 6, Name: "another tenant", URL: "https://alice.github.io"},
+		{ID: 7, Name: "http host", URL: "http://app.example.com/login"},
+	}
+
+	ranked, err := Rank("https://app.example.com/login", candidates, LevelDomain)
+	require.NoError(t, err)
+	var names []string
+	for _, c := range ranked {
+		names = append(names, c.Name)
+	}
+	assert.Equal(t, []string{"exact", "host", "http host", "domain"}, names)
+	assert.Equal(t, LevelExact, ranked[0].Level)
+
+	ranked, err = Rank("https://app.example.com/login", candidates, LevelHost)
+	require.NoError(t, err)
+	assert.Len(t, ranked, 3)
+
+	ranked, err = Rank("https://bob.github.io", candidates, LevelDomain)
+	require.NoError(t, err)
+	assert.Empty(t, ranked, "github.io is a public suffix")
+
+	_, err = Rank("", candidates, LevelDomain)
+	assert.Error(t, err)
+}
+
+func TestRules(t *testing.T) {
+	var rules Rules
+	err := json.Unmarshal([]byte(`{"example.com":"host","bank.example.com":"exact"}`),
+		&rules)
+	require.NoError(t, err)
+
+	assert.Equal(t, LevelExact, rules.ForSite("https://bank.example.com/login", LevelNone))
+	assert.Equal(t, LevelHost, rules.ForSite("app.example.com", LevelNone))
+	assert.Equal(t, LevelDomain, rules.ForSite("example.org", LevelNone))
+	assert.Equal(t, LevelHost, rules.ForSite("example.org", LevelHost))
+
+	err = json.Unmarshal([]byte(`{"example.com":"subdomain"}`), &rules)
+	assert.Error(t, err)
+}